@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/codec"
+)
+
+// benchResult holds the generator throughput of one payload
+type benchResult struct {
+	name     string
+	messages int
+	bytes    int
+	elapsed  time.Duration
+	err      error
+}
+
+// print writes the result as one summary line
+func (r benchResult) print() {
+	seconds := r.elapsed.Seconds()
+	fmt.Printf("%s: %d messages, %d bytes in %s: %.0f msg/s, %.2f MB/s\n",
+		r.name, r.messages, r.bytes, r.elapsed.Round(time.Millisecond),
+		float64(r.messages)/seconds, float64(r.bytes)/seconds/1e6)
+}
+
+// runBench implements the bench command: it runs the generators of all
+// payloads in parallel as fast as possible, without sending, and reports
+// their throughput. It returns the process exit code.
+func runBench(args []string) int {
+	fs, cfgFlags := newFlagSet("bench")
+	duration := fs.Duration("duration", 10*time.Second, "how long to generate")
+	count := fs.Int("n", 0, "stop a payload after this many messages, 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *duration <= 0 || *count < 0 {
+		fmt.Fprintln(os.Stderr, "-duration must be positive and -n must not be negative")
+		return exitUsage
+	}
+
+	cfg, ok := loadConfig(cfgFlags)
+	if !ok {
+		return exitConfig
+	}
+	log := problemLogger()
+
+	pools, err := buildPools(cfg, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfig
+	}
+	generators, _, err := buildGenerators(cfg, pools, log, codec.Offline())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfig
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	ctx, stop := context.WithTimeout(ctx, *duration)
+	defer stop()
+
+	results := make([]benchResult, len(generators))
+	var wg sync.WaitGroup
+	for i, pg := range generators {
+		wg.Add(1)
+		go func(i int, pg payloadGenerator) {
+			defer wg.Done()
+			results[i] = benchPayload(ctx, &pg, *count, log)
+		}(i, pg)
+	}
+	wg.Wait()
+
+	total := benchResult{name: "total"}
+	code := exitOK
+	for _, r := range results {
+		if r.err != nil {
+			fmt.Printf("%s: FAILED: %v\n", r.name, r.err)
+			code = exitFailure
+			continue
+		}
+		r.print()
+		total.messages += r.messages
+		total.bytes += r.bytes
+		total.elapsed = max(total.elapsed, r.elapsed)
+	}
+	if len(results) > 1 {
+		total.print()
+	}
+	return code
+}
+
+// benchPayload generates batches of a payload until the context is done or
+// the message limit is reached. Messages dropped by schema validation are
+// not counted.
+func benchPayload(ctx context.Context, pg *payloadGenerator, limit int, log *slog.Logger) benchResult {
+	r := benchResult{name: pg.name}
+	start := time.Now()
+
+	for ctx.Err() == nil && (limit == 0 || r.messages < limit) {
+		n := pg.batchSize
+		if limit > 0 {
+			n = min(n, limit-r.messages)
+		}
+		messages, err := pg.nextBatch(n, nil, false, log)
+		if err != nil {
+			r.err = err
+			break
+		}
+		for _, msg := range messages {
+			r.bytes += len(msg.Key) + len(msg.Value)
+		}
+		r.messages += len(messages)
+	}
+
+	r.elapsed = time.Since(start)
+	return r
+}
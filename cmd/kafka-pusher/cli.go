@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+)
+
+// Exit codes shared by all commands
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command ran and failed
+	exitUsage   = 2 // invalid command line
+	exitConfig  = 3 // the configuration could not be loaded
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands returns the available subcommands
+func commands() []command {
+	return []command{
+		{"run", "send the configured payloads, on the scheduler or once (default)", runRun},
+		{"validate", "load the config and templates and check sample messages, without connecting", runValidate},
+		{"preview", "print generated messages with their keys and headers", runPreview},
+		{"produce", "send a literal message or standard input lines to a topic", runProduce},
+		{"bench", "measure generator throughput without sending", runBench},
+		{"measure", "consume topics and report the latency of stamped messages", runMeasure},
+		{"config", "print the effective configuration: config dump", runConfig},
+		{"version", "show version information", runVersion},
+	}
+}
+
+// dispatch runs the command named by the first argument. Without one, or
+// when the arguments start with a flag, it runs the run command.
+func dispatch(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runRun(args)
+	}
+
+	name := args[0]
+	if name == "help" {
+		usage(os.Stdout)
+		return exitOK
+	}
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd.run(args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage(os.Stderr)
+	return exitUsage
+}
+
+// usage prints the available commands
+func usage(w *os.File) {
+	fmt.Fprintln(w, "Usage: kafka-pusher [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'kafka-pusher <command> -h' for the flags of a command.")
+}
+
+// configFlags holds the flags shared by all commands that select and adjust
+// the configuration
+type configFlags struct {
+	path      string
+	profile   string
+	overrides []config.Override
+	groups    map[string]bool
+	check     func(*config.Config) error // extra checks reported with the config problems
+}
+
+// override registers a flag that overrides the config field at path
+func (c *configFlags) override(fs *flag.FlagSet, name, path, usage string) {
+	fs.Func(name, usage, func(value string) error {
+		c.overrides = append(c.overrides, config.Override{Path: path, Value: value})
+		return nil
+	})
+}
+
+// switchGroup registers a flag that switches payload groups on or off
+func (c *configFlags) switchGroup(fs *flag.FlagSet, name string, enabled bool, usage string) {
+	fs.Func(name, usage, func(value string) error {
+		if c.groups == nil {
+			c.groups = make(map[string]bool)
+		}
+		for _, group := range strings.Split(value, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				c.groups[group] = enabled
+			}
+		}
+		return nil
+	})
+}
+
+// newFlagSet creates the flag set of a command with the shared flags that
+// select and adjust the configuration
+func newFlagSet(name string) (*flag.FlagSet, *configFlags) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	c := &configFlags{}
+	fs.StringVar(&c.path, "config", "./config.yaml", "path to configuration file")
+	fs.StringVar(&c.profile, "profile", os.Getenv(config.ProfileEnv), "profile to overlay on the configuration, defaults to $"+config.ProfileEnv)
+	c.switchGroup(fs, "enable-group", true, "comma-separated payload groups to switch on, may be repeated")
+	c.switchGroup(fs, "disable-group", false, "comma-separated payload groups to switch off, may be repeated")
+	fs.Func("set", "override a config field as path=value, e.g. kafka.timeout=30s, may be repeated", func(value string) error {
+		o, err := config.ParseOverride(value)
+		if err != nil {
+			return err
+		}
+		c.overrides = append(c.overrides, o)
+		return nil
+	})
+	c.override(fs, "brokers", "kafka.brokers", "comma-separated brokers of the kafka section")
+	c.override(fs, "log-level", "logging.level", "log level: debug, info, warn or error")
+	c.override(fs, "seed", "seed", "seed of the random values in generated messages, for reproducible runs")
+	return fs, c
+}
+
+// loadConfig loads the configuration with the overrides given as flags,
+// reporting failures on standard error
+func loadConfig(c *configFlags) (*config.Config, bool) {
+	cfg, err := config.LoadWith(c.path, config.LoadOptions{
+		Profile:   c.profile,
+		Overrides: c.overrides,
+		Groups:    c.groups,
+		Check:     c.check,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return nil, false
+	}
+	return cfg, true
+}
+
+// problemLogger returns a logger for commands whose results go to standard
+// output: only warnings and errors are logged, to standard error
+func problemLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+}
+
+// runVersion implements the version command
+func runVersion(args []string) int {
+	fmt.Printf("kafka-pusher version %s (commit: %s, built: %s)\n", version, commit, date)
+	return exitOK
+}
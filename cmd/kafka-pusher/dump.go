@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maskedKeys are config keys whose values are not printed
+var maskedKeys = map[string]bool{"password": true}
+
+// runConfig implements the config command. Its only subcommand, dump,
+// prints the configuration that would run, after includes, profiles,
+// overrides, groups and defaults, with secrets masked. It returns the
+// process exit code.
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "dump" {
+		fmt.Fprintln(os.Stderr, "Usage: kafka-pusher config dump [flags]")
+		return exitUsage
+	}
+	fs, cfgFlags := newFlagSet("config dump")
+	showSecrets := fs.Bool("secrets", false, "print passwords instead of masking them")
+	if err := fs.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+
+	cfg, ok := loadConfig(cfgFlags)
+	if !ok {
+		return exitConfig
+	}
+
+	var doc yaml.Node
+	if err := doc.Encode(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode configuration: %v\n", err)
+		return exitFailure
+	}
+	if !*showSecrets {
+		maskSecrets(&doc)
+	}
+
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print configuration: %v\n", err)
+		return exitFailure
+	}
+	if err := enc.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to print configuration: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// maskSecrets replaces the non-empty values of masked keys
+func maskSecrets(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if maskedKeys[node.Content[i].Value] && value.Kind == yaml.ScalarNode && value.Value != "" {
+				value.Value = "***"
+				value.Tag = "!!str"
+				value.Style = 0
+			}
+		}
+	}
+	for _, child := range node.Content {
+		maskSecrets(child)
+	}
+}
@@ -2,19 +2,31 @@ package main
 
 import (
 	"context"
-	"flag"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/alexermolov/go-kafka-pusher/internal/codec"
 	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/delivery"
+	"github.com/alexermolov/go-kafka-pusher/internal/faults"
+	"github.com/alexermolov/go-kafka-pusher/internal/jsonschema"
 	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/lifecycle"
 	"github.com/alexermolov/go-kafka-pusher/internal/logger"
+	"github.com/alexermolov/go-kafka-pusher/internal/replay"
 	"github.com/alexermolov/go-kafka-pusher/internal/scheduler"
+	"github.com/alexermolov/go-kafka-pusher/internal/sink"
 	"github.com/alexermolov/go-kafka-pusher/internal/template"
+	"github.com/alexermolov/go-kafka-pusher/internal/verify"
 )
 
 var (
@@ -24,21 +36,43 @@ var (
 )
 
 func main() {
-	// Parse command-line flags
-	configPath := flag.String("config", "./config.yaml", "path to configuration file")
-	showVersion := flag.Bool("version", false, "show version information")
-	flag.Parse()
+	os.Exit(dispatch(os.Args[1:]))
+}
+
+// runRun implements the run command: it sends the configured payloads on the
+// scheduler until interrupted, or once when the scheduler is disabled
+func runRun(args []string) int {
+	fs, cfgFlags := newFlagSet("run")
+	showVersion := fs.Bool("version", false, "show version information")
+	cfgFlags.override(fs, "batch-size", "payloads.*.batch_size", "messages per batch of every payload")
+	cfgFlags.override(fs, "duration", "scheduler.duration", "stop the scheduler after this long")
+	fs.Func("interval", "run the scheduler at this interval", func(value string) error {
+		cfgFlags.overrides = append(cfgFlags.overrides,
+			config.Override{Path: "scheduler.enabled", Value: "true"},
+			config.Override{Path: "scheduler.interval", Value: value},
+		)
+		return nil
+	})
+	fs.Func("topic", "send a payload to another topic as name=topic, may be repeated", func(value string) error {
+		name, topic, ok := strings.Cut(value, "=")
+		if !ok || name == "" || topic == "" {
+			return fmt.Errorf("topic must be name=topic")
+		}
+		cfgFlags.overrides = append(cfgFlags.overrides, config.Override{Path: "payloads." + name + ".topic", Value: topic})
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
 
 	if *showVersion {
-		fmt.Printf("kafka-pusher version %s (commit: %s, built: %s)\n", version, commit, date)
-		os.Exit(0)
+		return runVersion(nil)
 	}
 
 	// Load configuration
-	cfg, err := config.Load(*configPath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+	cfg, ok := loadConfig(cfgFlags)
+	if !ok {
+		return exitConfig
 	}
 
 	// Initialize logger
@@ -59,56 +93,155 @@ func main() {
 	// Run application
 	if err := run(ctx, cfg, log, sigChan); err != nil {
 		log.Error("application error", slog.String("error", err.Error()))
-		os.Exit(1)
+		return exitFailure
 	}
 
 	log.Info("kafka-pusher stopped successfully")
+	return exitOK
 }
 
-func run(ctx context.Context, cfg *config.Config, log *slog.Logger, sigChan <-chan os.Signal) error {
-	// Initialize template generators for each payload
-	type payloadGenerator struct {
-		name      string
-		generator *template.Generator
-		batchSize int
-		topic     string
+// messageGenerator produces the messages of a payload
+type messageGenerator interface {
+	Generate() ([]byte, error)
+}
+
+// payloadGenerator holds everything needed to produce one payload's batches
+type payloadGenerator struct {
+	name      string
+	generator messageGenerator
+	lifecycle *lifecycle.Engine
+	encoder   codec.Encoder
+	validator *messageValidator
+	faults    *faults.Injector
+	delivery  *delivery.Simulator
+	batchSize int
+	topic     string
+	clusters  config.ClusterNames
+	out       sink.Sink
+}
+
+// encode converts a rendered message into the payload's wire format
+func (pg *payloadGenerator) encode(message []byte) ([]byte, error) {
+	if pg.encoder == nil {
+		return message, nil
 	}
+	return pg.encoder.Encode(message)
+}
 
-	generators := make([]payloadGenerator, len(cfg.Payloads))
-	for i, payloadCfg := range cfg.Payloads {
-		gen, err := template.NewGenerator(payloadCfg.TemplatePath)
+// nextBatch generates, validates and encodes up to n messages. Messages
+// dropped by the schema validator are left out, faults are applied and
+// every message is tracked by the verifier, which may be nil.
+func (pg *payloadGenerator) nextBatch(n int, verifier *verify.Verifier, verbose bool, log *slog.Logger) ([]kafka.Message, error) {
+	// Lifecycle payloads emit keyed state transitions instead of independent messages
+	if pg.lifecycle != nil {
+		events, err := pg.lifecycle.Next(n)
 		if err != nil {
-			return fmt.Errorf("failed to create template generator for %s: %w", payloadCfg.Name, err)
+			return nil, fmt.Errorf("failed to advance lifecycle for %s: %w", pg.name, err)
 		}
-		generators[i] = payloadGenerator{
-			name:      payloadCfg.Name,
-			generator: gen,
-			batchSize: payloadCfg.BatchSize,
-			topic:     payloadCfg.Topic,
+		messages := make([]kafka.Message, 0, len(events))
+		for i, event := range events {
+			if verbose {
+				log.Debug("generated message",
+					slog.String("payload", pg.name),
+					slog.String("key", string(event.Key)),
+					slog.String("state", event.State),
+					slog.String("content", string(event.Value)),
+				)
+			}
+			keep, err := pg.validator.check(pg.name, event.Value, log)
+			if err != nil {
+				return nil, err
+			}
+			if !keep {
+				continue
+			}
+			value, err := pg.encode(event.Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode message %d for %s: %w", i, pg.name, err)
+			}
+			msg := kafka.Message{Key: event.Key, Value: value, Source: pg.name}
+			verifier.Track(pg.topic, &msg)
+			messages = append(messages, pg.faults.Apply(msg)...)
 		}
-		log.Info("template generator initialized",
-			slog.String("name", payloadCfg.Name),
-			slog.String("path", payloadCfg.TemplatePath),
-			slog.Int("batch_size", payloadCfg.BatchSize),
-			slog.String("topic", payloadCfg.Topic),
-		)
+		return messages, nil
+	}
+
+	// Generate batch of messages from template
+	messages := make([]kafka.Message, 0, n)
+	for i := 0; i < n; i++ {
+		message, err := pg.generator.Generate()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate message %d for %s: %w", i, pg.name, err)
+		}
+
+		// Log the message if verbose mode is enabled
+		if verbose {
+			log.Debug("generated message",
+				slog.String("payload", pg.name),
+				slog.Int("index", i),
+				slog.String("content", string(message)),
+			)
+		}
+
+		keep, err := pg.validator.check(pg.name, message, log)
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			continue
+		}
+
+		value, err := pg.encode(message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode message %d for %s: %w", i, pg.name, err)
+		}
+		msg := kafka.Message{Value: value, Source: pg.name}
+		verifier.Track(pg.topic, &msg)
+		messages = append(messages, pg.faults.Apply(msg)...)
+	}
+	return messages, nil
+}
+
+func run(ctx context.Context, cfg *config.Config, log *slog.Logger, sigChan <-chan os.Signal) error {
+	pools, err := buildPools(cfg, log)
+	if err != nil {
+		return err
+	}
+	generators, replays, err := buildGenerators(cfg, pools, log)
+	if err != nil {
+		return err
 	}
 
-	// Initialize Kafka producer
-	producer, err := kafka.NewProducer(&cfg.Kafka, log)
+	// Open one producer per cluster, or the configured sink
+	outs, err := openOutputs(ctx, cfg, log)
 	if err != nil {
-		return fmt.Errorf("failed to create kafka producer: %w", err)
+		return err
+	}
+	defer outs.close()
+	for i := range generators {
+		generators[i].out = outs.route(generators[i].clusters)
 	}
-	defer func() {
-		if err := producer.Close(); err != nil {
-			log.Error("failed to close producer", slog.String("error", err.Error()))
+
+	// Start consuming the target topics before producing so nothing is missed
+	var verifier *verify.Verifier
+	if cfg.Verify != nil && cfg.Verify.Enabled {
+		verifier, err = startVerifier(ctx, cfg, generators, log)
+		if err != nil {
+			return err
 		}
-	}()
-	log.Info("kafka producer initialized",
-		slog.Any("brokers", cfg.Kafka.Brokers),
-	)
+		defer verifier.Close()
+	}
+
+	// Start replay payloads, they run until their file is exhausted
+	replayCtx, stopReplays := context.WithCancel(ctx)
+	defer stopReplays()
+	replayDone, err := startReplays(replayCtx, replays, outs, pools, log)
+	if err != nil {
+		return err
+	}
 
 	// Define the task function
+	abortChan := make(chan error, 1)
 	taskFunc := func(ctx context.Context) error {
 		// Process all payloads in parallel
 		var wg sync.WaitGroup
@@ -119,33 +252,20 @@ func run(ctx context.Context, cfg *config.Config, log *slog.Logger, sigChan <-ch
 			go func(pg payloadGenerator) {
 				defer wg.Done()
 
-				// Generate batch of messages from template
-				messages := make([][]byte, pg.batchSize)
-				for i := 0; i < pg.batchSize; i++ {
-					message, err := pg.generator.Generate()
-					if err != nil {
-						errChan <- fmt.Errorf("failed to generate message %d for %s: %w", i, pg.name, err)
-						return
-					}
-					messages[i] = message
-
-					// Log the message if verbose mode is enabled
-					if cfg.Logging.Verbose {
-						log.Debug("generated message",
-							slog.String("payload", pg.name),
-							slog.Int("index", i),
-							slog.String("content", string(message)),
-						)
-					}
+				messages, err := pg.nextBatch(pg.batchSize, verifier, cfg.Logging.Verbose, log)
+				if err != nil {
+					errChan <- err
+					return
 				}
+				messages = pg.delivery.Process(messages)
 
 				// Send batch to Kafka
-				log.Info("sending batch to Kafka",
+				log.Info("sending batch",
 					slog.String("payload", pg.name),
 					slog.String("topic", pg.topic),
 					slog.Int("batch_size", len(messages)),
 				)
-				if err := producer.SendBatch(ctx, pg.topic, messages); err != nil {
+				if err := pg.out.SendMessages(ctx, pg.topic, messages); err != nil {
 					errChan <- fmt.Errorf("failed to send batch for %s: %w", pg.name, err)
 					return
 				}
@@ -155,14 +275,33 @@ func run(ctx context.Context, cfg *config.Config, log *slog.Logger, sigChan <-ch
 		wg.Wait()
 		close(errChan)
 
-		// Check for errors
+		// Check for errors, a schema violation in abort mode stops the run
 		for err := range errChan {
+			if errors.Is(err, errSchemaViolation) {
+				select {
+				case abortChan <- err:
+				default:
+				}
+			}
 			return err
 		}
 
 		return nil
 	}
 
+	// In tick scope each execution is one transaction across all payloads
+	if outs.transactional() {
+		sendBatches := taskFunc
+		taskFunc = func(ctx context.Context) error {
+			outs.beginTransaction()
+			err := sendBatches(ctx)
+			if endErr := outs.endTransaction(ctx, err); err == nil {
+				err = endErr
+			}
+			return err
+		}
+	}
+
 	// If scheduler is enabled, run periodically
 	if cfg.Scheduler != nil && cfg.Scheduler.Enabled {
 		sched, err := scheduler.NewScheduler(cfg.Scheduler, log, taskFunc)
@@ -173,17 +312,36 @@ func run(ctx context.Context, cfg *config.Config, log *slog.Logger, sigChan <-ch
 		if err := sched.Start(ctx); err != nil {
 			return fmt.Errorf("failed to start scheduler: %w", err)
 		}
-		defer func() {
-			if err := sched.Stop(); err != nil {
-				log.Error("failed to stop scheduler", slog.String("error", err.Error()))
-			}
-		}()
 
 		log.Info("scheduler started, waiting for termination signal...")
 
+		// A nil channel never fires, so without a duration only a signal stops the run
+		var deadline <-chan time.Time
+		if cfg.Scheduler.Duration > 0 {
+			timer := time.NewTimer(cfg.Scheduler.Duration)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+
 		// Wait for termination signal
-		<-sigChan
-		log.Info("received termination signal, shutting down gracefully...")
+		var runErr error
+		select {
+		case <-sigChan:
+			log.Info("received termination signal, shutting down gracefully...")
+		case <-deadline:
+			log.Info("scheduler duration elapsed, shutting down gracefully...", slog.Duration("duration", cfg.Scheduler.Duration))
+		case runErr = <-abortChan:
+			log.Error("aborting run", slog.String("error", runErr.Error()))
+		}
+		stopReplays()
+		replayErr := <-replayDone
+		if err := sched.Stop(); err != nil {
+			log.Error("failed to stop scheduler", slog.String("error", err.Error()))
+		}
+		if runErr == nil {
+			// No later batch carries the pending duplicates and held-back messages
+			runErr = drainDelivery(ctx, generators, log)
+		}
 
 		// Print statistics
 		stats := sched.GetStats()
@@ -192,15 +350,375 @@ func run(ctx context.Context, cfg *config.Config, log *slog.Logger, sigChan <-ch
 			slog.Uint64("successful", stats.SuccessCount),
 			slog.Uint64("failed", stats.ErrorCount),
 		)
+		logPayloadStats(generators, log)
+		reportVerification(ctx, verifier, log)
 
-		return nil
+		if runErr != nil {
+			return runErr
+		}
+		return replayErr
 	}
 
 	// Run once if scheduler is not enabled
 	log.Info("running in single-shot mode")
-	if err := taskFunc(ctx); err != nil {
+	err = taskFunc(ctx)
+	if err == nil {
+		// There is no later batch to carry duplicates and held-back messages
+		err = drainDelivery(ctx, generators, log)
+	}
+	logPayloadStats(generators, log)
+	if err != nil {
+		return err
+	}
+	reportVerification(ctx, verifier, log)
+
+	// Wait for replays to finish unless interrupted
+	select {
+	case err := <-replayDone:
 		return err
+	case <-sigChan:
+		log.Info("received termination signal, shutting down gracefully...")
+		stopReplays()
+		return <-replayDone
 	}
+}
 
+// drainDelivery sends all pending duplicates and held-back messages
+func drainDelivery(ctx context.Context, generators []payloadGenerator, log *slog.Logger) error {
+	for _, pg := range generators {
+		messages := pg.delivery.Drain()
+		if len(messages) == 0 {
+			continue
+		}
+		log.Info("sending pending duplicates and delayed messages",
+			slog.String("payload", pg.name),
+			slog.String("topic", pg.topic),
+			slog.Int("count", len(messages)),
+		)
+		if err := pg.out.SendMessages(ctx, pg.topic, messages); err != nil {
+			return fmt.Errorf("failed to send pending messages for %s: %w", pg.name, err)
+		}
+	}
 	return nil
 }
+
+// startVerifier starts the read-back verification consumer on the payload topics
+func startVerifier(ctx context.Context, cfg *config.Config, generators []payloadGenerator, log *slog.Logger) (*verify.Verifier, error) {
+	var topics []string
+	for _, pg := range generators {
+		if !slices.Contains(topics, pg.topic) {
+			topics = append(topics, pg.topic)
+		}
+	}
+
+	verifier, err := verify.NewVerifier(cfg.Verify, &cfg.Kafka, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verifier: %w", err)
+	}
+	if err := verifier.Start(ctx, topics); err != nil {
+		return nil, fmt.Errorf("failed to start verifier: %w", err)
+	}
+	return verifier, nil
+}
+
+// reportVerification waits for outstanding messages and logs the
+// verification report
+func reportVerification(ctx context.Context, verifier *verify.Verifier, log *slog.Logger) {
+	if verifier == nil {
+		return
+	}
+
+	log.Info("waiting for sent messages to be consumed")
+	report := verifier.Wait(ctx)
+	attrs := []any{
+		slog.Uint64("sent", report.Sent),
+		slog.Uint64("received", report.Received),
+		slog.Uint64("lost", report.Lost),
+		slog.Uint64("duplicated", report.Duplicated),
+		slog.Uint64("out_of_order", report.OutOfOrder),
+		slog.Duration("latency_p50", report.Latency.P50),
+		slog.Duration("latency_p95", report.Latency.P95),
+		slog.Duration("latency_p99", report.Latency.P99),
+		slog.Duration("latency_max", report.Latency.Max),
+	}
+	if report.Lost > 0 {
+		log.Warn("verification report", attrs...)
+		return
+	}
+	log.Info("verification report", attrs...)
+}
+
+// logPayloadStats logs lifecycle and validation statistics of the payloads
+func logPayloadStats(generators []payloadGenerator, log *slog.Logger) {
+	for _, pg := range generators {
+		if pg.lifecycle != nil {
+			lcStats := pg.lifecycle.Stats()
+			log.Info("lifecycle statistics",
+				slog.String("payload", pg.name),
+				slog.Uint64("created", lcStats.Created),
+				slog.Uint64("completed", lcStats.Completed),
+				slog.Int("active", lcStats.Active),
+			)
+		}
+		if pg.validator != nil {
+			log.Info("validation statistics",
+				slog.String("payload", pg.name),
+				slog.Uint64("violations", pg.validator.violations.Load()),
+				slog.String("on_violation", pg.validator.action),
+			)
+		}
+		if pg.faults != nil {
+			attrs := []any{
+				slog.String("payload", pg.name),
+				slog.Uint64("total", pg.faults.Total()),
+			}
+			stats := pg.faults.Stats()
+			for _, name := range config.FaultTypes {
+				if count, ok := stats[name]; ok {
+					attrs = append(attrs, slog.Uint64(name, count))
+				}
+			}
+			log.Info("fault injection statistics", attrs...)
+		}
+		if pg.delivery != nil {
+			dStats := pg.delivery.Stats()
+			log.Info("delivery simulation statistics",
+				slog.String("payload", pg.name),
+				slog.Uint64("duplicates", dStats.Duplicates),
+				slog.Uint64("reordered", dStats.Reordered),
+				slog.Uint64("delayed", dStats.Delayed),
+				slog.Uint64("evicted", dStats.Evicted),
+				slog.Int("pending", dStats.Pending),
+			)
+		}
+	}
+}
+
+// logSinkStats logs the messages accepted by the output sink and their rate
+func logSinkStats(out sink.Sink, elapsed time.Duration, log *slog.Logger) {
+	stats := out.Stats()
+	log.Info("output statistics",
+		slog.Uint64("messages", stats.Messages),
+		slog.Uint64("batches", stats.Batches),
+		slog.Uint64("bytes", stats.Bytes),
+		slog.Uint64("failed_batches", stats.Errors),
+		slog.Float64("messages_per_second", float64(stats.Messages)/elapsed.Seconds()),
+	)
+}
+
+// logDeliveryStats logs the broker acknowledged, failed and aborted messages
+// of every payload, replayed messages are counted under their topic
+func logDeliveryStats(producer *kafka.Producer, log *slog.Logger) {
+	stats := producer.DeliveryStats()
+	sources := make([]string, 0, len(stats))
+	for source := range stats {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		s := stats[source]
+		log.Info("delivery statistics",
+			slog.String("source", source),
+			slog.Uint64("delivered", s.Delivered),
+			slog.Uint64("failed", s.Failed),
+			slog.Uint64("aborted", s.Aborted),
+			slog.Duration("latency_p50", s.P50),
+			slog.Duration("latency_p95", s.P95),
+			slog.Duration("latency_p99", s.P99),
+			slog.Duration("latency_max", s.Max),
+		)
+	}
+	if txn, ok := producer.TransactionStats(); ok {
+		log.Info("transaction statistics",
+			slog.Uint64("committed", txn.Committed),
+			slog.Uint64("aborted", txn.Aborted),
+			slog.Uint64("failed", txn.Failed),
+		)
+	}
+}
+
+// startReplays runs a replay player per payload in the background.
+// The returned channel yields the first replay error, or nil, once all
+// players have finished.
+func startReplays(ctx context.Context, payloads []config.PayloadConfig, outs *outputs, pools map[string]*template.Pool, log *slog.Logger) (<-chan error, error) {
+	players := make([]*replay.Player, len(payloads))
+	for i, payloadCfg := range payloads {
+		var gen *template.Generator
+		if len(payloadCfg.Replay.Set) > 0 {
+			var err error
+			gen, err = template.New(&template.Template{}, template.WithPools(pools))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create replay generator for %s: %w", payloadCfg.Name, err)
+			}
+		}
+
+		player, err := replay.NewPlayer(payloadCfg.Replay, payloadCfg.Topic, payloadCfg.BatchSize, outs.route(payloadCfg.Cluster), gen, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create replay player for %s: %w", payloadCfg.Name, err)
+		}
+		players[i] = player
+		log.Info("replay player initialized",
+			slog.String("name", payloadCfg.Name),
+			slog.String("path", payloadCfg.Replay.Path),
+			slog.String("mode", payloadCfg.Replay.Mode),
+			slog.String("topic", payloadCfg.Topic),
+		)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var wg sync.WaitGroup
+		errChan := make(chan error, len(players))
+		for i, player := range players {
+			wg.Add(1)
+			go func(name string, player *replay.Player) {
+				defer wg.Done()
+				err := player.Run(ctx)
+				stats := player.Stats()
+				log.Info("replay statistics",
+					slog.String("payload", name),
+					slog.Uint64("records", stats.Records),
+					slog.Uint64("batches", stats.Batches),
+					slog.Uint64("passes", stats.Passes),
+				)
+				if err != nil {
+					errChan <- fmt.Errorf("replay failed for %s: %w", name, err)
+				}
+			}(payloads[i].Name, player)
+		}
+		wg.Wait()
+		close(errChan)
+		done <- <-errChan
+	}()
+
+	return done, nil
+}
+
+// buildPools initializes the entity pools shared between payloads
+func buildPools(cfg *config.Config, log *slog.Logger) (map[string]*template.Pool, error) {
+	pools := make(map[string]*template.Pool, len(cfg.Pools))
+	for name, poolCfg := range cfg.Pools {
+		pool, err := template.NewPoolWithSource(name, &poolCfg, seededSource(cfg, "pool:"+name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create entity pool %s: %w", name, err)
+		}
+		pools[name] = pool
+		log.Info("entity pool initialized",
+			slog.String("name", name),
+			slog.Int("size", pool.Size()),
+			slog.String("selection", poolCfg.Selection),
+		)
+	}
+
+	return pools, nil
+}
+
+// seededSource returns the random source of a pool or payload, or nil to
+// draw from crypto/rand when no seed is configured
+func seededSource(cfg *config.Config, stream string) *template.Source {
+	if cfg.Seed == nil {
+		return nil
+	}
+	return template.NewSource(*cfg.Seed, stream)
+}
+
+// buildGenerators creates the generator of every payload. Replay payloads
+// publish recorded messages instead and are returned separately. codecOpts
+// configure the encoders, e.g. codec.Offline for commands that must not
+// reach a schema registry.
+func buildGenerators(cfg *config.Config, pools map[string]*template.Pool, log *slog.Logger, codecOpts ...codec.Option) ([]payloadGenerator, []config.PayloadConfig, error) {
+	var generators []payloadGenerator
+	var replays []config.PayloadConfig
+	for _, payloadCfg := range cfg.Payloads {
+		// Replay payloads publish recorded messages instead of a template
+		if payloadCfg.Replay != nil {
+			replays = append(replays, payloadCfg)
+			continue
+		}
+
+		pg := payloadGenerator{
+			name:      payloadCfg.Name,
+			batchSize: payloadCfg.BatchSize,
+			topic:     payloadCfg.Topic,
+			clusters:  payloadCfg.Cluster,
+		}
+		encoder, err := codec.New(&payloadCfg, codecOpts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create %s encoder for %s: %w", payloadCfg.Format, payloadCfg.Name, err)
+		}
+		pg.encoder = encoder
+		if payloadCfg.SchemaPath != "" {
+			schema, err := jsonschema.NewValidator(payloadCfg.SchemaPath)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load validation schema for %s: %w", payloadCfg.Name, err)
+			}
+			pg.validator = &messageValidator{schema: schema, action: payloadCfg.OnViolation}
+		}
+
+		if payloadCfg.Faults != nil {
+			injector, err := faults.NewInjector(payloadCfg.Faults)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create fault injector for %s: %w", payloadCfg.Name, err)
+			}
+			pg.faults = injector
+		}
+
+		if payloadCfg.Delivery != nil {
+			sim, err := delivery.NewSimulator(payloadCfg.Delivery)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create delivery simulator for %s: %w", payloadCfg.Name, err)
+			}
+			pg.delivery = sim
+		}
+
+		// Schema payloads generate conforming documents instead of rendering a template
+		if payloadCfg.JSONSchema != nil {
+			resolver, err := template.New(&template.Template{}, template.WithPools(pools))
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create override resolver for %s: %w", payloadCfg.Name, err)
+			}
+			gen, err := jsonschema.NewGenerator(payloadCfg.JSONSchema, resolver)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create JSON schema generator for %s: %w", payloadCfg.Name, err)
+			}
+			pg.generator = gen
+			generators = append(generators, pg)
+			log.Info("JSON schema generator initialized",
+				slog.String("name", payloadCfg.Name),
+				slog.String("path", payloadCfg.JSONSchema.Path),
+				slog.Int("batch_size", payloadCfg.BatchSize),
+				slog.String("topic", payloadCfg.Topic),
+			)
+			continue
+		}
+
+		gen, err := template.NewGenerator(payloadCfg.TemplatePath, template.WithPools(pools), template.WithSource(seededSource(cfg, "payload:"+payloadCfg.Name)))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create template generator for %s: %w", payloadCfg.Name, err)
+		}
+		if encoder != nil && gen.Format() != template.FormatJSON {
+			return nil, nil, fmt.Errorf("payload %s: %s encoding requires a json template, got %s", payloadCfg.Name, payloadCfg.Format, gen.Format())
+		}
+		if pg.validator != nil && gen.Format() != template.FormatJSON {
+			return nil, nil, fmt.Errorf("payload %s: schema_path requires a json template, got %s", payloadCfg.Name, gen.Format())
+		}
+		pg.generator = gen
+		if payloadCfg.Lifecycle != nil {
+			engine, err := lifecycle.NewEngine(payloadCfg.Lifecycle, gen)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to create lifecycle engine for %s: %w", payloadCfg.Name, err)
+			}
+			pg.lifecycle = engine
+		}
+		generators = append(generators, pg)
+		log.Info("template generator initialized",
+			slog.String("name", payloadCfg.Name),
+			slog.String("path", payloadCfg.TemplatePath),
+			slog.Int("batch_size", payloadCfg.BatchSize),
+			slog.String("topic", payloadCfg.Topic),
+		)
+	}
+
+	return generators, replays, nil
+}
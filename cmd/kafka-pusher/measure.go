@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/logger"
+	"github.com/alexermolov/go-kafka-pusher/internal/measure"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// runMeasure implements the measure command: it consumes topics from their
+// current end and reports the latency of messages stamped by the producer.
+// It returns the process exit code.
+func runMeasure(args []string) int {
+	fs, cfgFlags := newFlagSet("measure")
+	topics := fs.String("topic", "", "comma-separated topics to consume (required)")
+	interval := fs.Duration("interval", 10*time.Second, "reporting interval")
+	duration := fs.Duration("duration", 0, "how long to measure, 0 runs until interrupted")
+	cluster := fs.String("cluster", config.DefaultCluster, "cluster to consume from")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *topics == "" {
+		fmt.Fprintln(os.Stderr, "-topic is required")
+		return exitUsage
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "-interval must be positive")
+		return exitUsage
+	}
+
+	cfg, ok := loadConfig(cfgFlags)
+	if !ok {
+		return exitConfig
+	}
+	log := logger.New(&cfg.Logging)
+	kafkaCfg := cfg.Cluster(*cluster)
+	if kafkaCfg == nil {
+		fmt.Fprintf(os.Stderr, "cluster %s is not configured\n", *cluster)
+		return exitUsage
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if *duration > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, *duration)
+		defer stop()
+	}
+
+	consumer, err := kafka.NewConsumer(kafkaCfg, log)
+	if err != nil {
+		log.Error("failed to create kafka consumer", slog.String("error", err.Error()))
+		return exitFailure
+	}
+
+	recorder := measure.NewRecorder(kafkaCfg.Stamp, time.Now())
+	handle := func(msg kafkago.Message) {
+		recorder.Observe(msg, time.Now())
+	}
+	if err := consumer.Start(ctx, strings.Split(*topics, ","), handle); err != nil {
+		log.Error("failed to start kafka consumer", slog.String("error", err.Error()))
+		return exitFailure
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+measuring:
+	for {
+		select {
+		case <-ctx.Done():
+			break measuring
+		case now := <-ticker.C:
+			logSnapshot(log, "latency window", recorder.Window(now))
+		}
+	}
+
+	if err := consumer.Close(); err != nil {
+		log.Error("failed to close kafka consumer", slog.String("error", err.Error()))
+	}
+	logSnapshot(log, "latency summary", recorder.Total(time.Now()))
+	return exitOK
+}
+
+// logSnapshot logs latency statistics
+func logSnapshot(log *slog.Logger, msg string, s measure.Snapshot) {
+	log.Info(msg,
+		slog.Uint64("count", s.Count),
+		slog.Float64("msg_per_sec", s.Throughput),
+		slog.Duration("p50", s.P50),
+		slog.Duration("p95", s.P95),
+		slog.Duration("p99", s.P99),
+		slog.Duration("max", s.Max),
+		slog.Uint64("reordered", s.Reordered),
+		slog.Uint64("unstamped", s.Unstamped),
+	)
+}
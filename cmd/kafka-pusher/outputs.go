@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/sink"
+)
+
+// output is the sink of one Kafka cluster, or the configured sink
+type output struct {
+	cluster  string // empty for sinks other than Kafka
+	sink     sink.Sink
+	producer *kafka.Producer // nil for sinks other than Kafka
+	tick     bool            // one transaction per scheduler execution
+	log      *slog.Logger
+}
+
+// outputs holds the sinks messages are routed to
+type outputs struct {
+	list    []*output // in cluster name order
+	started time.Time
+}
+
+// openOutputs opens one producer per Kafka cluster used by the payloads,
+// after checking each cluster and its topics. Sinks other than Kafka
+// receive the messages of every cluster.
+func openOutputs(ctx context.Context, cfg *config.Config, log *slog.Logger) (*outputs, error) {
+	o := &outputs{started: time.Now()}
+	if !cfg.Sink.UsesKafka() {
+		out, err := sink.New(cfg.Sink, &cfg.Kafka, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create output sink: %w", err)
+		}
+		o.list = append(o.list, &output{sink: out, log: log})
+		log.Info("output sink initialized", slog.String("type", cfg.Sink.Type))
+		return o, nil
+	}
+
+	topics := clusterTopics(cfg)
+	clusters := make([]string, 0, len(topics))
+	for cluster := range topics {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+
+	for _, cluster := range clusters {
+		kafkaCfg := cfg.Cluster(cluster)
+		// Name the cluster in logs once there is more than one
+		clusterLog := log
+		if len(cfg.Clusters) > 0 {
+			clusterLog = log.With(slog.String("cluster", cluster))
+		}
+
+		// Check the cluster and topics before any load begins
+		if !kafkaCfg.SkipPreflight {
+			if err := kafka.Preflight(ctx, kafkaCfg, topics[cluster], clusterLog); err != nil {
+				o.close()
+				return nil, fmt.Errorf("cluster %s: %w", cluster, err)
+			}
+		}
+
+		producer, err := sink.NewKafka(kafkaCfg, clusterLog)
+		if err != nil {
+			o.close()
+			return nil, fmt.Errorf("failed to create kafka producer for cluster %s: %w", cluster, err)
+		}
+		o.list = append(o.list, &output{
+			cluster:  cluster,
+			sink:     producer,
+			producer: producer.Producer(),
+			tick:     kafkaCfg.Transaction != nil && kafkaCfg.Transaction.Scope == "tick",
+			log:      clusterLog,
+		})
+		clusterLog.Info("kafka producer initialized",
+			slog.Any("brokers", kafkaCfg.Brokers),
+		)
+	}
+	return o, nil
+}
+
+// route returns the sink for a payload sent to the given clusters
+func (o *outputs) route(clusters config.ClusterNames) sink.Sink {
+	if len(o.list) == 1 && o.list[0].producer == nil {
+		return o.list[0].sink
+	}
+
+	var sinks sink.Fanout
+	for _, out := range o.list {
+		if slices.Contains(clusters.OrDefault(), out.cluster) {
+			sinks = append(sinks, out.sink)
+		}
+	}
+	if len(sinks) == 1 {
+		return sinks[0]
+	}
+	return sinks
+}
+
+// transactional reports whether a cluster uses one transaction per
+// scheduler execution
+func (o *outputs) transactional() bool {
+	return slices.ContainsFunc(o.list, func(out *output) bool { return out.tick })
+}
+
+// beginTransaction opens a transaction on every cluster in tick scope
+func (o *outputs) beginTransaction() {
+	for _, out := range o.list {
+		if out.tick {
+			out.producer.BeginTransaction()
+		}
+	}
+}
+
+// endTransaction commits or aborts the transactions opened by
+// beginTransaction
+func (o *outputs) endTransaction(ctx context.Context, err error) error {
+	var errs []error
+	for _, out := range o.list {
+		if !out.tick {
+			continue
+		}
+		if endErr := out.producer.EndTransaction(ctx, err); endErr != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", out.cluster, endErr))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// close closes every sink and logs its statistics. Closing flushes async
+// writes, so delivery results are final afterwards.
+func (o *outputs) close() {
+	elapsed := time.Since(o.started)
+	for _, out := range o.list {
+		if err := out.sink.Close(); err != nil {
+			out.log.Error("failed to close output sink", slog.String("error", err.Error()))
+		}
+		logSinkStats(out.sink, elapsed, out.log)
+		if out.producer != nil {
+			logDeliveryStats(out.producer, out.log)
+		}
+	}
+}
+
+// clusterTopics returns the distinct topics of the payloads sent to each
+// cluster
+func clusterTopics(cfg *config.Config) map[string][]string {
+	topics := make(map[string][]string)
+	for _, payload := range cfg.Payloads {
+		for _, cluster := range payload.Cluster.OrDefault() {
+			if !slices.Contains(topics[cluster], payload.Topic) {
+				topics[cluster] = append(topics[cluster], payload.Topic)
+			}
+		}
+	}
+	return topics
+}
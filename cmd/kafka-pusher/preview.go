@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/codec"
+	"github.com/alexermolov/go-kafka-pusher/internal/sink"
+)
+
+// runPreview implements the preview command: it prints generated messages of
+// every payload as JSON records with their topic, key and headers, without
+// connecting to Kafka. It returns the process exit code.
+func runPreview(args []string) int {
+	fs, cfgFlags := newFlagSet("preview")
+	count := fs.Int("n", 5, "number of messages per payload")
+	payload := fs.String("payload", "", "only preview the named payload")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *count < 1 {
+		fmt.Fprintln(os.Stderr, "-n must be at least 1")
+		return exitUsage
+	}
+
+	cfg, ok := loadConfig(cfgFlags)
+	if !ok {
+		return exitConfig
+	}
+	log := problemLogger()
+
+	pools, err := buildPools(cfg, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfig
+	}
+	generators, _, err := buildGenerators(cfg, pools, log, codec.Offline())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfig
+	}
+
+	out := sink.NewStdout()
+	defer out.Close()
+
+	found := false
+	for _, pg := range generators {
+		if *payload != "" && pg.name != *payload {
+			continue
+		}
+		found = true
+
+		messages, err := pg.nextBatch(*count, nil, false, log)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitFailure
+		}
+		if err := out.SendMessages(context.Background(), pg.topic, messages); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitFailure
+		}
+	}
+
+	if *payload != "" && !found {
+		fmt.Fprintf(os.Stderr, "payload %s not found, replay payloads cannot be previewed\n", *payload)
+		return exitUsage
+	}
+	return exitOK
+}
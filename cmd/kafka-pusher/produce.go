@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/logger"
+	"github.com/alexermolov/go-kafka-pusher/internal/pipe"
+	"github.com/alexermolov/go-kafka-pusher/internal/record"
+	"github.com/alexermolov/go-kafka-pusher/internal/template"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// headerFlags collects repeated -header key=value flags
+type headerFlags []kafkago.Header
+
+// String implements flag.Value
+func (h *headerFlags) String() string {
+	pairs := make([]string, len(*h))
+	for i, header := range *h {
+		pairs[i] = header.Key + "=" + string(header.Value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value
+func (h *headerFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("header must be key=value")
+	}
+	*h = append(*h, kafkago.Header{Key: key, Value: []byte(val)})
+	return nil
+}
+
+// runProduce implements the produce command: it sends the message given as
+// argument, or every line of standard input or a file, to a topic. It
+// returns the process exit code.
+func runProduce(args []string) int {
+	fs, cfgFlags := newFlagSet("produce")
+	topic := fs.String("topic", "", "topic to send to (required)")
+	key := fs.String("key", "", "key of messages whose line has none")
+	cluster := fs.String("cluster", config.DefaultCluster, "cluster to send to")
+	input := fs.String("input", "-", "file to read lines from, - for standard input")
+	format := fs.String("format", pipe.FormatRaw, "line format: raw, tsv (key<TAB>value) or json (envelope with key, headers and value)")
+	batchSize := fs.Int("batch", 100, "lines sent per batch")
+	rate := fs.Float64("rate", 0, "messages per second, 0 for as fast as possible")
+	expand := fs.Bool("template", false, "expand directives such as {{@uuid}} in keys, values and headers")
+	var headers headerFlags
+	fs.Var(&headers, "header", "message header as key=value, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *topic == "" {
+		fmt.Fprintln(os.Stderr, "-topic is required")
+		return exitUsage
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "at most one message may be given, quote it or use standard input")
+		return exitUsage
+	}
+	if fs.NArg() == 1 && *input != "-" {
+		fmt.Fprintln(os.Stderr, "a message argument cannot be combined with -input")
+		return exitUsage
+	}
+	if *batchSize < 1 || *rate < 0 {
+		fmt.Fprintln(os.Stderr, "-batch must be at least 1 and -rate must not be negative")
+		return exitUsage
+	}
+	switch *format {
+	case pipe.FormatRaw, pipe.FormatTSV, pipe.FormatJSON:
+	default:
+		fmt.Fprintln(os.Stderr, "-format must be raw, tsv or json")
+		return exitUsage
+	}
+
+	cfg, ok := loadConfig(cfgFlags)
+	if !ok {
+		return exitConfig
+	}
+	kafkaCfg := cfg.Cluster(*cluster)
+	if kafkaCfg == nil || len(kafkaCfg.Brokers) == 0 {
+		fmt.Fprintf(os.Stderr, "cluster %s has no brokers configured\n", *cluster)
+		return exitConfig
+	}
+	log := logger.New(&cfg.Logging)
+
+	// Directives may reference the configured entity pools
+	var gen *template.Generator
+	if *expand {
+		pools, err := buildPools(cfg, log)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return exitConfig
+		}
+		gen, err = template.New(&template.Template{}, template.WithPools(pools))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to create directive resolver: %v\n", err)
+			return exitConfig
+		}
+	}
+
+	source := os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to open input: %v\n", err)
+			return exitUsage
+		}
+		defer f.Close()
+		source = f
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	producer, err := kafka.NewProducer(kafkaCfg, log)
+	if err != nil {
+		log.Error("failed to create kafka producer", slog.String("error", err.Error()))
+		return exitFailure
+	}
+	defer func() {
+		if err := producer.Close(); err != nil {
+			log.Error("failed to close producer", slog.String("error", err.Error()))
+		}
+	}()
+
+	p, err := pipe.New(pipe.Options{
+		Topic:     *topic,
+		Format:    *format,
+		BatchSize: *batchSize,
+		Rate:      *rate,
+		Key:       []byte(*key),
+		Headers:   headers,
+	}, producer, gen)
+	if err != nil {
+		log.Error("failed to create pipe", slog.String("error", err.Error()))
+		return exitFailure
+	}
+
+	// A literal argument is the message value, whatever the line format
+	if fs.NArg() == 1 {
+		msg, err := p.Message(&record.Record{Value: []byte(fs.Arg(0))})
+		if err != nil {
+			log.Error("failed to build message", slog.String("error", err.Error()))
+			return exitFailure
+		}
+		if err := producer.SendMessages(ctx, *topic, []kafka.Message{msg}); err != nil {
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	err = p.Run(ctx, source)
+	stats := p.Stats()
+	log.Info("input sent",
+		slog.String("topic", *topic),
+		slog.String("input", *input),
+		slog.Uint64("count", stats.Records),
+		slog.Uint64("batches", stats.Batches),
+	)
+	if err != nil {
+		log.Error("failed to send input", slog.String("error", err.Error()))
+		return exitFailure
+	}
+	return exitOK
+}
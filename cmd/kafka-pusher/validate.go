@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync/atomic"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/codec"
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/jsonschema"
+	"github.com/alexermolov/go-kafka-pusher/internal/template"
+)
+
+// errSchemaViolation marks violations that abort the run
+var errSchemaViolation = errors.New("schema violation")
+
+// maxReportedViolations limits how many violations the validate command prints per payload
+const maxReportedViolations = 5
+
+// messageValidator checks generated messages against a payload's schema
+type messageValidator struct {
+	schema     *jsonschema.Validator
+	action     string
+	violations atomic.Uint64
+}
+
+// check validates a message and reports whether it should be sent.
+// A nil validator accepts every message. Violations return an error only
+// when the action is abort.
+func (v *messageValidator) check(payload string, message []byte, log *slog.Logger) (bool, error) {
+	if v == nil {
+		return true, nil
+	}
+
+	err := v.schema.Validate(message)
+	if err == nil {
+		return true, nil
+	}
+	v.violations.Add(1)
+
+	switch v.action {
+	case "abort":
+		return false, fmt.Errorf("%w in %s: %v", errSchemaViolation, payload, err)
+	case "log":
+		log.Warn("sending message that violates schema",
+			slog.String("payload", payload),
+			slog.String("error", err.Error()),
+		)
+		return true, nil
+	default:
+		log.Debug("dropping message that violates schema",
+			slog.String("payload", payload),
+			slog.String("error", err.Error()),
+		)
+		return false, nil
+	}
+}
+
+// checkFiles parses the template of every payload and checks that the other
+// files the configuration reads exist, so that loading reports them with the
+// rest of its problems
+func checkFiles(cfg *config.Config) error {
+	var p config.Problems
+	exists := func(field, path string) {
+		if path == "" {
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			p = append(p, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	checkTLS(cfg.Kafka.TLS, "kafka", exists)
+	var clusters []string
+	for name, cluster := range cfg.Clusters {
+		if cluster != nil && cluster.TLS != nil {
+			clusters = append(clusters, name)
+		}
+	}
+	sort.Strings(clusters)
+	for _, name := range clusters {
+		checkTLS(cfg.Clusters[name].TLS, "clusters."+name, exists)
+	}
+
+	// Pools of one entity are enough for parsing templates
+	pools := make(map[string]*template.Pool, len(cfg.Pools))
+	for name, poolCfg := range cfg.Pools {
+		poolCfg.Size = 1
+		pool, err := template.NewPool(name, &poolCfg)
+		if err != nil {
+			p = append(p, fmt.Errorf("pools.%s: %w", name, err))
+			continue
+		}
+		pools[name] = pool
+	}
+
+	for i, payload := range cfg.Payloads {
+		field := fmt.Sprintf("payloads[%d]", i)
+		if payload.Replay != nil {
+			exists(field+".replay.path", payload.Replay.Path)
+			continue
+		}
+		if payload.TemplatePath != "" {
+			if _, err := template.NewGenerator(payload.TemplatePath, template.WithPools(pools)); err != nil {
+				p = append(p, fmt.Errorf("%s.template_path: %w", field, err))
+			}
+		}
+		if payload.JSONSchema != nil {
+			exists(field+".json_schema.path", payload.JSONSchema.Path)
+		}
+		exists(field+".schema_path", payload.SchemaPath)
+		if payload.Avro != nil {
+			exists(field+".avro.schema_path", payload.Avro.SchemaPath)
+		}
+		if payload.Protobuf != nil {
+			exists(field+".protobuf.proto_path", payload.Protobuf.ProtoPath)
+			exists(field+".protobuf.descriptor_set_path", payload.Protobuf.DescriptorSetPath)
+		}
+	}
+
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
+
+// checkTLS checks that the certificate files of a TLS section exist
+func checkTLS(tls *config.TLSConfig, section string, exists func(field, path string)) {
+	if tls == nil || !tls.Enabled {
+		return
+	}
+	exists(section+".tls.ca_file", tls.CAFile)
+	exists(section+".tls.cert_file", tls.CertFile)
+	exists(section+".tls.key_file", tls.KeyFile)
+}
+
+// runValidate implements the validate command: it generates sample messages
+// for every payload and checks them against their schema without connecting
+// to Kafka. It returns the process exit code.
+func runValidate(args []string) int {
+	fs, cfgFlags := newFlagSet("validate")
+	count := fs.Int("n", 10, "number of sample messages per payload")
+	showSample := fs.Bool("sample", false, "print the first rendered message of every payload")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *count < 1 {
+		fmt.Fprintln(os.Stderr, "-n must be at least 1")
+		return exitUsage
+	}
+
+	// Other commands find missing files when they build their generators
+	cfgFlags.check = checkFiles
+	cfg, ok := loadConfig(cfgFlags)
+	if !ok {
+		return exitConfig
+	}
+
+	// Only problems are logged, the summary goes to stdout
+	log := problemLogger()
+
+	pools, err := buildPools(cfg, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfig
+	}
+	generators, replays, err := buildGenerators(cfg, pools, log, codec.Offline())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return exitConfig
+	}
+
+	failed := false
+	for _, pg := range generators {
+		sample, violations, err := validateSamples(&pg, *count)
+		if err != nil {
+			fmt.Printf("%s: FAILED: %v\n", pg.name, err)
+			failed = true
+			continue
+		}
+
+		switch {
+		case pg.validator == nil:
+			fmt.Printf("%s: %d messages generated, no schema_path configured\n", pg.name, *count)
+		case len(violations) == 0:
+			fmt.Printf("%s: %d messages valid\n", pg.name, *count)
+		default:
+			failed = true
+			fmt.Printf("%s: %d of %d messages violate the schema\n", pg.name, len(violations), *count)
+			for i, v := range violations {
+				if i == maxReportedViolations {
+					fmt.Printf("  ... and %d more\n", len(violations)-maxReportedViolations)
+					break
+				}
+				fmt.Printf("  - %v\n", v)
+			}
+		}
+		if *showSample && sample != nil {
+			fmt.Printf("  sample: %s\n", sample)
+		}
+	}
+	for _, payloadCfg := range replays {
+		fmt.Printf("%s: skipped, replay payload\n", payloadCfg.Name)
+	}
+
+	if failed {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// validateSamples generates n messages, returning the first rendered
+// message and the schema violations. Generation and encoding failures are
+// returned as an error.
+func validateSamples(pg *payloadGenerator, n int) ([]byte, []error, error) {
+	var samples [][]byte
+	if pg.lifecycle != nil {
+		events, err := pg.lifecycle.Next(n)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to advance lifecycle: %w", err)
+		}
+		for _, event := range events {
+			samples = append(samples, event.Value)
+		}
+	} else {
+		for i := 0; i < n; i++ {
+			message, err := pg.generator.Generate()
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to generate message %d: %w", i, err)
+			}
+			samples = append(samples, message)
+		}
+	}
+
+	var violations []error
+	for i, message := range samples {
+		if pg.validator != nil {
+			if err := pg.validator.schema.Validate(message); err != nil {
+				violations = append(violations, fmt.Errorf("message %d: %w", i, err))
+				continue
+			}
+		}
+		if _, err := pg.encode(message); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode message %d: %w", i, err)
+		}
+	}
+
+	var sample []byte
+	if len(samples) > 0 {
+		sample = samples[0]
+	}
+	return sample, violations, nil
+}
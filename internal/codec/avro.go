@@ -0,0 +1,538 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// avroSchema is a parsed Avro schema node
+type avroSchema struct {
+	typ      string // primitive name, record, enum, array, map, fixed or union
+	name     string // full name of named types
+	logical  string
+	fields   []avroField
+	symbols  []string
+	items    *avroSchema
+	values   *avroSchema
+	size     int
+	branches []*avroSchema
+}
+
+// avroField is a single record field
+type avroField struct {
+	name       string
+	schema     *avroSchema
+	defaultVal json.RawMessage
+}
+
+// AvroEncoder encodes rendered JSON messages as Avro binary.
+//
+// Conversion from JSON is lenient so that templates, which render most values
+// as strings, can be used unchanged: numeric and boolean strings are accepted
+// for numeric and boolean fields, RFC 3339 strings for timestamp logical
+// types, and union values may be given either bare or wrapped as
+// {"type": value}. Missing record fields take their default or null.
+type AvroEncoder struct {
+	schema   *avroSchema
+	schemaID int
+}
+
+// NewAvroEncoder parses an .avsc schema. When schemaID is positive messages
+// are prefixed with the Confluent wire format header.
+func NewAvroEncoder(schema []byte, schemaID int) (*AvroEncoder, error) {
+	var raw interface{}
+	if err := json.Unmarshal(schema, &raw); err != nil {
+		return nil, fmt.Errorf("invalid avro schema JSON: %w", err)
+	}
+
+	p := &avroParser{named: make(map[string]*avroSchema)}
+	s, err := p.parse(raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+
+	return &AvroEncoder{schema: s, schemaID: schemaID}, nil
+}
+
+// Encode converts a JSON message into Avro binary
+func (e *AvroEncoder) Encode(value []byte) ([]byte, error) {
+	v, err := decodeJSON(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if e.schemaID > 0 {
+		buf.Write(wireHeader(e.schemaID))
+	}
+	if err := writeAvro(&buf, e.schema, v, ""); err != nil {
+		return nil, fmt.Errorf("avro encoding failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// avroParser resolves named type references while parsing
+type avroParser struct {
+	named map[string]*avroSchema
+}
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+func (p *avroParser) parse(raw interface{}, namespace string) (*avroSchema, error) {
+	switch v := raw.(type) {
+	case string:
+		if avroPrimitives[v] {
+			return &avroSchema{typ: v}, nil
+		}
+		if s, ok := p.named[fullName(v, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := p.named[v]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", v)
+
+	case []interface{}:
+		union := &avroSchema{typ: "union"}
+		for _, branch := range v {
+			s, err := p.parse(branch, namespace)
+			if err != nil {
+				return nil, err
+			}
+			union.branches = append(union.branches, s)
+		}
+		return union, nil
+
+	case map[string]interface{}:
+		return p.parseComplex(v, namespace)
+
+	default:
+		return nil, fmt.Errorf("unexpected schema element %v", raw)
+	}
+}
+
+func (p *avroParser) parseComplex(m map[string]interface{}, namespace string) (*avroSchema, error) {
+	typ, _ := m["type"].(string)
+	logical, _ := m["logicalType"].(string)
+
+	if ns, ok := m["namespace"].(string); ok {
+		namespace = ns
+	}
+
+	switch typ {
+	case "record", "error", "enum", "fixed":
+		name, _ := m["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("%s requires a name", typ)
+		}
+		full := fullName(name, namespace)
+		if i := strings.LastIndex(full, "."); i >= 0 {
+			namespace = full[:i]
+		}
+
+		s := &avroSchema{typ: typ, name: full, logical: logical}
+		if typ == "error" {
+			s.typ = "record"
+		}
+		// Register before parsing fields to allow recursive references
+		p.named[full] = s
+
+		switch s.typ {
+		case "record":
+			fields, _ := m["fields"].([]interface{})
+			for _, f := range fields {
+				fm, ok := f.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("record %s: invalid field", full)
+				}
+				fname, _ := fm["name"].(string)
+				fs, err := p.parse(fm["type"], namespace)
+				if err != nil {
+					return nil, fmt.Errorf("record %s field %s: %w", full, fname, err)
+				}
+				field := avroField{name: fname, schema: fs}
+				if d, ok := fm["default"]; ok {
+					field.defaultVal, _ = json.Marshal(d)
+				}
+				s.fields = append(s.fields, field)
+			}
+		case "enum":
+			symbols, _ := m["symbols"].([]interface{})
+			for _, sym := range symbols {
+				str, _ := sym.(string)
+				s.symbols = append(s.symbols, str)
+			}
+		case "fixed":
+			size, _ := m["size"].(float64)
+			s.size = int(size)
+		}
+		return s, nil
+
+	case "array":
+		items, err := p.parse(m["items"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("array items: %w", err)
+		}
+		return &avroSchema{typ: "array", items: items}, nil
+
+	case "map":
+		values, err := p.parse(m["values"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("map values: %w", err)
+		}
+		return &avroSchema{typ: "map", values: values}, nil
+
+	default:
+		// Primitive with attributes, e.g. {"type": "long", "logicalType": "timestamp-millis"}
+		s, err := p.parse(m["type"], namespace)
+		if err != nil {
+			return nil, err
+		}
+		if logical == "" || s.name != "" {
+			return s, nil
+		}
+		annotated := *s
+		annotated.logical = logical
+		return &annotated, nil
+	}
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// writeAvro encodes v according to schema s; path is used in error messages
+func writeAvro(buf *bytes.Buffer, s *avroSchema, v interface{}, path string) error {
+	switch s.typ {
+	case "null":
+		if v != nil {
+			return fmt.Errorf("%s: expected null, got %v", fieldPath(path), v)
+		}
+		return nil
+
+	case "boolean":
+		b, err := toBool(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fieldPath(path), err)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+		return nil
+
+	case "int", "long":
+		n, err := toAvroInt(v, s.logical)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fieldPath(path), err)
+		}
+		if s.typ == "int" && (n < math.MinInt32 || n > math.MaxInt32) {
+			return fmt.Errorf("%s: value %d overflows int", fieldPath(path), n)
+		}
+		writeLong(buf, n)
+		return nil
+
+	case "float", "double":
+		f, err := toFloat(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fieldPath(path), err)
+		}
+		if s.typ == "float" {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(float32(f)))
+			buf.Write(b[:])
+		} else {
+			var b [8]byte
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+			buf.Write(b[:])
+		}
+		return nil
+
+	case "bytes", "string":
+		str, err := toString(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fieldPath(path), err)
+		}
+		writeLong(buf, int64(len(str)))
+		buf.WriteString(str)
+		return nil
+
+	case "fixed":
+		str, err := toString(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fieldPath(path), err)
+		}
+		if len(str) != s.size {
+			return fmt.Errorf("%s: fixed %s requires %d bytes, got %d", fieldPath(path), s.name, s.size, len(str))
+		}
+		buf.WriteString(str)
+		return nil
+
+	case "enum":
+		str, err := toString(v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fieldPath(path), err)
+		}
+		for i, sym := range s.symbols {
+			if sym == str {
+				writeLong(buf, int64(i))
+				return nil
+			}
+		}
+		return fmt.Errorf("%s: %q is not a symbol of enum %s", fieldPath(path), str, s.name)
+
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", fieldPath(path), v)
+		}
+		if len(items) > 0 {
+			writeLong(buf, int64(len(items)))
+			for i, item := range items {
+				if err := writeAvro(buf, s.items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+		writeLong(buf, 0)
+		return nil
+
+	case "map":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", fieldPath(path), v)
+		}
+		if len(m) > 0 {
+			writeLong(buf, int64(len(m)))
+			for key, value := range m {
+				writeLong(buf, int64(len(key)))
+				buf.WriteString(key)
+				if err := writeAvro(buf, s.values, value, joinPath(path, key)); err != nil {
+					return err
+				}
+			}
+		}
+		writeLong(buf, 0)
+		return nil
+
+	case "record":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected object for record %s, got %T", fieldPath(path), s.name, v)
+		}
+		for _, f := range s.fields {
+			fv, present := m[f.name]
+			if !present {
+				var err error
+				fv, present, err = fieldDefault(f)
+				if err != nil {
+					return fmt.Errorf("%s: %w", fieldPath(joinPath(path, f.name)), err)
+				}
+				if !present {
+					return fmt.Errorf("%s: required field is missing", fieldPath(joinPath(path, f.name)))
+				}
+			}
+			if err := writeAvro(buf, f.schema, fv, joinPath(path, f.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case "union":
+		return writeUnion(buf, s, v, path)
+
+	default:
+		return fmt.Errorf("%s: unsupported type %s", fieldPath(path), s.typ)
+	}
+}
+
+// writeUnion selects the union branch for a value
+func writeUnion(buf *bytes.Buffer, s *avroSchema, v interface{}, path string) error {
+	// Avro JSON encoding wraps non-null union values as {"type": value}
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+		for key, inner := range m {
+			for i, branch := range s.branches {
+				if branchName(branch) == key {
+					writeLong(buf, int64(i))
+					return writeAvro(buf, branch, inner, path)
+				}
+			}
+		}
+	}
+
+	// Prefer branches matching the JSON type, then fall back to lenient conversion
+	for _, strict := range []bool{true, false} {
+		for i, branch := range s.branches {
+			if strict && !matchesJSONType(branch, v) {
+				continue
+			}
+			var tmp bytes.Buffer
+			if err := writeAvro(&tmp, branch, v, path); err == nil {
+				writeLong(buf, int64(i))
+				buf.Write(tmp.Bytes())
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s: value %v matches no union branch", fieldPath(path), v)
+}
+
+// matchesJSONType reports whether a schema is the natural target of a JSON value
+func matchesJSONType(s *avroSchema, v interface{}) bool {
+	switch v.(type) {
+	case nil:
+		return s.typ == "null"
+	case bool:
+		return s.typ == "boolean"
+	case json.Number, float64:
+		return s.typ == "int" || s.typ == "long" || s.typ == "float" || s.typ == "double"
+	case string:
+		return s.typ == "string" || s.typ == "bytes" || s.typ == "enum" || s.typ == "fixed"
+	case []interface{}:
+		return s.typ == "array"
+	case map[string]interface{}:
+		return s.typ == "record" || s.typ == "map"
+	default:
+		return false
+	}
+}
+
+// fieldDefault returns the decoded default of a field; nullable fields
+// without a default are treated as null
+func fieldDefault(f avroField) (interface{}, bool, error) {
+	if f.defaultVal != nil {
+		v, err := decodeJSON(f.defaultVal)
+		if err != nil {
+			return nil, false, err
+		}
+		return v, true, nil
+	}
+	if f.schema.typ == "union" {
+		for _, branch := range f.schema.branches {
+			if branch.typ == "null" {
+				return nil, true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+func branchName(s *avroSchema) string {
+	if s.name != "" {
+		return s.name
+	}
+	return s.typ
+}
+
+// writeLong writes a zig-zag encoded variable-length integer
+func writeLong(buf *bytes.Buffer, n int64) {
+	var b [binary.MaxVarintLen64]byte
+	l := binary.PutVarint(b[:], n)
+	buf.Write(b[:l])
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		return strconv.ParseBool(b)
+	default:
+		return false, fmt.Errorf("expected boolean, got %T", v)
+	}
+}
+
+// toAvroInt converts numbers, numeric strings and, for time logical types,
+// formatted time strings
+func toAvroInt(v interface{}, logical string) (int64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Int64()
+	case float64:
+		return int64(n), nil
+	case string:
+		if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+			return i, nil
+		}
+		switch logical {
+		case "timestamp-millis", "local-timestamp-millis":
+			t, err := time.Parse(time.RFC3339Nano, n)
+			if err != nil {
+				return 0, fmt.Errorf("expected timestamp, got %q", n)
+			}
+			return t.UnixMilli(), nil
+		case "timestamp-micros", "local-timestamp-micros":
+			t, err := time.Parse(time.RFC3339Nano, n)
+			if err != nil {
+				return 0, fmt.Errorf("expected timestamp, got %q", n)
+			}
+			return t.UnixMicro(), nil
+		case "date":
+			t, err := time.Parse(time.DateOnly, n)
+			if err != nil {
+				return 0, fmt.Errorf("expected date, got %q", n)
+			}
+			return t.Unix() / 86400, nil
+		}
+		return 0, fmt.Errorf("expected integer, got %q", n)
+	default:
+		return 0, fmt.Errorf("expected integer, got %T", v)
+	}
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case json.Number:
+		return n.Float64()
+	case float64:
+		return n, nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected number, got %q", n)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("expected number, got %T", v)
+	}
+}
+
+func toString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case json.Number:
+		return s.String(), nil
+	case bool:
+		return strconv.FormatBool(s), nil
+	default:
+		return "", fmt.Errorf("expected string, got %T", v)
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return "message"
+	}
+	return path
+}
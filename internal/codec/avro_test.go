@@ -0,0 +1,127 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+const orderSchema = `{
+  "type": "record",
+  "name": "Order",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "amount", "type": "long"},
+    {"name": "price", "type": "double"},
+    {"name": "paid", "type": "boolean"},
+    {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID"]}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}},
+    {"name": "note", "type": ["null", "string"]},
+    {"name": "channel", "type": "string", "default": "web"},
+    {"name": "createdAt", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+  ]
+}`
+
+func TestAvroEncoderEncode(t *testing.T) {
+	enc, err := NewAvroEncoder([]byte(orderSchema), 0)
+	if err != nil {
+		t.Fatalf("NewAvroEncoder() error = %v", err)
+	}
+
+	// Numbers, booleans and timestamps rendered as strings are accepted
+	msg := `{"id":"a","amount":"3","price":1.5,"paid":"true","status":"PAID","tags":["x"],"note":"hi","createdAt":"1970-01-01T00:00:01Z"}`
+	got, err := enc.Encode([]byte(msg))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	want := []byte{
+		0x02, 'a', // id
+		0x06,                                           // amount 3
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f, // price 1.5
+		0x01,            // paid
+		0x02,            // status PAID
+		0x02, 0x02, 'x', // tags block of one item
+		0x00,                 // end of tags
+		0x02, 0x04, 'h', 'i', // note: union branch 1, "hi"
+		0x06, 'w', 'e', 'b', // channel default
+		0xd0, 0x0f, // createdAt 1000ms
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encode() = % x\nwant       % x", got, want)
+	}
+}
+
+func TestAvroEncoderWireFormat(t *testing.T) {
+	enc, err := NewAvroEncoder([]byte(`{"type":"record","name":"R","fields":[{"name":"n","type":"int"}]}`), 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := enc.Encode([]byte(`{"n": -1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x00, 0x00, 0x00, 0x00, 42, 0x01}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encode() = % x, want % x", got, want)
+	}
+}
+
+func TestAvroEncoderUnionAndRecursion(t *testing.T) {
+	schema := `{
+  "type": "record",
+  "name": "Node",
+  "fields": [
+    {"name": "value", "type": ["null", "long", "string"]},
+    {"name": "next", "type": ["null", "Node"]}
+  ]
+}`
+	enc, err := NewAvroEncoder([]byte(schema), 0)
+	if err != nil {
+		t.Fatalf("NewAvroEncoder() error = %v", err)
+	}
+
+	got, err := enc.Encode([]byte(`{"value": {"string": "7"}, "next": {"value": 7}}`))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	want := []byte{
+		0x04, 0x02, '7', // value: string branch
+		0x02,       // next: Node branch
+		0x02, 0x0e, // value: long branch 7
+		0x00, // next: null
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Encode() = % x, want % x", got, want)
+	}
+}
+
+func TestAvroEncoderErrors(t *testing.T) {
+	enc, err := NewAvroEncoder([]byte(orderSchema), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{"missing required field", `{"amount":1,"price":1,"paid":true,"status":"NEW","tags":[],"createdAt":0}`},
+		{"invalid enum symbol", `{"id":"a","amount":1,"price":1,"paid":true,"status":"LOST","tags":[],"createdAt":0}`},
+		{"non-numeric long", `{"id":"a","amount":"many","price":1,"paid":true,"status":"NEW","tags":[],"createdAt":0}`},
+		{"invalid JSON", `{"id":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := enc.Encode([]byte(tt.msg)); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+
+	if _, err := NewAvroEncoder([]byte(`{"type":"record","name":"R","fields":[{"name":"x","type":"Unknown"}]}`), 0); err == nil {
+		t.Error("Expected error for unknown type reference")
+	}
+}
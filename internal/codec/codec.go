@@ -0,0 +1,117 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+)
+
+// Encoder converts a rendered JSON message into its wire representation
+type Encoder interface {
+	Encode(value []byte) ([]byte, error)
+}
+
+// PlaceholderSchemaID frames messages in offline mode when the schema ID
+// would come from a registry and no schema_id is configured
+const PlaceholderSchemaID = 1
+
+// Option configures an encoder
+type Option func(*options)
+
+type options struct {
+	offline bool
+}
+
+// Offline makes encoders skip the schema registry: no schema is looked up or
+// registered. Messages are framed with the configured schema_id, or
+// PlaceholderSchemaID, so they have the size of the messages sent.
+func Offline() Option {
+	return func(o *options) {
+		o.offline = true
+	}
+}
+
+// New creates the encoder for a payload's format.
+// It returns nil for JSON payloads, which are sent as rendered.
+func New(cfg *config.PayloadConfig, opts ...Option) (Encoder, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("payload config is required")
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	switch cfg.Format {
+	case "", "json":
+		return nil, nil
+	case "avro":
+		return newAvroFromConfig(cfg.Avro, o)
+	case "protobuf":
+		return newProtobufFromConfig(cfg.Protobuf, o)
+	default:
+		return nil, fmt.Errorf("unsupported format %q", cfg.Format)
+	}
+}
+
+// newAvroFromConfig loads the schema and resolves its registry ID
+func newAvroFromConfig(cfg *config.AvroConfig, o options) (Encoder, error) {
+	if cfg == nil || cfg.SchemaPath == "" {
+		return nil, fmt.Errorf("avro schema_path is required")
+	}
+
+	schema, err := os.ReadFile(cfg.SchemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avro schema: %w", err)
+	}
+
+	id, err := schemaID(cfg.Registry, cfg.SchemaID, string(schema), "AVRO", o)
+	if err != nil {
+		return nil, err
+	}
+	return NewAvroEncoder(schema, id)
+}
+
+// schemaID returns the ID messages are framed with: the one the registry
+// holds for the schema, or the static ID when there is no registry or the
+// encoder is offline
+func schemaID(registry *config.RegistryConfig, static int, schema, schemaType string, o options) (int, error) {
+	if registry == nil {
+		return static, nil
+	}
+	if o.offline {
+		if static > 0 {
+			return static, nil
+		}
+		return PlaceholderSchemaID, nil
+	}
+	client, err := NewRegistryClient(registry)
+	if err != nil {
+		return 0, err
+	}
+	return client.Resolve(schema, schemaType)
+}
+
+// decodeJSON parses a rendered message keeping numbers exact
+func decodeJSON(value []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("message is not valid JSON: %w", err)
+	}
+	return v, nil
+}
+
+// wireHeader returns the Confluent wire format prefix: magic byte 0 followed
+// by the big-endian schema ID
+func wireHeader(schemaID int) []byte {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], uint32(schemaID))
+	return header
+}
@@ -0,0 +1,199 @@
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufEncoder converts JSON messages into Protobuf binary using a
+// dynamically loaded message descriptor
+type ProtobufEncoder struct {
+	desc   protoreflect.MessageDescriptor
+	header []byte
+}
+
+// NewProtobufEncoder creates an encoder for a message descriptor. When
+// schemaID is positive, messages are prefixed with the Confluent wire format
+// header followed by the message indexes of the descriptor.
+func NewProtobufEncoder(desc protoreflect.MessageDescriptor, schemaID int) *ProtobufEncoder {
+	e := &ProtobufEncoder{desc: desc}
+	if schemaID > 0 {
+		e.header = append(wireHeader(schemaID), messageIndexes(desc)...)
+	}
+	return e
+}
+
+// Encode converts a JSON message into Protobuf binary
+func (e *ProtobufEncoder) Encode(value []byte) ([]byte, error) {
+	msg := dynamicpb.NewMessage(e.desc)
+	if err := protojson.Unmarshal(value, msg); err != nil {
+		return nil, fmt.Errorf("message does not match %s: %w", e.desc.FullName(), err)
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", e.desc.FullName(), err)
+	}
+
+	if e.header == nil {
+		return data, nil
+	}
+	out := make([]byte, 0, len(e.header)+len(data))
+	out = append(out, e.header...)
+	return append(out, data...), nil
+}
+
+// newProtobufFromConfig loads the message descriptor and resolves its
+// registry ID
+func newProtobufFromConfig(cfg *config.ProtobufConfig, o options) (Encoder, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("protobuf config is required")
+	}
+
+	var desc protoreflect.MessageDescriptor
+	var err error
+	switch {
+	case cfg.ProtoPath != "":
+		desc, err = compileMessage(cfg.ProtoPath, cfg.ImportPaths, cfg.Message)
+	case cfg.DescriptorSetPath != "":
+		desc, err = loadDescriptorSetMessage(cfg.DescriptorSetPath, cfg.Message)
+	default:
+		return nil, fmt.Errorf("protobuf proto_path or descriptor_set_path is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id := cfg.SchemaID
+	if cfg.Registry != nil {
+		if cfg.ProtoPath == "" {
+			return nil, fmt.Errorf("protobuf registry requires proto_path")
+		}
+		schema, err := os.ReadFile(cfg.ProtoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proto file: %w", err)
+		}
+		id, err = schemaID(cfg.Registry, cfg.SchemaID, string(schema), "PROTOBUF", o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewProtobufEncoder(desc, id), nil
+}
+
+// compileMessage compiles a .proto file and looks up a message in it.
+// Imports are resolved against importPaths, the file's own directory when it
+// lies outside them, and the well-known types bundled with the compiler.
+func compileMessage(path string, importPaths []string, name string) (protoreflect.MessageDescriptor, error) {
+	file, ok := relativeTo(path, importPaths)
+	if !ok {
+		file = filepath.Base(path)
+		importPaths = append([]string{filepath.Dir(path)}, importPaths...)
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	files, err := compiler.Compile(context.Background(), file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile proto file: %w", err)
+	}
+
+	d, err := files.AsResolver().FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found in %s", name, path)
+	}
+	return asMessage(d, name)
+}
+
+// loadDescriptorSetMessage reads a serialized FileDescriptorSet and looks up
+// a message in it
+func loadDescriptorSetMessage(path, name string) (protoreflect.MessageDescriptor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptor set: %w", err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("message %s not found in %s", name, path)
+	}
+	return asMessage(d, name)
+}
+
+// asMessage checks that a descriptor describes a message
+func asMessage(d protoreflect.Descriptor, name string) (protoreflect.MessageDescriptor, error) {
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", name)
+	}
+	return md, nil
+}
+
+// relativeTo returns path relative to the first import path containing it
+func relativeTo(path string, importPaths []string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	for _, dir := range importPaths {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(absDir, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel), true
+		}
+	}
+	return "", false
+}
+
+// messageIndexes encodes the position of a message within its file as used
+// by the Confluent Protobuf wire format: a zigzag varint count followed by
+// one index per nesting level. The common case of the first top-level
+// message is written as a single 0 byte.
+func messageIndexes(desc protoreflect.MessageDescriptor) []byte {
+	var path []int
+	for d := protoreflect.Descriptor(desc); ; {
+		path = append([]int{d.Index()}, path...)
+		parent, ok := d.Parent().(protoreflect.MessageDescriptor)
+		if !ok {
+			break
+		}
+		d = parent
+	}
+
+	if len(path) == 1 && path[0] == 0 {
+		return []byte{0}
+	}
+
+	out := binary.AppendVarint(nil, int64(len(path)))
+	for _, i := range path {
+		out = binary.AppendVarint(out, int64(i))
+	}
+	return out
+}
@@ -0,0 +1,182 @@
+package codec
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const orderProto = `syntax = "proto3";
+
+package shop;
+
+import "google/protobuf/timestamp.proto";
+
+message Order {
+  string id = 1;
+  int64 amount = 2;
+  Status status = 3;
+  repeated string tags = 4;
+  google.protobuf.Timestamp created_at = 5;
+
+  message Line {
+    string sku = 1;
+    int32 qty = 2;
+  }
+  repeated Line lines = 6;
+}
+
+enum Status {
+  NEW = 0;
+  PAID = 1;
+}
+`
+
+func writeProto(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "order.proto")
+	if err := os.WriteFile(path, []byte(orderProto), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProtobufEncoderEncode(t *testing.T) {
+	enc, err := New(&config.PayloadConfig{
+		Format:   "protobuf",
+		Protobuf: &config.ProtobufConfig{ProtoPath: writeProto(t), Message: "shop.Order"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	got, err := enc.Encode([]byte(`{"id":"o-1","amount":"42","status":"PAID","tags":["a"],"createdAt":"2024-01-02T03:04:05Z","lines":[{"sku":"x","qty":2}]}`))
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	desc := enc.(*ProtobufEncoder).desc
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(got, msg); err != nil {
+		t.Fatalf("failed to decode encoded message: %v", err)
+	}
+	if id := msg.Get(desc.Fields().ByName("id")).String(); id != "o-1" {
+		t.Errorf("Expected id o-1, got %q", id)
+	}
+	if amount := msg.Get(desc.Fields().ByName("amount")).Int(); amount != 42 {
+		t.Errorf("Expected amount 42, got %d", amount)
+	}
+	if lines := msg.Get(desc.Fields().ByName("lines")).List().Len(); lines != 1 {
+		t.Errorf("Expected 1 line, got %d", lines)
+	}
+
+	if _, err := enc.Encode([]byte(`{"unknown": 1}`)); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+}
+
+func TestProtobufEncoderWireFormat(t *testing.T) {
+	path := writeProto(t)
+
+	top, err := compileMessage(path, nil, "shop.Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewProtobufEncoder(top, 7).Encode([]byte(`{"id":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0, 0, 0, 0, 7, 0, 0x0a, 0x01, 'x'}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected % x, got % x", want, got)
+	}
+
+	nested, err := compileMessage(path, nil, "shop.Order.Line")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = NewProtobufEncoder(nested, 7).Encode([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Two indexes [0, 0], zigzag encoded
+	want = []byte{0, 0, 0, 0, 7, 0x04, 0, 0}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected % x, got % x", want, got)
+	}
+}
+
+func TestProtobufFromDescriptorSet(t *testing.T) {
+	desc, err := compileMessage(writeProto(t), nil, "shop.Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(desc.ParentFile().Imports().Get(0).FileDescriptor),
+		protodesc.ToFileDescriptorProto(desc.ParentFile()),
+	}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "order.pb")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := New(&config.PayloadConfig{
+		Format:   "protobuf",
+		Protobuf: &config.ProtobufConfig{DescriptorSetPath: path, Message: "shop.Order"},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := enc.Encode([]byte(`{"id":"o-1"}`)); err != nil {
+		t.Errorf("Encode() error = %v", err)
+	}
+
+	if _, err := loadDescriptorSetMessage(path, "shop.Missing"); err == nil {
+		t.Error("Expected error for unknown message")
+	}
+	if _, err := loadDescriptorSetMessage(path, "shop.Status"); err == nil {
+		t.Error("Expected error for non-message type")
+	}
+}
+
+func TestNewProtobufFromRegistry(t *testing.T) {
+	registry := newFakeRegistry()
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	enc, err := New(&config.PayloadConfig{
+		Format: "protobuf",
+		Protobuf: &config.ProtobufConfig{
+			ProtoPath: writeProto(t),
+			Message:   "shop.Order",
+			Registry:  &config.RegistryConfig{URL: server.URL, Subject: "orders-value", AutoRegister: true, Timeout: time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	got, err := enc.Encode([]byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte{0, 0, 0, 0, 101, 0}) {
+		t.Errorf("Unexpected wire format message % x", got)
+	}
+	if registry.types[len(registry.types)-1] != "PROTOBUF" {
+		t.Errorf("Expected schemaType PROTOBUF, got %q", registry.types[len(registry.types)-1])
+	}
+}
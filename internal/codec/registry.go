@@ -0,0 +1,140 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+)
+
+const registryContentType = "application/vnd.schemaregistry.v1+json"
+
+// RegistryClient talks to a Confluent-compatible schema registry
+type RegistryClient struct {
+	cfg    *config.RegistryConfig
+	client *http.Client
+}
+
+// registryRequest is the body of lookup and register requests
+type registryRequest struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType,omitempty"`
+}
+
+// registryResponse holds the fields used from registry responses
+type registryResponse struct {
+	ID        int    `json:"id"`
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// NewRegistryClient creates a schema registry client
+func NewRegistryClient(cfg *config.RegistryConfig) (*RegistryClient, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("registry config is required")
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("registry url is required")
+	}
+	if cfg.Subject == "" {
+		return nil, fmt.Errorf("registry subject is required")
+	}
+
+	return &RegistryClient{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}, nil
+}
+
+// Resolve returns the ID of the schema under the configured subject,
+// registering it first when auto-registration is enabled
+func (c *RegistryClient) Resolve(schema, schemaType string) (int, error) {
+	id, found, err := c.Lookup(schema, schemaType)
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		return id, nil
+	}
+	if !c.cfg.AutoRegister {
+		return 0, fmt.Errorf("schema is not registered under subject %s and auto_register is disabled", c.cfg.Subject)
+	}
+	return c.Register(schema, schemaType)
+}
+
+// Lookup checks whether the schema is registered under the subject
+func (c *RegistryClient) Lookup(schema, schemaType string) (int, bool, error) {
+	resp, status, err := c.post("/subjects/"+url.PathEscape(c.cfg.Subject), schema, schemaType)
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch {
+	case status == http.StatusOK:
+		return resp.ID, true, nil
+	case status == http.StatusNotFound:
+		// 40401: subject not found, 40403: schema not found
+		return 0, false, nil
+	default:
+		return 0, false, fmt.Errorf("schema lookup failed with status %d: %s", status, resp.Message)
+	}
+}
+
+// Register registers the schema under the subject and returns its ID
+func (c *RegistryClient) Register(schema, schemaType string) (int, error) {
+	resp, status, err := c.post("/subjects/"+url.PathEscape(c.cfg.Subject)+"/versions", schema, schemaType)
+	if err != nil {
+		return 0, err
+	}
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("schema registration failed with status %d: %s", status, resp.Message)
+	}
+	return resp.ID, nil
+}
+
+// post sends a schema to a registry endpoint
+func (c *RegistryClient) post(path, schema, schemaType string) (*registryResponse, int, error) {
+	reqBody := registryRequest{Schema: schema}
+	if schemaType != "AVRO" {
+		reqBody.SchemaType = schemaType
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal registry request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(c.cfg.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create registry request: %w", err)
+	}
+	req.Header.Set("Content-Type", registryContentType)
+	req.Header.Set("Accept", registryContentType)
+	if c.cfg.Username != "" {
+		req.SetBasicAuth(c.cfg.Username, c.cfg.Password)
+	}
+
+	httpResp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("schema registry request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read registry response: %w", err)
+	}
+
+	var resp registryResponse
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return nil, 0, fmt.Errorf("invalid registry response (status %d): %w", httpResp.StatusCode, err)
+		}
+	}
+
+	return &resp, httpResp.StatusCode, nil
+}
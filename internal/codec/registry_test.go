@@ -0,0 +1,180 @@
+package codec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+)
+
+// fakeRegistry is a minimal in-memory stand-in for a Confluent schema registry
+type fakeRegistry struct {
+	mu       sync.Mutex
+	subjects map[string]map[string]int
+	nextID   int
+	types    []string
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{subjects: make(map[string]map[string]int), nextID: 100}
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var req registryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	f.types = append(f.types, req.SchemaType)
+
+	const prefix = "/subjects/"
+	path := r.URL.Path[len(prefix):]
+	w.Header().Set("Content-Type", registryContentType)
+
+	if subject, ok := strings.CutSuffix(path, "/versions"); ok {
+		if f.subjects[subject] == nil {
+			f.subjects[subject] = make(map[string]int)
+		}
+		id, ok := f.subjects[subject][req.Schema]
+		if !ok {
+			f.nextID++
+			id = f.nextID
+			f.subjects[subject][req.Schema] = id
+		}
+		_ = json.NewEncoder(w).Encode(map[string]int{"id": id})
+		return
+	}
+
+	id, ok := f.subjects[path][req.Schema]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error_code": 40401, "message": "Subject not found"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"subject": path, "id": id, "version": 1})
+}
+
+func TestRegistryClientResolve(t *testing.T) {
+	registry := newFakeRegistry()
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	schema := `{"type":"string"}`
+
+	noRegister, err := NewRegistryClient(&config.RegistryConfig{URL: server.URL, Subject: "orders-value", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := noRegister.Resolve(schema, "AVRO"); err == nil {
+		t.Error("Expected error for unregistered schema without auto_register")
+	}
+
+	client, err := NewRegistryClient(&config.RegistryConfig{URL: server.URL, Subject: "orders-value", AutoRegister: true, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := client.Resolve(schema, "AVRO")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if id != 101 {
+		t.Errorf("Expected registered id 101, got %d", id)
+	}
+
+	// A second resolution finds the existing registration
+	again, err := noRegister.Resolve(schema, "AVRO")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if again != id {
+		t.Errorf("Expected lookup to return %d, got %d", id, again)
+	}
+
+	for _, typ := range registry.types {
+		if typ != "" {
+			t.Errorf("Expected schemaType to be omitted for AVRO, got %q", typ)
+		}
+	}
+}
+
+func TestNewAvroFromRegistry(t *testing.T) {
+	server := httptest.NewServer(newFakeRegistry())
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "value.avsc")
+	if err := os.WriteFile(path, []byte(`{"type":"record","name":"R","fields":[{"name":"n","type":"int"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	enc, err := New(&config.PayloadConfig{
+		Format: "avro",
+		Avro: &config.AvroConfig{
+			SchemaPath: path,
+			Registry:   &config.RegistryConfig{URL: server.URL, Subject: "r-value", AutoRegister: true, Timeout: time.Second},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	got, err := enc.Encode([]byte(`{"n": 1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 6 || got[0] != 0 || got[4] != 101 || got[5] != 0x02 {
+		t.Errorf("Unexpected wire format message % x", got)
+	}
+}
+
+func TestNewOfflineSkipsRegistry(t *testing.T) {
+	// Nothing listens on the discard port, so any registry call fails
+	registry := &config.RegistryConfig{URL: "http://127.0.0.1:9", Subject: "r-value", AutoRegister: true, Timeout: time.Second}
+
+	path := filepath.Join(t.TempDir(), "value.avsc")
+	if err := os.WriteFile(path, []byte(`{"type":"record","name":"R","fields":[{"name":"n","type":"int"}]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	avro := &config.PayloadConfig{Format: "avro", Avro: &config.AvroConfig{SchemaPath: path, Registry: registry}}
+	if _, err := New(avro); err == nil {
+		t.Fatal("New() error = nil, want the unreachable registry to fail")
+	}
+
+	tests := []struct {
+		name     string
+		cfg      *config.PayloadConfig
+		schemaID byte
+	}{
+		{"avro placeholder", avro, PlaceholderSchemaID},
+		{"avro schema_id", &config.PayloadConfig{Format: "avro", Avro: &config.AvroConfig{SchemaPath: path, SchemaID: 7, Registry: registry}}, 7},
+		{"protobuf placeholder", &config.PayloadConfig{Format: "protobuf", Protobuf: &config.ProtobufConfig{ProtoPath: writeProto(t), Message: "shop.Order", Registry: registry}}, PlaceholderSchemaID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := New(tt.cfg, Offline())
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			value := `{"n": 1}`
+			if tt.cfg.Format == "protobuf" {
+				value = `{}`
+			}
+			got, err := enc.Encode([]byte(value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) < 5 || got[0] != 0 || got[4] != tt.schemaID {
+				t.Errorf("Unexpected wire format message % x, want schema ID %d", got, tt.schemaID)
+			}
+		})
+	}
+}
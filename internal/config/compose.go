@@ -0,0 +1,225 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// compose reads a config file with its includes into one YAML mapping and
+// overlays the named profile. Includes are merged in order and the including
+// file is merged last, so its values win. Unknown fields in any of the files
+// are recorded in p.
+func compose(path, profile string, p *Problems) (*yaml.Node, error) {
+	root, err := readComposed(path, nil, p)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := removeKey(root, "profiles")
+	if profile == "" {
+		return root, nil
+	}
+	var names []string
+	if profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 0; i < len(profiles.Content); i += 2 {
+			if profiles.Content[i].Value == profile {
+				overlay := profiles.Content[i+1]
+				if overlay.Kind != yaml.MappingNode {
+					return nil, fmt.Errorf("profile %s must be a mapping", profile)
+				}
+				mergeNodes(root, overlay)
+				return root, nil
+			}
+			names = append(names, profiles.Content[i].Value)
+		}
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown profile %q, available: %s", profile, strings.Join(names, ", "))
+}
+
+// readComposed reads a file and merges its includes, expanding environment
+// variables in each file. stack holds the files being read, to detect cycles.
+func readComposed(path string, stack []string, p *Problems) (*yaml.Node, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	for _, seen := range stack {
+		if seen == abs {
+			return nil, fmt.Errorf("include cycle: %s", strings.Join(append(stack, abs), " -> "))
+		}
+	}
+	stack = append(stack, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if len(doc.Content) > 0 {
+		root = doc.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %s must hold a mapping", path)
+	}
+	if err := ExpandEnvNode(root); err != nil {
+		return nil, fmt.Errorf("failed to expand config file %s: %w", path, err)
+	}
+
+	patterns, err := includePatterns(removeKey(root, "include"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	checkFile(root, path, p)
+
+	result := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid include %s: %w", path, pattern, err)
+		}
+		// A plain path must exist, a glob may match nothing
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			return nil, fmt.Errorf("%s: included file %s does not exist", path, pattern)
+		}
+		for _, match := range matches {
+			included, err := readComposed(match, stack, p)
+			if err != nil {
+				return nil, err
+			}
+			mergeNodes(result, included)
+		}
+	}
+
+	mergeNodes(result, root)
+	return result, nil
+}
+
+// checkFile records the unknown fields of a config file and its profiles
+func checkFile(root *yaml.Node, path string, p *Problems) {
+	configType := reflect.TypeOf(Config{})
+	base := &yaml.Node{Kind: yaml.MappingNode}
+	var profiles *yaml.Node
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if key.Value != "profiles" {
+			base.Content = append(base.Content, key, value)
+			continue
+		}
+		if value.Kind != yaml.MappingNode {
+			p.add("%s:%d: profiles must map profile names to settings", path, key.Line)
+			continue
+		}
+		profiles = value
+	}
+	checkFields(base, configType, path, "", p)
+	if profiles != nil {
+		for i := 0; i < len(profiles.Content); i += 2 {
+			checkFields(profiles.Content[i+1], configType, path, "profiles."+profiles.Content[i].Value, p)
+		}
+	}
+}
+
+// includePatterns returns the files of an include value, a single path or
+// a list of paths and globs
+func includePatterns(node *yaml.Node) ([]string, error) {
+	if node == nil {
+		return nil, nil
+	}
+	var patterns []string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		patterns = append(patterns, node.Value)
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("include must list file paths")
+			}
+			patterns = append(patterns, item.Value)
+		}
+	default:
+		return nil, fmt.Errorf("include must be a path or a list of paths")
+	}
+	return patterns, nil
+}
+
+// mergeNodes overlays the mapping src on dst. Mappings are merged key by key
+// and payloads by name; any other value in src replaces the one in dst.
+func mergeNodes(dst, src *yaml.Node) {
+	for i := 0; i < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		existing := findKey(dst, key.Value)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, key, value)
+		case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeNodes(existing, value)
+		case key.Value == "payloads" && existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			mergePayloads(existing, value)
+		default:
+			*existing = *value
+		}
+	}
+}
+
+// mergePayloads merges payloads of src into the payloads of dst with the
+// same name and appends the others
+func mergePayloads(dst, src *yaml.Node) {
+	for _, payload := range src.Content {
+		if name := findKey(payload, "name"); name != nil && payload.Kind == yaml.MappingNode {
+			if existing := findPayload(dst, name.Value); existing != nil {
+				mergeNodes(existing, payload)
+				continue
+			}
+		}
+		dst.Content = append(dst.Content, payload)
+	}
+}
+
+// findPayload returns the payload mapping with the given name
+func findPayload(payloads *yaml.Node, name string) *yaml.Node {
+	for _, payload := range payloads.Content {
+		if n := findKey(payload, "name"); n != nil && n.Value == name && payload.Kind == yaml.MappingNode {
+			return payload
+		}
+	}
+	return nil
+}
+
+// findKey returns the value of a key in a mapping
+func findKey(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// removeKey removes a key from a mapping and returns its value
+func removeKey(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return value
+		}
+	}
+	return nil
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"os"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -10,20 +15,176 @@ import (
 
 // Config represents the application configuration
 type Config struct {
-	Kafka     KafkaConfig      `yaml:"kafka" validate:"required"`
-	Scheduler *SchedulerConfig  `yaml:"scheduler,omitempty"`
-	Logging   LoggingConfig    `yaml:"logging"`
-	Payloads  []PayloadConfig  `yaml:"payloads" validate:"required,min=1"`
+	Kafka     KafkaConfig           `yaml:"kafka" validate:"required"`
+	Scheduler *SchedulerConfig      `yaml:"scheduler,omitempty"`
+	Logging   LoggingConfig         `yaml:"logging"`
+	Payloads  []PayloadConfig       `yaml:"payloads" validate:"required,min=1"`
+	Pools     map[string]PoolConfig `yaml:"pools,omitempty"`
+	Verify    *VerifyConfig         `yaml:"verify,omitempty"`
+	Sink      *SinkConfig           `yaml:"sink,omitempty"`
+
+	// Clusters are additional named Kafka clusters payloads can be routed
+	// to, the kafka section is the cluster named default
+	Clusters map[string]*KafkaConfig `yaml:"clusters,omitempty"`
+	// Groups switches payload groups on or off, groups not listed are on
+	Groups map[string]bool `yaml:"groups,omitempty"`
+	// Seed makes the random values of template payloads reproducible; unset
+	// draws from crypto/rand
+	Seed *uint64 `yaml:"seed,omitempty"`
+}
+
+// DefaultCluster is the name of the cluster configured in the kafka section
+const DefaultCluster = "default"
+
+// Cluster returns the settings of a named cluster, or nil if it is not
+// configured
+func (c *Config) Cluster(name string) *KafkaConfig {
+	if name == "" || name == DefaultCluster {
+		return &c.Kafka
+	}
+	return c.Clusters[name]
+}
+
+// usesCluster reports whether any payload is sent to the named cluster
+func (c *Config) usesCluster(name string) bool {
+	for _, payload := range c.Payloads {
+		if slices.Contains(payload.Cluster.OrDefault(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterNames returns the names of the additional clusters in order
+func (c *Config) clusterNames() []string {
+	names := make([]string, 0, len(c.Clusters))
+	for name := range c.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ClusterNames lists the clusters a payload is sent to. It is written as a
+// single name or a list.
+type ClusterNames []string
+
+// UnmarshalYAML allows a single cluster name instead of a list
+func (n *ClusterNames) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var name string
+		if err := node.Decode(&name); err != nil {
+			return err
+		}
+		*n = ClusterNames{name}
+		return nil
+	}
+	return node.Decode((*[]string)(n))
+}
+
+// OrDefault returns the names, or the default cluster when none are set
+func (n ClusterNames) OrDefault() []string {
+	if len(n) == 0 {
+		return []string{DefaultCluster}
+	}
+	return n
+}
+
+// SinkConfig selects where generated messages are written
+type SinkConfig struct {
+	Type       string `yaml:"type"`        // kafka (default), stdout, file or discard
+	Path       string `yaml:"path"`        // file: JSON-lines file, or a directory with per_message
+	PerMessage bool   `yaml:"per_message"` // file: write every message value to its own file
+	Extension  string `yaml:"extension"`   // file name extension with per_message, defaults to json
+}
+
+// UsesKafka reports whether messages go to the Kafka cluster
+func (s *SinkConfig) UsesKafka() bool {
+	return s == nil || s.Type == "" || s.Type == "kafka"
+}
+
+// VerifyConfig enables read-back verification of produced messages
+type VerifyConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Header  string        `yaml:"header"`  // header carrying the message ID, defaults to x-pusher-id
+	Timeout time.Duration `yaml:"timeout"` // how long a message may stay outstanding, and to wait for them at the end, defaults to 30s
 }
 
 // KafkaConfig holds Kafka connection settings
 type KafkaConfig struct {
-	Brokers   []string      `yaml:"brokers" validate:"required,min=1"`
-	Topic     string        `yaml:"topic,omitempty"` // Optional: used as default if not specified in payload
-	ClientID  string        `yaml:"client_id"`
-	Partition int           `yaml:"partition"`
-	Timeout   time.Duration `yaml:"timeout"`
-	Async     bool          `yaml:"async"`
+	Brokers     []string           `yaml:"brokers" validate:"required,min=1"`
+	Topic       string             `yaml:"topic,omitempty"` // Optional: used as default if not specified in payload
+	ClientID    string             `yaml:"client_id"`
+	Partition   int                `yaml:"partition"`
+	Timeout     time.Duration      `yaml:"timeout"`
+	Async       bool               `yaml:"async"`
+	TLS         *TLSConfig         `yaml:"tls,omitempty"`
+	SASL        *SASLConfig        `yaml:"sasl,omitempty"`
+	Stamp       *StampConfig       `yaml:"stamp,omitempty"`
+	Retry       *RetryConfig       `yaml:"retry,omitempty"`
+	DeadLetter  *DeadLetterConfig  `yaml:"dead_letter,omitempty"`
+	Idempotent  bool               `yaml:"idempotent"` // producer ID and per-partition sequence numbers
+	Transaction *TransactionConfig `yaml:"transaction,omitempty"`
+
+	SkipPreflight bool                `yaml:"skip_preflight"` // skip the broker and topic checks at startup
+	CreateTopics  *CreateTopicsConfig `yaml:"create_topics,omitempty"`
+}
+
+// CreateTopicsConfig creates payload topics found missing by the pre-flight
+// checks
+type CreateTopicsConfig struct {
+	Partitions        int               `yaml:"partitions"`         // defaults to the broker's num.partitions
+	ReplicationFactor int               `yaml:"replication_factor"` // defaults to the broker's default.replication.factor
+	Configs           map[string]string `yaml:"configs"`            // topic configs such as retention.ms and cleanup.policy
+}
+
+// TransactionConfig produces batches in transactions, which implies an
+// idempotent producer
+type TransactionConfig struct {
+	ID        string        `yaml:"id"`         // transactional ID, defaults to the client ID
+	Timeout   time.Duration `yaml:"timeout"`    // defaults to 60s
+	Scope     string        `yaml:"scope"`      // batch (default) or tick: one transaction per scheduler execution
+	AbortRate float64       `yaml:"abort_rate"` // share of transactions deliberately aborted
+}
+
+// RetryConfig retries failed batches with exponential backoff. Sync writes
+// are retried by the producer, async writes by the writer before they are
+// dead-lettered.
+type RetryConfig struct {
+	Attempts       int           `yaml:"attempts"`        // retries after the first failure, defaults to 3
+	InitialBackoff time.Duration `yaml:"initial_backoff"` // defaults to 100ms, doubled on every retry
+	MaxBackoff     time.Duration `yaml:"max_backoff"`     // defaults to 10s
+	Jitter         float64       `yaml:"jitter"`          // randomised share of each backoff, defaults to 0.2
+}
+
+// DeadLetterConfig spills messages that could not be delivered to a
+// JSON-lines file that replay mode can read
+type DeadLetterConfig struct {
+	Path string `yaml:"path"`
+}
+
+// TLSConfig holds TLS settings for broker connections
+type TLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	CAFile             string `yaml:"ca_file"`   // defaults to the system pool
+	CertFile           string `yaml:"cert_file"` // client certificate for mutual TLS
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// SASLConfig holds SASL authentication settings
+type SASLConfig struct {
+	Mechanism string `yaml:"mechanism"` // plain, scram-sha-256 or scram-sha-512
+	Username  string `yaml:"username"`
+	Password  string `yaml:"password"`
+}
+
+// StampConfig adds send timestamp and sequence headers to every message
+// for latency measurement
+type StampConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	TimestampHeader string `yaml:"timestamp_header"` // unix nanoseconds, defaults to x-sent-at
+	SequenceHeader  string `yaml:"sequence_header"`  // defaults to x-seq
 }
 
 // SchedulerConfig holds scheduler settings
@@ -31,6 +192,7 @@ type SchedulerConfig struct {
 	Enabled        bool          `yaml:"enabled"`
 	Interval       time.Duration `yaml:"interval" validate:"required_if=Enabled true"`
 	WorkerPoolSize int           `yaml:"worker_pool_size"`
+	Duration       time.Duration `yaml:"duration"` // stop after this long, 0 runs until interrupted
 }
 
 // LoggingConfig holds logging settings
@@ -38,46 +200,259 @@ type LoggingConfig struct {
 	Level   string `yaml:"level"`
 	Format  string `yaml:"format"` // json or text
 	Verbose bool   `yaml:"verbose"`
+	Output  string `yaml:"output"` // stdout or stderr, defaults to stderr with the stdout sink
 }
 
 // PayloadConfig holds payload template settings
 type PayloadConfig struct {
-	Name         string `yaml:"name"`
-	TemplatePath string `yaml:"template_path" validate:"required"`
-	BatchSize    int    `yaml:"batch_size"`
-	Topic        string `yaml:"topic" validate:"required"`
+	Name         string            `yaml:"name"`
+	TemplatePath string            `yaml:"template_path" validate:"required"`
+	BatchSize    int               `yaml:"batch_size"`
+	Topic        string            `yaml:"topic" validate:"required"`
+	Lifecycle    *LifecycleConfig  `yaml:"lifecycle,omitempty"`
+	Replay       *ReplayConfig     `yaml:"replay,omitempty"`
+	Format       string            `yaml:"format"` // json (default), avro or protobuf
+	Avro         *AvroConfig       `yaml:"avro,omitempty"`
+	Protobuf     *ProtobufConfig   `yaml:"protobuf,omitempty"`
+	JSONSchema   *JSONSchemaConfig `yaml:"json_schema,omitempty"`
+	SchemaPath   string            `yaml:"schema_path"`  // JSON Schema every generated message is validated against
+	OnViolation  string            `yaml:"on_violation"` // drop (default), log or abort
+	Faults       *FaultsConfig     `yaml:"faults,omitempty"`
+	Delivery     *DeliveryConfig   `yaml:"delivery,omitempty"`
+	Cluster      ClusterNames      `yaml:"cluster,omitempty"` // clusters to send to, defaults to the kafka section
+	Group        string            `yaml:"group,omitempty"`   // group that can be switched off as a whole
 }
 
-// Load reads and parses the configuration file
-func Load(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+// DeliveryConfig simulates duplicate and out-of-order delivery
+type DeliveryConfig struct {
+	Duplicates *DuplicatesConfig `yaml:"duplicates,omitempty"`
+	Reorder    *ReorderConfig    `yaml:"reorder,omitempty"`
+}
+
+// DuplicatesConfig re-sends a share of sent messages after a delay
+type DuplicatesConfig struct {
+	Rate     float64       `yaml:"rate"`      // fraction of messages sent again
+	Delay    time.Duration `yaml:"delay"`     // minimum time before the duplicate
+	MaxDelay time.Duration `yaml:"max_delay"` // optional, picks a random delay up to this
+	History  int           `yaml:"history"`   // maximum pending duplicates, defaults to 10000
+}
+
+// ReorderConfig shuffles or holds back a share of messages
+type ReorderConfig struct {
+	Rate     float64       `yaml:"rate"`      // fraction of messages affected
+	Mode     string        `yaml:"mode"`      // shuffle (default) within the batch, or delay to a later batch
+	Delay    time.Duration `yaml:"delay"`     // hold time in delay mode, defaults to 1s
+	MaxDelay time.Duration `yaml:"max_delay"` // optional, picks a random hold time up to this
+	History  int           `yaml:"history"`   // maximum held-back messages in delay mode, defaults to 10000
+}
+
+// FaultTypes lists the faults that can be injected into messages
+var FaultTypes = []string{"missing_field", "wrong_type", "truncate", "oversize", "invalid_utf8", "duplicate", "null_key"}
+
+// FaultsConfig injects deliberately broken messages for consumer robustness tests
+type FaultsConfig struct {
+	Rate          float64            `yaml:"rate"`           // fraction of messages to corrupt
+	Types         map[string]float64 `yaml:"types"`          // fault type -> relative weight, defaults to all types equally
+	Header        string             `yaml:"header"`         // header naming the injected fault, defaults to x-fault
+	OversizeBytes int                `yaml:"oversize_bytes"` // padding added by oversize faults, defaults to 1 MiB
+}
+
+// JSONSchemaConfig generates payloads from a JSON Schema instead of a template
+type JSONSchemaConfig struct {
+	Path                string            `yaml:"path"`
+	Overrides           map[string]string `yaml:"overrides"`            // dot path -> directive or literal
+	OptionalProbability float64           `yaml:"optional_probability"` // chance of emitting a non-required property
+}
+
+// AvroConfig holds settings for encoding payloads as Avro.
+// Messages use the Confluent wire format when a schema ID is configured or
+// resolved through the registry, and plain Avro binary otherwise.
+type AvroConfig struct {
+	SchemaPath string          `yaml:"schema_path"`
+	SchemaID   int             `yaml:"schema_id"` // static ID for offline use
+	Registry   *RegistryConfig `yaml:"registry,omitempty"`
+}
+
+// ProtobufConfig holds settings for encoding payloads as Protobuf.
+// The message is described either by a .proto file or by a compiled
+// descriptor set (protoc --descriptor_set_out --include_imports).
+type ProtobufConfig struct {
+	ProtoPath         string          `yaml:"proto_path"`
+	ImportPaths       []string        `yaml:"import_paths"` // defaults to the directory of proto_path
+	DescriptorSetPath string          `yaml:"descriptor_set_path"`
+	Message           string          `yaml:"message"` // fully-qualified message name
+	SchemaID          int             `yaml:"schema_id"`
+	Registry          *RegistryConfig `yaml:"registry,omitempty"`
+}
+
+// RegistryConfig holds Confluent-compatible schema registry settings
+type RegistryConfig struct {
+	URL          string        `yaml:"url"`
+	Subject      string        `yaml:"subject"` // defaults to <topic>-value
+	AutoRegister bool          `yaml:"auto_register"`
+	Username     string        `yaml:"username"`
+	Password     string        `yaml:"password"`
+	Timeout      time.Duration `yaml:"timeout"`
+}
+
+// ReplayConfig holds settings for publishing previously recorded messages
+// instead of generating them from a template
+type ReplayConfig struct {
+	Path            string            `yaml:"path"`
+	Mode            string            `yaml:"mode"`             // asap, rate or original
+	Rate            float64           `yaml:"rate"`             // messages per second in rate mode
+	Speed           float64           `yaml:"speed"`            // timing scale in original mode, 2 replays twice as fast
+	Loop            bool              `yaml:"loop"`             // start over when the file is exhausted
+	ShiftTimestamps bool              `yaml:"shift_timestamps"` // rebase record timestamps onto the replay start
+	Set             map[string]string `yaml:"set"`              // value fields (dot paths) replaced by directives
+}
+
+// LifecycleConfig describes a state machine walked by every generated entity.
+// Each transition emits one message keyed by the entity ID.
+type LifecycleConfig struct {
+	Initial     string                        `yaml:"initial"`
+	Key         string                        `yaml:"key"`          // entity ID, resolved once per entity
+	MaxEntities int                           `yaml:"max_entities"` // upper bound of in-flight entities
+	Attributes  map[string]string             `yaml:"attributes"`   // resolved once per entity
+	Transitions map[string][]TransitionConfig `yaml:"transitions"`
+}
+
+// TransitionConfig holds a single outgoing transition of a lifecycle state
+type TransitionConfig struct {
+	To          string        `yaml:"to"`
+	Probability float64       `yaml:"probability"`
+	Delay       time.Duration `yaml:"delay"`
+	MaxDelay    time.Duration `yaml:"max_delay"` // optional, delay is picked uniformly from [delay, max_delay]
+}
+
+// PoolConfig holds settings for a named entity pool shared between payloads.
+// A pool may be declared in short form as just its size (e.g. `customers: 10000`).
+type PoolConfig struct {
+	Size       int               `yaml:"size"`
+	Fields     map[string]string `yaml:"fields"`
+	Selection  string            `yaml:"selection"`   // uniform or hotkey
+	HotKeys    float64           `yaml:"hot_keys"`    // fraction of members treated as hot
+	HotTraffic float64           `yaml:"hot_traffic"` // fraction of references that hit hot members
+}
+
+// UnmarshalYAML allows a pool to be declared as a plain size
+func (p *PoolConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&p.Size)
 	}
+	type plain PoolConfig
+	return node.Decode((*plain)(p))
+}
+
+// LoadOptions adjust the configuration read from a file
+type LoadOptions struct {
+	Profile   string          // profile overlaid on the file
+	Overrides []Override      // applied after environment overrides
+	Groups    map[string]bool // payload groups switched on or off, over the groups of the file
+
+	// Check runs further checks after validation, such as parsing templates.
+	// Its problems are reported together with the validation problems.
+	Check func(*Config) error
+}
+
+// Load reads and parses the configuration file. Environment overrides and
+// then the given overrides take precedence over the file.
+func Load(path string, overrides ...Override) (*Config, error) {
+	return LoadWith(path, LoadOptions{Overrides: overrides})
+}
 
+// LoadWith reads the configuration file with its includes and applies the
+// options. Unknown fields, values of the wrong type and invalid settings are
+// all reported together as Problems.
+func LoadWith(path string, opts LoadOptions) (*Config, error) {
+	var problems Problems
+	doc, err := compose(path, opts.Profile, &problems)
+	if err != nil {
+		return nil, err
+	}
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	if err := doc.Decode(&cfg); err != nil {
+		// Values of the wrong type are skipped, the rest is decoded
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		for _, msg := range typeErr.Errors {
+			problems.add("%s", msg)
+		}
 	}
+	problems.addAll("environment override ", applyEnvOverrides(&cfg, os.Environ()))
+	problems.addAll("override ", applyOverrides(&cfg, opts.Overrides))
+	problems.addAll("", cfg.applyGroups(opts.Groups))
 
 	// Set defaults
 	cfg.setDefaults()
 
 	// Validate
-	if err := cfg.Validate(); err != nil {
+	problems.addAll("", cfg.Validate())
+	if opts.Check != nil {
+		problems.addAll("", opts.Check(&cfg))
+	}
+	if err := problems.err(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
 	return &cfg, nil
 }
 
+// applyGroups drops the payloads of disabled groups. Groups not listed in
+// the file or the given switches are enabled. Switches of groups no payload
+// belongs to are reported as Problems.
+func (c *Config) applyGroups(switches map[string]bool) error {
+	groups := make(map[string]bool)
+	for _, p := range c.Payloads {
+		if p.Group != "" {
+			groups[p.Group] = true
+		}
+	}
+	for name, enabled := range switches {
+		if c.Groups == nil {
+			c.Groups = make(map[string]bool)
+		}
+		c.Groups[name] = enabled
+	}
+
+	names := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var p Problems
+	for _, name := range names {
+		if !groups[name] {
+			p.add("no payload belongs to group %s", name)
+		}
+	}
+
+	payloads := c.Payloads[:0]
+	for _, p := range c.Payloads {
+		if enabled, ok := c.Groups[p.Group]; !ok || enabled || p.Group == "" {
+			payloads = append(payloads, p)
+		}
+	}
+	c.Payloads = payloads
+	return p.err()
+}
+
 // setDefaults sets default values for optional fields
 func (c *Config) setDefaults() {
-	if c.Kafka.ClientID == "" {
-		c.Kafka.ClientID = "kafka-pusher"
+	c.Kafka.setDefaults()
+	for _, cluster := range c.Clusters {
+		if cluster != nil {
+			cluster.setDefaults()
+		}
+	}
+	if s := c.Sink; s != nil && s.PerMessage && s.Extension == "" {
+		s.Extension = "json"
 	}
-	if c.Kafka.Timeout == 0 {
-		c.Kafka.Timeout = 10 * time.Second
+	if c.Logging.Output == "" && c.Sink != nil && c.Sink.Type == "stdout" {
+		// Keep the log out of the generated messages
+		c.Logging.Output = "stderr"
 	}
 	if c.Logging.Level == "" {
 		c.Logging.Level = "info"
@@ -92,6 +467,92 @@ func (c *Config) setDefaults() {
 		if c.Payloads[i].Name == "" {
 			c.Payloads[i].Name = fmt.Sprintf("payload-%d", i+1)
 		}
+		if c.Payloads[i].Format == "" {
+			c.Payloads[i].Format = "json"
+		}
+		if av := c.Payloads[i].Avro; av != nil && av.Registry != nil {
+			av.Registry.setDefaults(c.Payloads[i].Topic)
+		}
+		if pb := c.Payloads[i].Protobuf; pb != nil && pb.Registry != nil {
+			pb.Registry.setDefaults(c.Payloads[i].Topic)
+		}
+		if c.Payloads[i].SchemaPath != "" && c.Payloads[i].OnViolation == "" {
+			c.Payloads[i].OnViolation = "drop"
+		}
+		if fc := c.Payloads[i].Faults; fc != nil {
+			if len(fc.Types) == 0 {
+				fc.Types = make(map[string]float64, len(FaultTypes))
+				for _, t := range FaultTypes {
+					fc.Types[t] = 1
+				}
+			}
+			if fc.Header == "" {
+				fc.Header = "x-fault"
+			}
+			if fc.OversizeBytes == 0 {
+				fc.OversizeBytes = 1 << 20
+			}
+		}
+		if dc := c.Payloads[i].Delivery; dc != nil {
+			if dc.Duplicates != nil && dc.Duplicates.History == 0 {
+				dc.Duplicates.History = 10000
+			}
+			if rc := dc.Reorder; rc != nil {
+				if rc.Mode == "" {
+					rc.Mode = "shuffle"
+				}
+				if rc.Mode == "delay" && rc.Delay == 0 {
+					rc.Delay = time.Second
+				}
+				if rc.Mode == "delay" && rc.History == 0 {
+					rc.History = 10000
+				}
+			}
+		}
+		if js := c.Payloads[i].JSONSchema; js != nil && js.OptionalProbability == 0 {
+			js.OptionalProbability = 0.5
+		}
+		if rp := c.Payloads[i].Replay; rp != nil {
+			if rp.Mode == "" {
+				rp.Mode = "asap"
+			}
+			if rp.Speed == 0 {
+				rp.Speed = 1
+			}
+		}
+		if lc := c.Payloads[i].Lifecycle; lc != nil {
+			if lc.Key == "" {
+				lc.Key = "{{@uuid}}"
+			}
+			if lc.MaxEntities == 0 {
+				lc.MaxEntities = 1000
+			}
+		}
+	}
+	for name, pool := range c.Pools {
+		if len(pool.Fields) == 0 {
+			pool.Fields = map[string]string{"id": "{{@uuid}}"}
+		}
+		if pool.Selection == "" {
+			pool.Selection = "uniform"
+		}
+		if pool.Selection == "hotkey" {
+			if pool.HotKeys == 0 {
+				pool.HotKeys = 0.2
+			}
+			if pool.HotTraffic == 0 {
+				pool.HotTraffic = 0.8
+			}
+		}
+		c.Pools[name] = pool
+	}
+	if c.Verify != nil && c.Verify.Enabled {
+		if c.Verify.Header == "" {
+			c.Verify.Header = "x-pusher-id"
+		}
+		if c.Verify.Timeout == 0 {
+			c.Verify.Timeout = 30 * time.Second
+		}
 	}
 	if c.Scheduler != nil && c.Scheduler.Enabled {
 		if c.Scheduler.Interval == 0 {
@@ -103,29 +564,453 @@ func (c *Config) setDefaults() {
 	}
 }
 
-// Validate validates the configuration
+// Validate validates the configuration and returns every problem found as
+// Problems
 func (c *Config) Validate() error {
-	if len(c.Kafka.Brokers) == 0 {
-		return fmt.Errorf("kafka.brokers is required")
+	var p Problems
+	if len(c.Kafka.Brokers) == 0 && c.Sink.UsesKafka() && c.usesCluster(DefaultCluster) {
+		p.add("kafka.brokers is required")
+	}
+	p.addAll("kafka.", c.Kafka.validate())
+	for _, name := range c.clusterNames() {
+		cluster := c.Clusters[name]
+		if name == DefaultCluster {
+			p.add("clusters.%s is reserved for the kafka section", name)
+			continue
+		}
+		if cluster == nil || len(cluster.Brokers) == 0 {
+			p.add("clusters.%s.brokers is required", name)
+		}
+		if cluster != nil {
+			p.addAll("clusters."+name+".", cluster.validate())
+		}
+	}
+	if c.Verify != nil && c.Verify.Enabled {
+		for _, name := range c.clusterNames() {
+			if c.usesCluster(name) {
+				p.add("verify only reads back from the kafka section, but payloads are sent to cluster %s", name)
+			}
+		}
+	}
+	switch c.Logging.Output {
+	case "", "stdout", "stderr":
+	default:
+		p.add("logging.output must be stdout or stderr")
+	}
+	if s := c.Sink; s != nil {
+		switch s.Type {
+		case "", "kafka", "stdout", "discard":
+		case "file":
+			if s.Path == "" {
+				p.add("sink.path is required for the file sink")
+			}
+		default:
+			p.add("sink.type must be kafka, stdout, file or discard")
+		}
+		if c.Verify != nil && c.Verify.Enabled && !s.UsesKafka() {
+			p.add("verify requires the kafka sink")
+		}
 	}
 	if len(c.Payloads) == 0 {
-		return fmt.Errorf("at least one payload is required")
+		p.add("at least one payload is required")
 	}
+	names := make(map[string]int)
 	for i, payload := range c.Payloads {
-		if payload.TemplatePath == "" {
-			return fmt.Errorf("payloads[%d].template_path is required", i)
+		if first, ok := names[payload.Name]; ok && payload.Name != "" {
+			p.add("payloads[%d].name %s is already used by payloads[%d]", i, payload.Name, first)
+		} else {
+			names[payload.Name] = i
+		}
+		if payload.BatchSize < 0 {
+			p.add("payloads[%d].batch_size must not be negative", i)
 		}
-		if payload.Topic == "" {
-			return fmt.Errorf("payloads[%d].topic is required", i)
+		if payload.Topic != "" {
+			if err := validateTopic(payload.Topic); err != nil {
+				p.add("payloads[%d].topic: %v", i, err)
+			}
 		}
+		for _, name := range payload.Cluster {
+			if c.Cluster(name) == nil {
+				p.add("payloads[%d].cluster %s is not configured", i, name)
+			}
+		}
+		if payload.Replay != nil {
+			// Replayed records may carry their own topic
+			p.addAll(fmt.Sprintf("payloads[%d].replay: ", i), payload.Replay.validate())
+			continue
+		}
+		c.validatePayload(i, &payload, &p)
+	}
+	for _, name := range sortedKeys(c.Pools) {
+		pool := c.Pools[name]
+		if pool.Size < 1 {
+			p.add("pools.%s.size must be at least 1", name)
+		}
+		switch pool.Selection {
+		case "", "uniform":
+		case "hotkey":
+			if pool.HotKeys <= 0 || pool.HotKeys > 1 {
+				p.add("pools.%s.hot_keys must be in (0, 1]", name)
+			}
+			if pool.HotTraffic < 0 || pool.HotTraffic > 1 {
+				p.add("pools.%s.hot_traffic must be in [0, 1]", name)
+			}
+		default:
+			p.add("pools.%s.selection must be uniform or hotkey", name)
+		}
+	}
+	if c.Verify != nil && c.Verify.Enabled && c.Verify.Timeout < 0 {
+		p.add("verify.timeout must not be negative")
 	}
 	if c.Scheduler != nil && c.Scheduler.Enabled {
 		if c.Scheduler.Interval <= 0 {
-			return fmt.Errorf("scheduler.interval must be positive")
+			p.add("scheduler.interval must be positive")
 		}
 		if c.Scheduler.WorkerPoolSize < 1 {
-			return fmt.Errorf("scheduler.worker_pool_size must be at least 1")
+			p.add("scheduler.worker_pool_size must be at least 1")
+		}
+		if c.Scheduler.Duration < 0 {
+			p.add("scheduler.duration must not be negative")
+		}
+	}
+	return p.err()
+}
+
+// validatePayload checks the generation, format and simulation settings of
+// a payload that is not replayed
+func (c *Config) validatePayload(i int, payload *PayloadConfig, p *Problems) {
+	if payload.JSONSchema != nil {
+		if payload.TemplatePath != "" {
+			p.add("payloads[%d] must set only one of template_path or json_schema", i)
+		}
+		if payload.JSONSchema.Path == "" {
+			p.add("payloads[%d].json_schema.path is required", i)
+		}
+		if prob := payload.JSONSchema.OptionalProbability; prob < 0 || prob > 1 {
+			p.add("payloads[%d].json_schema.optional_probability must be in [0, 1]", i)
+		}
+		if payload.Lifecycle != nil {
+			p.add("payloads[%d].lifecycle requires template_path", i)
+		}
+	} else if payload.TemplatePath == "" {
+		p.add("payloads[%d].template_path is required", i)
+	}
+	if payload.Topic == "" {
+		p.add("payloads[%d].topic is required", i)
+	}
+	switch payload.Format {
+	case "", "json":
+	case "avro":
+		if payload.Avro == nil || payload.Avro.SchemaPath == "" {
+			p.add("payloads[%d].avro.schema_path is required for avro format", i)
+		}
+		if payload.Avro != nil && payload.Avro.Registry != nil && payload.Avro.Registry.URL == "" {
+			p.add("payloads[%d].avro.registry.url is required", i)
+		}
+	case "protobuf":
+		pb := payload.Protobuf
+		if pb == nil || (pb.ProtoPath == "") == (pb.DescriptorSetPath == "") {
+			p.add("payloads[%d].protobuf requires exactly one of proto_path or descriptor_set_path", i)
+		}
+		if pb != nil && pb.Message == "" {
+			p.add("payloads[%d].protobuf.message is required", i)
+		}
+		if pb != nil && pb.Registry != nil {
+			if pb.Registry.URL == "" {
+				p.add("payloads[%d].protobuf.registry.url is required", i)
+			}
+			if pb.ProtoPath == "" {
+				p.add("payloads[%d].protobuf.registry requires proto_path", i)
+			}
+		}
+	default:
+		p.add("payloads[%d].format must be json, avro or protobuf", i)
+	}
+	switch payload.OnViolation {
+	case "", "drop", "log", "abort":
+	default:
+		p.add("payloads[%d].on_violation must be drop, log or abort", i)
+	}
+	if payload.Faults != nil {
+		p.addAll(fmt.Sprintf("payloads[%d].faults: ", i), payload.Faults.validate())
+	}
+	if payload.Delivery != nil {
+		p.addAll(fmt.Sprintf("payloads[%d].delivery: ", i), payload.Delivery.validate())
+	}
+	if payload.Lifecycle != nil {
+		p.addAll(fmt.Sprintf("payloads[%d].lifecycle: ", i), payload.Lifecycle.validate())
+	}
+}
+
+// validateTopic checks a topic name against the rules of the brokers
+func validateTopic(topic string) error {
+	if topic == "." || topic == ".." {
+		return fmt.Errorf("%q is not a valid topic name", topic)
+	}
+	if len(topic) > 249 {
+		return fmt.Errorf("topic name is longer than 249 characters")
+	}
+	for _, r := range topic {
+		if !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && !('0' <= r && r <= '9') && r != '.' && r != '_' && r != '-' {
+			return fmt.Errorf("topic %q contains %q, only letters, digits, '.', '_' and '-' are allowed", topic, r)
 		}
 	}
 	return nil
 }
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// setDefaults fills in the stamp header names
+func (s *StampConfig) setDefaults() {
+	if s == nil || !s.Enabled {
+		return
+	}
+	if s.TimestampHeader == "" {
+		s.TimestampHeader = "x-sent-at"
+	}
+	if s.SequenceHeader == "" {
+		s.SequenceHeader = "x-seq"
+	}
+}
+
+// setDefaults fills in the client, transaction and retry defaults of a
+// cluster
+func (k *KafkaConfig) setDefaults() {
+	if k.ClientID == "" {
+		k.ClientID = "kafka-pusher"
+	}
+	if k.Timeout == 0 {
+		k.Timeout = 10 * time.Second
+	}
+	k.Stamp.setDefaults()
+	if t := k.Transaction; t != nil {
+		if t.ID == "" {
+			t.ID = k.ClientID
+		}
+		if t.Timeout == 0 {
+			t.Timeout = 60 * time.Second
+		}
+		if t.Scope == "" {
+			t.Scope = "batch"
+		}
+		k.Idempotent = true
+	}
+	if r := k.Retry; r != nil {
+		if r.Attempts == 0 {
+			r.Attempts = 3
+		}
+		if r.InitialBackoff == 0 {
+			r.InitialBackoff = 100 * time.Millisecond
+		}
+		if r.MaxBackoff == 0 {
+			r.MaxBackoff = 10 * time.Second
+		}
+		if r.Jitter == 0 {
+			r.Jitter = 0.2
+		}
+	}
+}
+
+// validate checks the connection, retry and dead-letter settings
+func (k *KafkaConfig) validate() error {
+	var p Problems
+	if k.Topic != "" {
+		if err := validateTopic(k.Topic); err != nil {
+			p.add("topic: %v", err)
+		}
+	}
+	// -1 leaves the partition to the balancer
+	if k.Partition < -1 {
+		p.add("partition must be -1 for automatic or a partition number")
+	} else if c := k.CreateTopics; c != nil && c.Partitions > 0 && k.Partition >= c.Partitions {
+		p.add("partition %d is out of range for topics created with %d partitions", k.Partition, c.Partitions)
+	}
+	if k.TLS != nil && k.TLS.Enabled && (k.TLS.CertFile == "") != (k.TLS.KeyFile == "") {
+		p.add("tls.cert_file and tls.key_file must be set together")
+	}
+	if k.SASL != nil {
+		switch k.SASL.Mechanism {
+		case "plain", "scram-sha-256", "scram-sha-512":
+		default:
+			p.add("sasl.mechanism must be plain, scram-sha-256 or scram-sha-512")
+		}
+		if k.SASL.Username == "" {
+			p.add("sasl.username is required")
+		}
+	}
+	if r := k.Retry; r != nil {
+		if r.Attempts < 1 {
+			p.add("retry.attempts must be at least 1")
+		}
+		if r.InitialBackoff <= 0 || r.MaxBackoff < r.InitialBackoff {
+			p.add("retry.max_backoff must not be less than a positive initial_backoff")
+		}
+		if r.Jitter < 0 || r.Jitter > 1 {
+			p.add("retry.jitter must be in [0, 1]")
+		}
+	}
+	if k.DeadLetter != nil && k.DeadLetter.Path == "" {
+		p.add("dead_letter.path is required")
+	}
+	if k.Idempotent && k.Async {
+		p.add("idempotent and transactional producing cannot be async")
+	}
+	if t := k.Transaction; t != nil {
+		switch t.Scope {
+		case "", "batch", "tick":
+		default:
+			p.add("transaction.scope must be batch or tick")
+		}
+		if t.Timeout < 0 {
+			p.add("transaction.timeout must not be negative")
+		}
+		if t.AbortRate < 0 || t.AbortRate > 1 {
+			p.add("transaction.abort_rate must be in [0, 1]")
+		}
+	}
+	if c := k.CreateTopics; c != nil {
+		if c.Partitions < 0 || c.ReplicationFactor < 0 {
+			p.add("create_topics.partitions and replication_factor must not be negative")
+		}
+		if k.SkipPreflight {
+			p.add("create_topics requires the pre-flight checks, remove skip_preflight")
+		}
+	}
+	return p.err()
+}
+
+// validate checks the lifecycle state machine definition
+func (l *LifecycleConfig) validate() error {
+	var p Problems
+	if l.Initial == "" {
+		p.add("initial state is required")
+	}
+	if l.MaxEntities < 1 {
+		p.add("max_entities must be at least 1")
+	}
+	if len(l.Transitions[l.Initial]) == 0 {
+		p.add("initial state %s has no transitions", l.Initial)
+	}
+	for _, state := range sortedKeys(l.Transitions) {
+		transitions := l.Transitions[state]
+		var total float64
+		for _, t := range transitions {
+			if t.To == "" {
+				p.add("transitions.%s: target state is required", state)
+			}
+			if t.Probability <= 0 || t.Probability > 1 {
+				p.add("transitions.%s: probability of %s must be in (0, 1]", state, t.To)
+			}
+			if t.Delay < 0 {
+				p.add("transitions.%s: delay of %s must not be negative", state, t.To)
+			}
+			if t.MaxDelay != 0 && t.MaxDelay < t.Delay {
+				p.add("transitions.%s: max_delay of %s must not be less than delay", state, t.To)
+			}
+			total += t.Probability
+		}
+		if math.Abs(total-1) > 1e-6 {
+			p.add("transitions.%s: probabilities must sum to 1, got %g", state, total)
+		}
+	}
+	return p.err()
+}
+
+// validate checks the fault rate and weights
+func (f *FaultsConfig) validate() error {
+	var p Problems
+	if f.Rate <= 0 || f.Rate > 1 {
+		p.add("rate must be in (0, 1]")
+	}
+	var total float64
+	for _, name := range sortedKeys(f.Types) {
+		weight := f.Types[name]
+		if !slices.Contains(FaultTypes, name) {
+			p.add("unknown fault type %q, expected one of %s", name, strings.Join(FaultTypes, ", "))
+		}
+		if weight < 0 {
+			p.add("types.%s weight must not be negative", name)
+		}
+		total += weight
+	}
+	if total == 0 {
+		p.add("at least one fault type needs a positive weight")
+	}
+	if f.OversizeBytes < 0 {
+		p.add("oversize_bytes must not be negative")
+	}
+	return p.err()
+}
+
+// validate checks the duplicate and reorder settings
+func (d *DeliveryConfig) validate() error {
+	var p Problems
+	if dup := d.Duplicates; dup != nil {
+		if dup.Rate <= 0 || dup.Rate > 1 {
+			p.add("duplicates.rate must be in (0, 1]")
+		}
+		if dup.Delay < 0 || (dup.MaxDelay != 0 && dup.MaxDelay < dup.Delay) {
+			p.add("duplicates.delay must not be negative and max_delay must not be below delay")
+		}
+		if dup.History < 1 {
+			p.add("duplicates.history must be at least 1")
+		}
+	}
+	if r := d.Reorder; r != nil {
+		if r.Rate <= 0 || r.Rate > 1 {
+			p.add("reorder.rate must be in (0, 1]")
+		}
+		switch r.Mode {
+		case "", "shuffle", "delay":
+		default:
+			p.add("reorder.mode must be shuffle or delay")
+		}
+		if r.Delay < 0 || (r.MaxDelay != 0 && r.MaxDelay < r.Delay) {
+			p.add("reorder.delay must not be negative and max_delay must not be below delay")
+		}
+		if r.Mode == "delay" && r.History < 1 {
+			p.add("reorder.history must be at least 1")
+		}
+	}
+	return p.err()
+}
+
+// setDefaults fills in the subject and timeout of a registry
+func (r *RegistryConfig) setDefaults(topic string) {
+	if r.Subject == "" {
+		r.Subject = topic + "-value"
+	}
+	if r.Timeout == 0 {
+		r.Timeout = 10 * time.Second
+	}
+}
+
+// validate checks the replay settings
+func (r *ReplayConfig) validate() error {
+	var p Problems
+	if r.Path == "" {
+		p.add("path is required")
+	}
+	switch r.Mode {
+	case "", "asap":
+	case "rate":
+		if r.Rate <= 0 {
+			p.add("rate must be positive in rate mode")
+		}
+	case "original":
+		if r.Speed <= 0 {
+			p.add("speed must be positive")
+		}
+	default:
+		p.add("mode must be asap, rate or original")
+	}
+	return p.err()
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -167,3 +171,764 @@ func TestSetDefaults(t *testing.T) {
 		t.Errorf("Expected default log format text, got %s", cfg.Logging.Format)
 	}
 }
+
+func TestValidateLifecycle(t *testing.T) {
+	base := func(lc *LifecycleConfig) Config {
+		return Config{
+			Kafka: KafkaConfig{Brokers: []string{"localhost:9092"}},
+			Payloads: []PayloadConfig{
+				{TemplatePath: "./order.yaml", Topic: "orders", Lifecycle: lc},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		lc      *LifecycleConfig
+		wantErr bool
+	}{
+		{
+			name: "valid lifecycle",
+			lc: &LifecycleConfig{
+				Initial:     "CREATED",
+				MaxEntities: 10,
+				Transitions: map[string][]TransitionConfig{
+					"CREATED": {{To: "PAID", Probability: 0.9}, {To: "CANCELLED", Probability: 0.1}},
+					"PAID":    {{To: "SHIPPED", Probability: 1, Delay: time.Second, MaxDelay: 5 * time.Second}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing initial state",
+			lc: &LifecycleConfig{
+				MaxEntities: 10,
+				Transitions: map[string][]TransitionConfig{"CREATED": {{To: "PAID", Probability: 1}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "probabilities do not sum to one",
+			lc: &LifecycleConfig{
+				Initial:     "CREATED",
+				MaxEntities: 10,
+				Transitions: map[string][]TransitionConfig{
+					"CREATED": {{To: "PAID", Probability: 0.5}, {To: "CANCELLED", Probability: 0.2}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "max delay below delay",
+			lc: &LifecycleConfig{
+				Initial:     "CREATED",
+				MaxEntities: 10,
+				Transitions: map[string][]TransitionConfig{
+					"CREATED": {{To: "PAID", Probability: 1, Delay: time.Minute, MaxDelay: time.Second}},
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base(tt.lc)
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateJSONSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload PayloadConfig
+		wantErr bool
+	}{
+		{
+			name:    "valid schema payload",
+			payload: PayloadConfig{Topic: "orders", JSONSchema: &JSONSchemaConfig{Path: "./order.schema.json", OptionalProbability: 0.5}},
+			wantErr: false,
+		},
+		{
+			name:    "both template and schema",
+			payload: PayloadConfig{TemplatePath: "./order.yaml", Topic: "orders", JSONSchema: &JSONSchemaConfig{Path: "./order.schema.json"}},
+			wantErr: true,
+		},
+		{
+			name:    "missing schema path",
+			payload: PayloadConfig{Topic: "orders", JSONSchema: &JSONSchemaConfig{}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid on_violation",
+			payload: PayloadConfig{TemplatePath: "./order.yaml", Topic: "orders", SchemaPath: "./order.schema.json", OnViolation: "ignore"},
+			wantErr: true,
+		},
+		{
+			name:    "optional probability out of range",
+			payload: PayloadConfig{Topic: "orders", JSONSchema: &JSONSchemaConfig{Path: "./order.schema.json", OptionalProbability: 1.5}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Kafka:    KafkaConfig{Brokers: []string{"localhost:9092"}},
+				Payloads: []PayloadConfig{tt.payload},
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		faults  *FaultsConfig
+		wantErr bool
+	}{
+		{"valid", &FaultsConfig{Rate: 0.1, Types: map[string]float64{"truncate": 1, "null_key": 2}}, false},
+		{"rate out of range", &FaultsConfig{Rate: 1.5, Types: map[string]float64{"truncate": 1}}, true},
+		{"unknown type", &FaultsConfig{Rate: 0.1, Types: map[string]float64{"explode": 1}}, true},
+		{"no positive weight", &FaultsConfig{Rate: 0.1, Types: map[string]float64{"truncate": 0}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Kafka:    KafkaConfig{Brokers: []string{"localhost:9092"}},
+				Payloads: []PayloadConfig{{TemplatePath: "./order.yaml", Topic: "orders", Faults: tt.faults}},
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDelivery(t *testing.T) {
+	tests := []struct {
+		name     string
+		delivery *DeliveryConfig
+		wantErr  bool
+	}{
+		{"valid", &DeliveryConfig{
+			Duplicates: &DuplicatesConfig{Rate: 0.1, Delay: time.Second, MaxDelay: time.Minute, History: 100},
+			Reorder:    &ReorderConfig{Rate: 0.2, Mode: "delay", Delay: time.Second, History: 100},
+		}, false},
+		{"delay without history", &DeliveryConfig{Reorder: &ReorderConfig{Rate: 0.2, Mode: "delay", Delay: time.Second}}, true},
+		{"duplicate rate out of range", &DeliveryConfig{Duplicates: &DuplicatesConfig{Rate: 0, History: 100}}, true},
+		{"max delay below delay", &DeliveryConfig{Duplicates: &DuplicatesConfig{Rate: 0.1, Delay: time.Minute, MaxDelay: time.Second, History: 100}}, true},
+		{"unknown reorder mode", &DeliveryConfig{Reorder: &ReorderConfig{Rate: 0.1, Mode: "reverse"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Kafka:    KafkaConfig{Brokers: []string{"localhost:9092"}},
+				Payloads: []PayloadConfig{{TemplatePath: "./order.yaml", Topic: "orders", Delivery: tt.delivery}},
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyDefaults(t *testing.T) {
+	cfg := Config{
+		Kafka:    KafkaConfig{Brokers: []string{"localhost:9092"}},
+		Payloads: []PayloadConfig{{TemplatePath: "./order.yaml", Topic: "orders"}},
+		Verify:   &VerifyConfig{Enabled: true},
+	}
+	cfg.setDefaults()
+
+	if cfg.Verify.Header != "x-pusher-id" {
+		t.Errorf("Header = %q, want x-pusher-id", cfg.Verify.Header)
+	}
+	if cfg.Verify.Timeout != 30*time.Second {
+		t.Errorf("Timeout = %v, want 30s", cfg.Verify.Timeout)
+	}
+
+	cfg.Verify.Timeout = -time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil, want error for negative timeout")
+	}
+}
+
+func TestValidateKafkaSecurity(t *testing.T) {
+	tests := []struct {
+		name    string
+		tls     *TLSConfig
+		sasl    *SASLConfig
+		wantErr bool
+	}{
+		{"tls with client certificate", &TLSConfig{Enabled: true, CertFile: "client.crt", KeyFile: "client.key"}, nil, false},
+		{"tls certificate without key", &TLSConfig{Enabled: true, CertFile: "client.crt"}, nil, true},
+		{"scram", nil, &SASLConfig{Mechanism: "scram-sha-512", Username: "pusher", Password: "secret"}, false},
+		{"unknown mechanism", nil, &SASLConfig{Mechanism: "gssapi", Username: "pusher"}, true},
+		{"missing username", nil, &SASLConfig{Mechanism: "plain"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Kafka:    KafkaConfig{Brokers: []string{"localhost:9092"}, TLS: tt.tls, SASL: tt.sasl},
+				Payloads: []PayloadConfig{{TemplatePath: "./order.yaml", Topic: "orders"}},
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePartition(t *testing.T) {
+	tests := []struct {
+		name      string
+		partition int
+		create    *CreateTopicsConfig
+		wantErr   bool
+	}{
+		{"automatic", -1, nil, false},
+		{"automatic with created topics", -1, &CreateTopicsConfig{Partitions: 3}, false},
+		{"fixed", 2, &CreateTopicsConfig{Partitions: 3}, false},
+		{"beyond created topics", 3, &CreateTopicsConfig{Partitions: 3}, true},
+		{"below automatic", -2, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{
+				Kafka:    KafkaConfig{Brokers: []string{"localhost:9092"}, Partition: tt.partition, CreateTopics: tt.create},
+				Payloads: []PayloadConfig{{TemplatePath: "./order.yaml", Topic: "orders"}},
+			}
+			err := cfg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryDefaults(t *testing.T) {
+	cfg := Config{
+		Kafka:    KafkaConfig{Brokers: []string{"localhost:9092"}, Retry: &RetryConfig{}},
+		Payloads: []PayloadConfig{{TemplatePath: "./order.yaml", Topic: "orders"}},
+	}
+	cfg.setDefaults()
+
+	r := cfg.Kafka.Retry
+	if r.Attempts != 3 || r.InitialBackoff != 100*time.Millisecond || r.MaxBackoff != 10*time.Second || r.Jitter != 0.2 {
+		t.Errorf("Retry defaults = %+v", r)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	r.MaxBackoff = time.Millisecond
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil, want error for max_backoff below initial_backoff")
+	}
+
+	r.MaxBackoff = time.Second
+	cfg.Kafka.DeadLetter = &DeadLetterConfig{}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil, want error for missing dead_letter.path")
+	}
+}
+
+func TestTransactionDefaults(t *testing.T) {
+	cfg := Config{
+		Kafka:    KafkaConfig{Brokers: []string{"localhost:9092"}, ClientID: "pusher-1", Transaction: &TransactionConfig{}},
+		Payloads: []PayloadConfig{{TemplatePath: "./order.yaml", Topic: "orders"}},
+	}
+	cfg.setDefaults()
+
+	txn := cfg.Kafka.Transaction
+	if txn.ID != "pusher-1" || txn.Timeout != 60*time.Second || txn.Scope != "batch" {
+		t.Errorf("Transaction defaults = %+v", txn)
+	}
+	if !cfg.Kafka.Idempotent {
+		t.Error("Idempotent = false, want true with a transaction")
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	txn.AbortRate = 1.5
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil, want error for abort_rate above 1")
+	}
+
+	txn.AbortRate = 0.1
+	cfg.Kafka.Async = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil, want error for async transactions")
+	}
+}
+
+func TestLoadClusters(t *testing.T) {
+	content := `
+kafka:
+  brokers: [primary:9092]
+clusters:
+  dr:
+    brokers: [dr:9092]
+    client_id: pusher-dr
+    retry: {}
+payloads:
+  - template_path: ./order.yaml
+    topic: orders
+    cluster: [default, dr]
+  - template_path: ./audit.yaml
+    topic: audit
+    cluster: dr
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Payloads[0].Cluster; len(got) != 2 || got[1] != "dr" {
+		t.Errorf("payloads[0].cluster = %v, want [default dr]", got)
+	}
+	if got := cfg.Payloads[1].Cluster; len(got) != 1 || got[0] != "dr" {
+		t.Errorf("payloads[1].cluster = %v, want [dr]", got)
+	}
+
+	dr := cfg.Cluster("dr")
+	if dr == nil || dr.Timeout != 10*time.Second || dr.Retry.Attempts != 3 {
+		t.Errorf("cluster dr = %+v, want defaults applied", dr)
+	}
+	if cfg.Cluster(DefaultCluster) != &cfg.Kafka {
+		t.Error("default cluster is not the kafka section")
+	}
+
+	cfg.Payloads[1].Cluster = ClusterNames{"west"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate() error = nil, want error for an unknown cluster")
+	}
+
+	// The kafka section needs no brokers when no payload uses it
+	cfg.Kafka.Brokers = nil
+	cfg.Payloads = cfg.Payloads[1:]
+	cfg.Payloads[0].Cluster = ClusterNames{"dr"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("PUSHER_HOST", "kafka-1")
+	t.Setenv("PUSHER_EMPTY", "")
+
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "${PUSHER_HOST}:9092", want: "kafka-1:9092"},
+		{in: "${PUSHER_EMPTY:-fallback}", want: "fallback"},
+		{in: "${PUSHER_UNSET:-}", want: ""},
+		{in: "${PUSHER_EMPTY}", want: ""},
+		{in: "price $5, $$HOME, ${PUSHER_HOST:-x}", want: "price $5, $HOME, kafka-1"},
+		{in: "${PUSHER_UNSET}", wantErr: true},
+		{in: "${PUSHER_HOST", wantErr: true},
+		{in: "${1BAD}", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ExpandEnv(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandEnv(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ExpandEnv(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	content := `
+kafka:
+  brokers: ["${PUSHER_BROKER:-localhost:9092}"]
+  # comments may mention ${PUSHER_UNSET}
+  sasl:
+    mechanism: plain
+    username: ${PUSHER_USER}
+payloads:
+  - template_path: ./order.yaml
+    topic: orders
+    batch_size: ${PUSHER_BATCH}
+  - template_path: ./audit.yaml
+    topic: audit
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PUSHER_USER", "svc")
+	t.Setenv("PUSHER_BATCH", "50")
+	t.Setenv("KAFKA_PUSHER_KAFKA_BROKERS", "k1:9092, k2:9092")
+	t.Setenv("KAFKA_PUSHER_KAFKA_SASL_PASSWORD", "secret")
+	t.Setenv("KAFKA_PUSHER_KAFKA_TIMEOUT", "3s")
+	t.Setenv("KAFKA_PUSHER_PAYLOADS_1_BATCH_SIZE", "7")
+	t.Setenv("KAFKA_PUSHER_CLUSTERS_DR_BROKERS", "dr:9092")
+	t.Setenv("KAFKA_PUSHER_SEED", "42")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := cfg.Kafka.Brokers; len(got) != 2 || got[1] != "k2:9092" {
+		t.Errorf("kafka.brokers = %v, want [k1:9092 k2:9092]", got)
+	}
+	if s := cfg.Kafka.SASL; s == nil || s.Username != "svc" || s.Password != "secret" {
+		t.Errorf("kafka.sasl = %+v, want interpolated username and overridden password", s)
+	}
+	if cfg.Kafka.Timeout != 3*time.Second {
+		t.Errorf("kafka.timeout = %v, want 3s", cfg.Kafka.Timeout)
+	}
+	if cfg.Payloads[0].BatchSize != 50 || cfg.Payloads[1].BatchSize != 7 {
+		t.Errorf("batch sizes = %d, %d, want 50, 7", cfg.Payloads[0].BatchSize, cfg.Payloads[1].BatchSize)
+	}
+	if dr := cfg.Cluster("dr"); dr == nil || len(dr.Brokers) != 1 || dr.ClientID != "kafka-pusher" {
+		t.Errorf("cluster dr = %+v, want a new cluster with defaults", dr)
+	}
+	if cfg.Seed == nil || *cfg.Seed != 42 {
+		t.Errorf("seed = %v, want 42", cfg.Seed)
+	}
+
+	for name, value := range map[string]string{
+		"KAFKA_PUSHER_KAFKA_BROKER":          "typo:9092",
+		"KAFKA_PUSHER_PAYLOADS_5_BATCH_SIZE": "1",
+		"KAFKA_PUSHER_KAFKA_TIMEOUT":         "soon",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := Load(path); err == nil {
+				t.Errorf("Load() error = nil, want error for %s", name)
+			}
+		})
+	}
+
+	os.Unsetenv("PUSHER_USER")
+	if _, err := Load(path); err == nil {
+		t.Error("Load() error = nil, want error for an unset variable")
+	}
+}
+
+func TestLoadOverridePrecedence(t *testing.T) {
+	content := `
+kafka:
+  brokers: [file:9092]
+  client_id: from-file
+  timeout: 5s
+logging:
+  level: warn
+payloads:
+  - name: orders
+    template_path: ./order.yaml
+    topic: orders
+    batch_size: 10
+  - name: audit-log
+    template_path: ./audit.yaml
+    topic: audit
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KAFKA_PUSHER_KAFKA_BROKERS", "env:9092")
+	t.Setenv("KAFKA_PUSHER_KAFKA_CLIENT_ID", "from-env")
+	t.Setenv("KAFKA_PUSHER_LOGGING_LEVEL", "info")
+
+	set, err := ParseOverride("kafka.client_id=from-flag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path,
+		set,
+		Override{Path: "logging.level", Value: "debug"},
+		Override{Path: "payloads.*.batch_size", Value: "25"},
+		Override{Path: "payloads.audit-log.topic", Value: "audit-test"},
+		Override{Path: "scheduler.interval", Value: "2s"},
+		Override{Path: "scheduler.enabled", Value: "true"},
+		Override{Path: "scheduler.duration", Value: "1m"},
+	)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	// flags > env > file
+	if cfg.Kafka.ClientID != "from-flag" || cfg.Logging.Level != "debug" {
+		t.Errorf("client_id = %s, level = %s, want the flag values", cfg.Kafka.ClientID, cfg.Logging.Level)
+	}
+	if got := cfg.Kafka.Brokers; len(got) != 1 || got[0] != "env:9092" {
+		t.Errorf("kafka.brokers = %v, want the env value", got)
+	}
+	if cfg.Kafka.Timeout != 5*time.Second {
+		t.Errorf("kafka.timeout = %v, want the file value", cfg.Kafka.Timeout)
+	}
+	if cfg.Payloads[0].BatchSize != 25 || cfg.Payloads[1].BatchSize != 25 {
+		t.Errorf("batch sizes = %d, %d, want 25 for every payload", cfg.Payloads[0].BatchSize, cfg.Payloads[1].BatchSize)
+	}
+	if cfg.Payloads[0].Topic != "orders" || cfg.Payloads[1].Topic != "audit-test" {
+		t.Errorf("topics = %s, %s, want orders, audit-test", cfg.Payloads[0].Topic, cfg.Payloads[1].Topic)
+	}
+	if s := cfg.Scheduler; s == nil || !s.Enabled || s.Interval != 2*time.Second || s.Duration != time.Minute || s.WorkerPoolSize != 1 {
+		t.Errorf("scheduler = %+v, want a new section with defaults", s)
+	}
+
+	for _, o := range []Override{
+		{Path: "payloads.missing.topic", Value: "x"},
+		{Path: "payloads.*.nope", Value: "x"},
+		{Path: "kafka.timeout", Value: "soon"},
+	} {
+		if _, err := Load(path, o); err == nil {
+			t.Errorf("Load() error = nil, want error for %s=%s", o.Path, o.Value)
+		}
+	}
+	if _, err := ParseOverride("no-value"); err == nil {
+		t.Error("ParseOverride() error = nil, want error without =")
+	}
+}
+
+func TestLoadComposition(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": `
+include:
+  - common.yaml
+  - payloads/*.yaml
+kafka:
+  client_id: base
+profiles:
+  staging:
+    kafka:
+      brokers: [staging:9092]
+    payloads:
+      - name: orders
+        topic: orders-staging
+`,
+		"common.yaml": `
+kafka:
+  brokers: [common:9092]
+  client_id: common
+  timeout: 3s
+`,
+		"payloads/a.yaml": `
+payloads:
+  - name: orders
+    template_path: ./order.yaml
+    topic: orders
+    batch_size: 5
+    group: checkout
+`,
+		"payloads/b.yaml": `
+payloads:
+  - name: audit
+    template_path: ./audit.yaml
+    topic: audit
+    group: compliance
+groups:
+  compliance: false
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "config.yaml")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Kafka.ClientID != "base" || cfg.Kafka.Timeout != 3*time.Second || cfg.Kafka.Brokers[0] != "common:9092" {
+		t.Errorf("kafka = %+v, want the including file over its includes", cfg.Kafka)
+	}
+	if len(cfg.Payloads) != 1 || cfg.Payloads[0].Name != "orders" {
+		t.Errorf("payloads = %+v, want only orders with compliance switched off", cfg.Payloads)
+	}
+
+	cfg, err = LoadWith(path, LoadOptions{Profile: "staging", Groups: map[string]bool{"compliance": true}})
+	if err != nil {
+		t.Fatalf("LoadWith() error = %v", err)
+	}
+	if cfg.Kafka.Brokers[0] != "staging:9092" {
+		t.Errorf("kafka.brokers = %v, want the profile value", cfg.Kafka.Brokers)
+	}
+	if len(cfg.Payloads) != 2 {
+		t.Fatalf("payloads = %+v, want orders and audit", cfg.Payloads)
+	}
+	// The profile merges into the payload with the same name
+	if p := cfg.Payloads[0]; p.Topic != "orders-staging" || p.BatchSize != 5 {
+		t.Errorf("orders = %+v, want the profile topic and the included batch size", p)
+	}
+
+	for name, opts := range map[string]LoadOptions{
+		"unknown profile": {Profile: "prod"},
+		"unknown group":   {Groups: map[string]bool{"billing": false}},
+	} {
+		if _, err := LoadWith(path, opts); err == nil {
+			t.Errorf("LoadWith() error = nil, want error for %s", name)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "common.yaml"), []byte("include: config.yaml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Load() error = %v, want an include cycle", err)
+	}
+}
+
+func TestLoadReportsAllProblems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+kafka:
+  brokers: [localhost:9092]
+  partiton: 2
+  partition: -2
+  topic: ".."
+payloads:
+  - name: orders
+    template_path: ./order.yaml
+    topic: "orders topic"
+    batch_size: -3
+  - name: orders
+    template_path: ./order.yaml
+    topic: orders
+    batchsize: 3
+profiles:
+  dev:
+    kafka:
+      broker: [dev:9092]
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadWith(path, LoadOptions{Check: func(*Config) error {
+		return Problems{fmt.Errorf("payloads[0].template_path: missing")}
+	}})
+	var problems Problems
+	if !errors.As(err, &problems) {
+		t.Fatalf("Load() error = %v, want Problems", err)
+	}
+	want := []string{
+		`config.yaml:4: unknown field "partiton" in kafka, did you mean "partition"?`,
+		`config.yaml:15: unknown field "batchsize" in payloads[1], did you mean "batch_size"?`,
+		`config.yaml:19: unknown field "broker" in profiles.dev.kafka, did you mean "brokers"?`,
+		"kafka.topic:",
+		"kafka.partition must be -1 for automatic or a partition number",
+		"payloads[0].batch_size must not be negative",
+		"payloads[0].topic:",
+		"payloads[1].name orders is already used by payloads[0]",
+		"payloads[0].template_path: missing",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("got %d problems, want %d:\n%v", len(problems), len(want), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(problems[i].Error(), w) {
+			t.Errorf("problem %d = %q, want it to contain %q", i, problems[i], w)
+		}
+	}
+}
+
+func TestLoadReportsOverrideProblems(t *testing.T) {
+	content := `
+kafka:
+  brokers: [localhost:9092]
+payloads:
+  - name: orders
+    template_path: ./order.yaml
+    topic: orders
+    group: billing
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KAFKA_PUSHER_KAFKA_TIMEOUT", "soon")
+	t.Setenv("KAFKA_PUSHER_KAFKA_NOPE", "1")
+
+	_, err := LoadWith(path, LoadOptions{
+		Overrides: []Override{
+			{Path: "kafka.partition", Value: "-2"},
+			{Path: "payloads.0.batch_size", Value: "many"},
+			{Path: "kafka.nope", Value: "1"},
+		},
+		Groups: map[string]bool{"audit": true},
+	})
+	var problems Problems
+	if !errors.As(err, &problems) {
+		t.Fatalf("LoadWith() error = %v, want Problems", err)
+	}
+	want := []string{
+		"environment override KAFKA_PUSHER_KAFKA_NOPE does not name a config field",
+		"environment override KAFKA_PUSHER_KAFKA_TIMEOUT:",
+		"override payloads.0.batch_size:",
+		"override kafka.nope does not name a config field",
+		"no payload belongs to group audit",
+		"kafka.partition must be -1 for automatic or a partition number",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("got %d problems, want %d:\n%v", len(problems), len(want), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(problems[i].Error(), w) {
+			t.Errorf("problem %d = %q, want it to contain %q", i, problems[i], w)
+		}
+	}
+}
+
+func TestValidateTopic(t *testing.T) {
+	for topic, valid := range map[string]bool{
+		"orders":                 true,
+		"orders.v1_eu-west":      true,
+		".":                      false,
+		"..":                     false,
+		"orders/v1":              false,
+		strings.Repeat("a", 249): true,
+		strings.Repeat("a", 250): false,
+	} {
+		if err := validateTopic(topic); (err == nil) != valid {
+			t.Errorf("validateTopic(%q) error = %v, want valid %v", topic, err, valid)
+		}
+	}
+}
+
+func TestProblemsError(t *testing.T) {
+	var p Problems
+	p.add("first")
+	if p.Error() != "first" {
+		t.Errorf("Error() = %q, want the single problem", p.Error())
+	}
+	p.addAll("kafka.", Problems{fmt.Errorf("second"), fmt.Errorf("third")})
+	if want := "3 problems:\n  - first\n  - kafka.second\n  - kafka.third"; p.Error() != want {
+		t.Errorf("Error() = %q, want %q", p.Error(), want)
+	}
+}
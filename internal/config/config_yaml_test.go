@@ -220,3 +220,64 @@ payloads:
 		})
 	}
 }
+
+// TestConfigYAMLPools tests entity pool configuration in short and full form
+func TestConfigYAMLPools(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config_pools.yaml")
+
+	yamlContent := `kafka:
+  brokers:
+    - localhost:9092
+
+pools:
+  customers: 1000
+  merchants:
+    size: 50
+    selection: hotkey
+    fields:
+      id: "{{@uuid}}"
+      mcc: "{{@rnd|4}}"
+
+payloads:
+  - template_path: ./payload.yaml
+    topic: orders
+`
+
+	if err := os.WriteFile(configPath, []byte(yamlContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config: %v", err)
+	}
+
+	cfg, err := Load(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	customers, ok := cfg.Pools["customers"]
+	if !ok {
+		t.Fatal("Expected customers pool")
+	}
+	if customers.Size != 1000 {
+		t.Errorf("Expected customers size 1000, got %d", customers.Size)
+	}
+	if customers.Selection != "uniform" {
+		t.Errorf("Expected default selection uniform, got %s", customers.Selection)
+	}
+	if customers.Fields["id"] != "{{@uuid}}" {
+		t.Errorf("Expected default id field, got %v", customers.Fields)
+	}
+
+	merchants := cfg.Pools["merchants"]
+	if merchants.Size != 50 || len(merchants.Fields) != 2 {
+		t.Errorf("Unexpected merchants pool: %+v", merchants)
+	}
+	if merchants.HotKeys != 0.2 || merchants.HotTraffic != 0.8 {
+		t.Errorf("Expected default hot key settings, got %v/%v", merchants.HotKeys, merchants.HotTraffic)
+	}
+
+	merchants.Selection = "zipf"
+	cfg.Pools["merchants"] = merchants
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected error for unknown selection")
+	}
+}
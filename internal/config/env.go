@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override config fields,
+// e.g. KAFKA_PUSHER_KAFKA_BROKERS or KAFKA_PUSHER_PAYLOADS_0_BATCH_SIZE
+const EnvPrefix = "KAFKA_PUSHER_"
+
+// ProfileEnv selects the profile when none is given on the command line
+const ProfileEnv = EnvPrefix + "PROFILE"
+
+// ExpandEnv replaces ${VAR} and ${VAR:-default} with the value of the
+// environment variable. The default is used when the variable is unset or
+// empty, and $$ stands for a literal $. A variable without a default that is
+// not set is an error.
+func ExpandEnv(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			name, def, hasDefault := strings.Cut(s[i+2:i+end], ":-")
+			if !validEnvName(name) {
+				return "", fmt.Errorf("invalid variable name %q", name)
+			}
+			value, ok := os.LookupEnv(name)
+			switch {
+			case value == "" && hasDefault:
+				value = def
+			case !ok:
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			b.WriteString(value)
+			i += end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// validEnvName reports whether name is a shell variable name
+func validEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !('A' <= r && r <= 'Z') && !('a' <= r && r <= 'z') && (i == 0 || !('0' <= r && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExpandEnvNode expands environment variables in every scalar value of a
+// YAML document. Keys and comments are left as they are. Plain scalars are
+// resolved again after expansion, so `${BATCH_SIZE}` may fill a number.
+func ExpandEnvNode(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, err := ExpandEnv(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		if value != node.Value {
+			node.Value = value
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := ExpandEnvNode(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := ExpandEnvNode(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyEnvOverrides sets the config fields named by EnvPrefix variables.
+// The rest of a variable name is the YAML path in upper case joined by
+// underscores, with list indexes, payload names and map keys as path
+// elements.
+func applyEnvOverrides(c *Config, environ []string) error {
+	var names []string
+	values := make(map[string]string)
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) || name == EnvPrefix || name == ProfileEnv {
+			continue
+		}
+		names = append(names, name)
+		values[name] = value
+	}
+	// Apply in a stable order so that overrides of overlapping paths are deterministic
+	sort.Strings(names)
+
+	var p Problems
+	for _, name := range names {
+		matched, err := setOverride(reflect.ValueOf(c).Elem(), strings.TrimPrefix(name, EnvPrefix), values[name])
+		if err != nil {
+			p.add("%s: %w", name, err)
+		} else if !matched {
+			p.add("%s does not name a config field", name)
+		}
+	}
+	return p.err()
+}
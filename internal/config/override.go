@@ -0,0 +1,218 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Override sets the config field at a dot path, such as kafka.brokers or
+// payloads.orders.topic, from a command-line value. Payloads may be picked
+// by index or name, and * picks all of them.
+type Override struct {
+	Path  string
+	Value string
+}
+
+// ParseOverride parses an override written as path=value
+func ParseOverride(s string) (Override, error) {
+	path, value, ok := strings.Cut(s, "=")
+	if !ok || strings.TrimSpace(path) == "" {
+		return Override{}, fmt.Errorf("override must be path=value")
+	}
+	return Override{Path: strings.TrimSpace(path), Value: value}, nil
+}
+
+// applyOverrides sets the fields named by the overrides in order
+func applyOverrides(c *Config, overrides []Override) error {
+	var p Problems
+	for _, o := range overrides {
+		elements := strings.Split(o.Path, ".")
+		for i, element := range elements {
+			if element != "*" {
+				elements[i] = envKey(element)
+			}
+		}
+		matched, err := setOverride(reflect.ValueOf(c).Elem(), strings.Join(elements, "_"), o.Value)
+		if err != nil {
+			p.add("%s: %w", o.Path, err)
+		} else if !matched {
+			p.add("%s does not name a config field", o.Path)
+		}
+	}
+	return p.err()
+}
+
+// setOverride sets the field of v at the upper-case path. It reports
+// whether the path names a field.
+func setOverride(v reflect.Value, path, value string) (bool, error) {
+	if path == "" {
+		return true, setLeaf(v, value)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		// Missing optional sections are created on demand
+		elem := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			elem.Elem().Set(v.Elem())
+		}
+		matched, err := setOverride(elem.Elem(), path, value)
+		if matched && err == nil {
+			v.Set(elem)
+		}
+		return matched, err
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			if rest, ok := cutPathElement(path, strings.ToUpper(name)); ok {
+				if matched, err := setOverride(v.Field(i), rest, value); matched || err != nil {
+					return matched, err
+				}
+			}
+		}
+		return false, nil
+
+	case reflect.Slice:
+		element, rest, _ := strings.Cut(path, "_")
+		if element == "*" {
+			for i := 0; i < v.Len(); i++ {
+				matched, err := setOverride(v.Index(i), rest, value)
+				if !matched || err != nil {
+					return matched, err
+				}
+			}
+			return true, nil
+		}
+		if i, err := strconv.Atoi(element); err == nil {
+			if i < 0 || i >= v.Len() {
+				return true, fmt.Errorf("index %d out of range, %d configured", i, v.Len())
+			}
+			return setOverride(v.Index(i), rest, value)
+		}
+		// Elements with a name, such as payloads, may be picked by it
+		for i := 0; i < v.Len(); i++ {
+			if name := elementName(v.Index(i)); name != "" {
+				if rest, ok := cutPathElement(path, envKey(name)); ok {
+					return setOverride(v.Index(i), rest, value)
+				}
+			}
+		}
+		return false, nil
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return false, nil
+		}
+		// Existing keys match with any character outside [A-Z0-9] read as _
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, key := range keys {
+			if rest, ok := cutPathElement(path, envKey(key.String())); ok {
+				return setMapEntry(v, key.String(), rest, value)
+			}
+		}
+		// New entries of sections take the first element as their key,
+		// plain values the whole rest of the path
+		key, rest := path, ""
+		if elem := v.Type().Elem(); elem.Kind() == reflect.Struct || elem.Kind() == reflect.Pointer {
+			key, rest, _ = strings.Cut(path, "_")
+		}
+		return setMapEntry(v, strings.ToLower(key), rest, value)
+	}
+
+	return false, nil
+}
+
+// setMapEntry sets the entry of a map at the rest of the path, creating the
+// map and the entry as needed
+func setMapEntry(m reflect.Value, key, path, value string) (bool, error) {
+	elem := reflect.New(m.Type().Elem()).Elem()
+	k := reflect.ValueOf(key).Convert(m.Type().Key())
+	if existing := m.MapIndex(k); existing.IsValid() {
+		elem.Set(existing)
+	}
+	matched, err := setOverride(elem, path, value)
+	if !matched || err != nil {
+		return matched, err
+	}
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+	m.SetMapIndex(k, elem)
+	return true, nil
+}
+
+// setLeaf sets a field from an override value. Strings are taken as they
+// are, string lists may be comma-separated, and everything else is parsed
+// as YAML, e.g. `10s`, `true` or `[a, b]`.
+func setLeaf(v reflect.Value, value string) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+		return nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(value), "["):
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			list.Index(i).SetString(item)
+		}
+		v.Set(list)
+		return nil
+	}
+
+	if err := yaml.Unmarshal([]byte(value), v.Addr().Interface()); err != nil {
+		return fmt.Errorf("invalid value %q: %w", value, err)
+	}
+	return nil
+}
+
+// elementName returns the name field of a list element, if it has one
+func elementName(v reflect.Value) string {
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ","); name == "name" && v.Field(i).Kind() == reflect.String {
+			return v.Field(i).String()
+		}
+	}
+	return ""
+}
+
+// cutPathElement removes the element and its separator from the front of
+// path, reporting whether path starts with it
+func cutPathElement(path, element string) (string, bool) {
+	if path == element {
+		return "", true
+	}
+	return strings.CutPrefix(path, element+"_")
+}
+
+// envKey returns how a map key is written in a variable name
+func envKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problems collects every problem found in a configuration, so that they
+// can be fixed in one go
+type Problems []error
+
+// Error lists the problems, one per line
+func (p Problems) Error() string {
+	if len(p) == 1 {
+		return p[0].Error()
+	}
+	lines := make([]string, len(p))
+	for i, err := range p {
+		lines[i] = err.Error()
+	}
+	return fmt.Sprintf("%d problems:\n  - %s", len(p), strings.Join(lines, "\n  - "))
+}
+
+// Unwrap returns the problems
+func (p Problems) Unwrap() []error {
+	return p
+}
+
+// add records a problem
+func (p *Problems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Errorf(format, args...))
+}
+
+// addAll records err, or each problem it holds, with the section it was
+// found in as prefix
+func (p *Problems) addAll(prefix string, err error) {
+	if err == nil {
+		return
+	}
+	var nested Problems
+	if !errors.As(err, &nested) {
+		nested = Problems{err}
+	}
+	for _, e := range nested {
+		*p = append(*p, fmt.Errorf("%s%w", prefix, e))
+	}
+}
+
+// err returns the problems as an error, or nil when there are none
+func (p Problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
+
+// checkFields records every mapping key of node that does not name a field
+// of t, with its file and line. path is the YAML path of node.
+func checkFields(node *yaml.Node, t reflect.Type, file, path string, p *Problems) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := make(map[string]reflect.Type)
+		var names []string
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if name != "" && name != "-" {
+				fields[name] = t.Field(i).Type
+				names = append(names, name)
+			}
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Value == "<<" {
+				checkFields(node.Content[i+1], t, file, path, p)
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				p.add("%s:%d: unknown field %q in %s%s", file, key.Line, key.Value, sectionName(path), suggest(key.Value, names))
+				continue
+			}
+			checkFields(node.Content[i+1], field, file, joinPath(path, key.Value), p)
+		}
+
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i < len(node.Content); i += 2 {
+			checkFields(node.Content[i+1], t.Elem(), file, joinPath(path, node.Content[i].Value), p)
+		}
+
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			checkFields(item, t.Elem(), file, fmt.Sprintf("%s[%d]", path, i), p)
+		}
+	}
+}
+
+// joinPath appends a key to a YAML path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sectionName describes where a field was found
+func sectionName(path string) string {
+	if path == "" {
+		return "the top level"
+	}
+	return path
+}
+
+// suggest returns a hint naming the known field closest to a misspelt one
+func suggest(name string, known []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range known {
+		if d := editDistance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// editDistance returns the Levenshtein distance of two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
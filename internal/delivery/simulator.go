@@ -0,0 +1,214 @@
+package delivery
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+)
+
+// Stats holds delivery simulation statistics
+type Stats struct {
+	Duplicates uint64 // duplicates sent
+	Reordered  uint64 // messages moved within their batch
+	Delayed    uint64 // held-back messages sent in a later batch
+	Evicted    uint64 // pending duplicates dropped and held-back messages sent early because the history was full
+	Pending    int    // duplicates and held-back messages not yet sent
+}
+
+// pending is a message waiting to be sent
+type pending struct {
+	msg kafka.Message
+	due time.Time
+}
+
+// Simulator re-sends and reorders messages to exercise idempotent and
+// order-tolerant consumers. Pending messages are sent with the first batch
+// processed after they become due.
+type Simulator struct {
+	dup     *config.DuplicatesConfig
+	reorder *config.ReorderConfig
+
+	mu         sync.Mutex
+	duplicates []pending
+	held       []pending
+	stats      Stats
+	now        func() time.Time
+}
+
+// NewSimulator creates a delivery simulator
+func NewSimulator(cfg *config.DeliveryConfig) (*Simulator, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("delivery config is required")
+	}
+	if cfg.Duplicates == nil && cfg.Reorder == nil {
+		return nil, fmt.Errorf("duplicates or reorder is required")
+	}
+	if cfg.Duplicates != nil && cfg.Duplicates.History < 1 {
+		return nil, fmt.Errorf("duplicates history must be at least 1")
+	}
+	if cfg.Reorder != nil && cfg.Reorder.Mode == "delay" && cfg.Reorder.History < 1 {
+		return nil, fmt.Errorf("reorder history must be at least 1")
+	}
+
+	return &Simulator{
+		dup:     cfg.Duplicates,
+		reorder: cfg.Reorder,
+		now:     time.Now,
+	}, nil
+}
+
+// Process returns the messages to send for a batch: due held-back messages
+// and duplicates first, then the batch with a share of messages held back
+// or shuffled. A nil simulator returns the batch unchanged.
+// This method is thread-safe
+func (s *Simulator) Process(batch []kafka.Message) []kafka.Message {
+	if s == nil {
+		return batch
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var out []kafka.Message
+	var released []kafka.Message
+
+	released, s.held = takeDue(s.held, now)
+	s.stats.Delayed += uint64(len(released))
+	out = append(out, released...)
+
+	released, s.duplicates = takeDue(s.duplicates, now)
+	s.stats.Duplicates += uint64(len(released))
+	out = append(out, released...)
+
+	kept := batch
+	if s.reorder != nil {
+		if s.reorder.Mode == "delay" {
+			released, kept = s.holdBack(batch, now)
+			out = append(out, released...)
+		} else {
+			kept = s.shuffle(batch)
+		}
+	}
+
+	if s.dup != nil {
+		for _, msg := range kept {
+			if rand.Float64() >= s.dup.Rate {
+				continue
+			}
+			s.duplicates = append(s.duplicates, pending{msg: msg, due: now.Add(randomDelay(s.dup.Delay, s.dup.MaxDelay))})
+			if over := len(s.duplicates) - s.dup.History; over > 0 {
+				// Evict the oldest pending duplicates
+				s.duplicates = append(s.duplicates[:0], s.duplicates[over:]...)
+				s.stats.Evicted += uint64(over)
+			}
+		}
+	}
+
+	return append(out, kept...)
+}
+
+// Drain returns all pending messages regardless of their due time
+func (s *Simulator) Drain() []kafka.Message {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]kafka.Message, 0, len(s.held)+len(s.duplicates))
+	for _, p := range s.held {
+		out = append(out, p.msg)
+	}
+	for _, p := range s.duplicates {
+		out = append(out, p.msg)
+	}
+	s.stats.Delayed += uint64(len(s.held))
+	s.stats.Duplicates += uint64(len(s.duplicates))
+	s.held, s.duplicates = nil, nil
+	return out
+}
+
+// Stats returns delivery simulation statistics
+func (s *Simulator) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.Pending = len(s.held) + len(s.duplicates)
+	return stats
+}
+
+// holdBack removes a share of messages from the batch until their hold time
+// has passed. When the history is full the oldest held-back messages are
+// returned to be sent early, so that no message is lost.
+func (s *Simulator) holdBack(batch []kafka.Message, now time.Time) (evicted, kept []kafka.Message) {
+	kept = make([]kafka.Message, 0, len(batch))
+	for _, msg := range batch {
+		if rand.Float64() >= s.reorder.Rate {
+			kept = append(kept, msg)
+			continue
+		}
+		s.held = append(s.held, pending{msg: msg, due: now.Add(randomDelay(s.reorder.Delay, s.reorder.MaxDelay))})
+		if over := len(s.held) - s.reorder.History; over > 0 {
+			for _, p := range s.held[:over] {
+				evicted = append(evicted, p.msg)
+			}
+			s.held = append(s.held[:0], s.held[over:]...)
+			s.stats.Evicted += uint64(over)
+		}
+	}
+	return evicted, kept
+}
+
+// shuffle permutes a random share of positions within the batch
+func (s *Simulator) shuffle(batch []kafka.Message) []kafka.Message {
+	var positions []int
+	for i := range batch {
+		if rand.Float64() < s.reorder.Rate {
+			positions = append(positions, i)
+		}
+	}
+	if len(positions) < 2 {
+		return batch
+	}
+
+	out := append([]kafka.Message(nil), batch...)
+	order := rand.Perm(len(positions))
+	for i, pos := range positions {
+		src := positions[order[i]]
+		out[pos] = batch[src]
+		if src != pos {
+			s.stats.Reordered++
+		}
+	}
+	return out
+}
+
+// takeDue splits pending messages into those due at now and the rest
+func takeDue(list []pending, now time.Time) ([]kafka.Message, []pending) {
+	var due []kafka.Message
+	rest := list[:0]
+	for _, p := range list {
+		if !p.due.After(now) {
+			due = append(due, p.msg)
+			continue
+		}
+		rest = append(rest, p)
+	}
+	return due, rest
+}
+
+// randomDelay returns delay, or a random duration in [delay, maxDelay] when
+// maxDelay is set
+func randomDelay(delay, maxDelay time.Duration) time.Duration {
+	if maxDelay > delay {
+		return delay + rand.N(maxDelay-delay+1)
+	}
+	return delay
+}
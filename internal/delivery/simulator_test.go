@@ -0,0 +1,169 @@
+package delivery
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+)
+
+// fakeClock is a manually advanced time source
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func batch(prefix string, n int) []kafka.Message {
+	messages := make([]kafka.Message, n)
+	for i := range messages {
+		messages[i] = kafka.Message{
+			Key:   []byte(fmt.Sprintf("%s-%d", prefix, i)),
+			Value: []byte(fmt.Sprintf(`{"n":%d}`, i)),
+		}
+	}
+	return messages
+}
+
+func newSimulator(t *testing.T, cfg *config.DeliveryConfig) (*Simulator, *fakeClock) {
+	t.Helper()
+	sim, err := NewSimulator(cfg)
+	if err != nil {
+		t.Fatalf("NewSimulator() error = %v", err)
+	}
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	sim.now = clock.Now
+	return sim, clock
+}
+
+func TestDuplicatesAfterDelay(t *testing.T) {
+	sim, clock := newSimulator(t, &config.DeliveryConfig{
+		Duplicates: &config.DuplicatesConfig{Rate: 1, Delay: time.Minute, History: 100},
+	})
+
+	first := sim.Process(batch("a", 3))
+	if len(first) != 3 {
+		t.Fatalf("Expected the batch unchanged, got %d messages", len(first))
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+	if out := sim.Process(nil); len(out) != 0 {
+		t.Fatalf("Expected no duplicates before the delay, got %d", len(out))
+	}
+
+	clock.now = clock.now.Add(31 * time.Second)
+	out := sim.Process(nil)
+	if len(out) != 3 {
+		t.Fatalf("Expected 3 duplicates, got %d", len(out))
+	}
+	for i := range out {
+		if string(out[i].Key) != string(first[i].Key) || string(out[i].Value) != string(first[i].Value) {
+			t.Errorf("Duplicate %d differs from original", i)
+		}
+	}
+
+	if stats := sim.Stats(); stats.Duplicates != 3 || stats.Pending != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestDuplicatesHistoryBound(t *testing.T) {
+	sim, _ := newSimulator(t, &config.DeliveryConfig{
+		Duplicates: &config.DuplicatesConfig{Rate: 1, Delay: time.Hour, History: 5},
+	})
+
+	sim.Process(batch("a", 8))
+	stats := sim.Stats()
+	if stats.Pending != 5 || stats.Evicted != 3 {
+		t.Errorf("Expected 5 pending and 3 evicted, got %+v", stats)
+	}
+
+	drained := sim.Drain()
+	if len(drained) != 5 || string(drained[0].Key) != "a-3" {
+		t.Errorf("Expected the 5 newest messages, got %d starting with %s", len(drained), drained[0].Key)
+	}
+}
+
+func TestReorderShuffle(t *testing.T) {
+	sim, _ := newSimulator(t, &config.DeliveryConfig{
+		Reorder: &config.ReorderConfig{Rate: 1, Mode: "shuffle"},
+	})
+
+	in := batch("a", 50)
+	out := sim.Process(in)
+	if len(out) != len(in) {
+		t.Fatalf("Expected %d messages, got %d", len(in), len(out))
+	}
+
+	seen := make(map[string]bool)
+	moved := 0
+	for i, msg := range out {
+		seen[string(msg.Key)] = true
+		if string(msg.Key) != string(in[i].Key) {
+			moved++
+		}
+	}
+	if len(seen) != len(in) {
+		t.Error("Expected every message exactly once")
+	}
+	if moved == 0 || sim.Stats().Reordered != uint64(moved) {
+		t.Errorf("Expected reordered count %d, got %d", moved, sim.Stats().Reordered)
+	}
+	if string(in[0].Key) != "a-0" {
+		t.Error("Process must not modify the input batch")
+	}
+}
+
+func TestReorderDelay(t *testing.T) {
+	sim, clock := newSimulator(t, &config.DeliveryConfig{
+		Reorder: &config.ReorderConfig{Rate: 1, Mode: "delay", Delay: time.Second, History: 100},
+	})
+
+	if out := sim.Process(batch("a", 2)); len(out) != 0 {
+		t.Fatalf("Expected all messages held back, got %d", len(out))
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	out := sim.Process(batch("b", 1))
+	// The held messages come first, the new one is held in turn
+	if len(out) != 2 || string(out[0].Key) != "a-0" || string(out[1].Key) != "a-1" {
+		t.Fatalf("Expected held messages released, got %d", len(out))
+	}
+
+	if stats := sim.Stats(); stats.Delayed != 2 || stats.Pending != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestReorderDelayHistoryBound(t *testing.T) {
+	sim, _ := newSimulator(t, &config.DeliveryConfig{
+		Reorder: &config.ReorderConfig{Rate: 1, Mode: "delay", Delay: time.Hour, History: 3},
+	})
+
+	// The oldest held-back messages are sent early instead of being lost
+	out := sim.Process(batch("a", 5))
+	if len(out) != 2 || string(out[0].Key) != "a-0" || string(out[1].Key) != "a-1" {
+		t.Fatalf("Expected a-0 and a-1 sent early, got %d messages", len(out))
+	}
+	if stats := sim.Stats(); stats.Pending != 3 || stats.Evicted != 2 {
+		t.Errorf("Expected 3 pending and 2 evicted, got %+v", stats)
+	}
+
+	drained := sim.Drain()
+	if len(drained) != 3 || string(drained[0].Key) != "a-2" {
+		t.Errorf("Expected a-2 to a-4 drained, got %d messages", len(drained))
+	}
+}
+
+func TestNilSimulator(t *testing.T) {
+	var sim *Simulator
+	in := batch("a", 2)
+	if out := sim.Process(in); len(out) != 2 {
+		t.Error("Expected nil simulator to pass batches through")
+	}
+	if sim.Drain() != nil {
+		t.Error("Expected nothing to drain")
+	}
+}
@@ -0,0 +1,256 @@
+package faults
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Fault types
+const (
+	MissingField = "missing_field"
+	WrongType    = "wrong_type"
+	Truncate     = "truncate"
+	Oversize     = "oversize"
+	InvalidUTF8  = "invalid_utf8"
+	Duplicate    = "duplicate"
+	NullKey      = "null_key"
+)
+
+// structural faults need a JSON object value with at least one field
+var structural = map[string]bool{MissingField: true, WrongType: true}
+
+// Injector corrupts a configurable fraction of messages. Every corrupted
+// message carries a header naming the fault.
+type Injector struct {
+	rate     float64
+	header   string
+	oversize int
+	types    []string
+	weights  []float64
+	counts   map[string]*atomic.Uint64
+	total    atomic.Uint64
+}
+
+// NewInjector creates a fault injector
+func NewInjector(cfg *config.FaultsConfig) (*Injector, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("faults config is required")
+	}
+	if cfg.Rate <= 0 || cfg.Rate > 1 {
+		return nil, fmt.Errorf("fault rate must be in (0, 1]")
+	}
+
+	inj := &Injector{
+		rate:     cfg.Rate,
+		header:   cfg.Header,
+		oversize: cfg.OversizeBytes,
+		counts:   make(map[string]*atomic.Uint64),
+	}
+	if inj.header == "" {
+		inj.header = "x-fault"
+	}
+
+	// Sorted for a stable weighted pick
+	names := make([]string, 0, len(cfg.Types))
+	for name := range cfg.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cfg.Types[name] <= 0 {
+			continue
+		}
+		inj.types = append(inj.types, name)
+		inj.weights = append(inj.weights, cfg.Types[name])
+		inj.counts[name] = &atomic.Uint64{}
+	}
+	if len(inj.types) == 0 {
+		return nil, fmt.Errorf("at least one fault type is required")
+	}
+
+	return inj, nil
+}
+
+// Apply corrupts the message with the configured probability and returns
+// the messages to send: the original, a corrupted copy, or two copies for
+// duplicates. A nil injector returns the message unchanged.
+// This method is thread-safe
+func (i *Injector) Apply(msg kafka.Message) []kafka.Message {
+	if i == nil || rand.Float64() >= i.rate {
+		return []kafka.Message{msg}
+	}
+
+	var obj map[string]interface{}
+	if isJSONObject(msg.Value) {
+		obj = decodeObject(msg.Value)
+	}
+	fault := i.pick(len(obj) > 0)
+	if fault == "" {
+		return []kafka.Message{msg}
+	}
+
+	out := msg
+	out.Headers = append(append([]kafkago.Header(nil), msg.Headers...), kafkago.Header{Key: i.header, Value: []byte(fault)})
+
+	switch fault {
+	case MissingField:
+		delete(obj, randomKey(obj))
+		out.Value = encodeObject(obj)
+	case WrongType:
+		key := randomKey(obj)
+		obj[key] = wrongType(obj[key])
+		out.Value = encodeObject(obj)
+	case Truncate:
+		out.Value = truncate(msg.Value)
+	case Oversize:
+		out.Value = pad(msg.Value, obj, i.oversize)
+	case InvalidUTF8:
+		out.Value = insertInvalidUTF8(msg.Value)
+	case NullKey:
+		out.Key = nil
+	}
+
+	i.counts[fault].Add(1)
+	i.total.Add(1)
+
+	if fault == Duplicate {
+		return []kafka.Message{out, out}
+	}
+	return []kafka.Message{out}
+}
+
+// Stats returns the number of injected faults by type
+func (i *Injector) Stats() map[string]uint64 {
+	stats := make(map[string]uint64, len(i.counts))
+	for name, count := range i.counts {
+		stats[name] = count.Load()
+	}
+	return stats
+}
+
+// Total returns the number of corrupted messages
+func (i *Injector) Total() uint64 {
+	return i.total.Load()
+}
+
+// pick chooses a fault type by weight among those applicable to the message
+func (i *Injector) pick(hasFields bool) string {
+	var total float64
+	for n, name := range i.types {
+		if structural[name] && !hasFields {
+			continue
+		}
+		total += i.weights[n]
+	}
+	if total == 0 {
+		return ""
+	}
+
+	r := rand.Float64() * total
+	for n, name := range i.types {
+		if structural[name] && !hasFields {
+			continue
+		}
+		r -= i.weights[n]
+		if r < 0 {
+			return name
+		}
+	}
+	return i.types[len(i.types)-1]
+}
+
+// isJSONObject cheaply checks whether a value looks like a JSON object
+func isJSONObject(value []byte) bool {
+	trimmed := bytes.TrimSpace(value)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// decodeObject parses a JSON object keeping numbers exact, returning nil
+// for anything else
+func decodeObject(value []byte) map[string]interface{} {
+	dec := json.NewDecoder(bytes.NewReader(value))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil
+	}
+	return obj
+}
+
+// encodeObject marshals a decoded object; it cannot fail for decoded JSON
+func encodeObject(obj map[string]interface{}) []byte {
+	data, _ := json.Marshal(obj)
+	return data
+}
+
+// randomKey picks a random field name
+func randomKey(obj map[string]interface{}) string {
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys[rand.IntN(len(keys))]
+}
+
+// wrongType returns a value of a different JSON type
+func wrongType(v interface{}) interface{} {
+	switch v.(type) {
+	case string:
+		return 12345
+	case json.Number:
+		return "not-a-number"
+	case bool:
+		return "maybe"
+	case map[string]interface{}:
+		return []interface{}{"unexpected"}
+	case []interface{}:
+		return map[string]interface{}{"unexpected": true}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// truncate cuts the value at a random position
+func truncate(value []byte) []byte {
+	if len(value) < 2 {
+		return []byte{}
+	}
+	return append([]byte(nil), value[:1+rand.IntN(len(value)-1)]...)
+}
+
+// pad grows the value by size bytes, as an extra field for JSON objects
+func pad(value []byte, obj map[string]interface{}, size int) []byte {
+	if obj != nil {
+		obj["_padding"] = strings.Repeat("x", size)
+		return encodeObject(obj)
+	}
+	out := make([]byte, len(value), len(value)+size)
+	copy(out, value)
+	return append(out, bytes.Repeat([]byte{'x'}, size)...)
+}
+
+// insertInvalidUTF8 inserts bytes that are never valid in UTF-8, inside a
+// string literal when the value is JSON
+func insertInvalidUTF8(value []byte) []byte {
+	pos := len(value)
+	if quote := bytes.IndexByte(value, '"'); quote >= 0 {
+		pos = quote + 1
+	} else if len(value) > 0 {
+		pos = rand.IntN(len(value) + 1)
+	}
+
+	out := make([]byte, 0, len(value)+2)
+	out = append(out, value[:pos]...)
+	out = append(out, 0xff, 0xfe)
+	return append(out, value[pos:]...)
+}
@@ -0,0 +1,130 @@
+package faults
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+)
+
+var sample = kafka.Message{
+	Key:   []byte("k-1"),
+	Value: []byte(`{"id":"o-1","amount":42,"paid":true,"tags":["a"]}`),
+}
+
+func newInjector(t *testing.T, fault string) *Injector {
+	t.Helper()
+	inj, err := NewInjector(&config.FaultsConfig{Rate: 1, Types: map[string]float64{fault: 1}, OversizeBytes: 64})
+	if err != nil {
+		t.Fatalf("NewInjector() error = %v", err)
+	}
+	return inj
+}
+
+func faultHeader(t *testing.T, msg kafka.Message) string {
+	t.Helper()
+	for _, h := range msg.Headers {
+		if h.Key == "x-fault" {
+			return string(h.Value)
+		}
+	}
+	t.Fatal("Expected x-fault header")
+	return ""
+}
+
+func TestApplyFaults(t *testing.T) {
+	tests := []struct {
+		fault string
+		check func(t *testing.T, out []kafka.Message)
+	}{
+		{MissingField, func(t *testing.T, out []kafka.Message) {
+			var obj map[string]interface{}
+			if err := json.Unmarshal(out[0].Value, &obj); err != nil || len(obj) != 3 {
+				t.Errorf("Expected one field removed, got %s", out[0].Value)
+			}
+		}},
+		{WrongType, func(t *testing.T, out []kafka.Message) {
+			if bytes.Equal(out[0].Value, sample.Value) || !json.Valid(out[0].Value) {
+				t.Errorf("Expected a changed JSON document, got %s", out[0].Value)
+			}
+		}},
+		{Truncate, func(t *testing.T, out []kafka.Message) {
+			if len(out[0].Value) >= len(sample.Value) || json.Valid(out[0].Value) {
+				t.Errorf("Expected truncated JSON, got %s", out[0].Value)
+			}
+		}},
+		{Oversize, func(t *testing.T, out []kafka.Message) {
+			if len(out[0].Value) < len(sample.Value)+64 || !json.Valid(out[0].Value) {
+				t.Errorf("Expected padded JSON, got %d bytes", len(out[0].Value))
+			}
+		}},
+		{InvalidUTF8, func(t *testing.T, out []kafka.Message) {
+			if utf8.Valid(out[0].Value) {
+				t.Errorf("Expected invalid UTF-8, got %q", out[0].Value)
+			}
+		}},
+		{Duplicate, func(t *testing.T, out []kafka.Message) {
+			if len(out) != 2 || !bytes.Equal(out[0].Value, out[1].Value) {
+				t.Errorf("Expected two identical messages, got %d", len(out))
+			}
+		}},
+		{NullKey, func(t *testing.T, out []kafka.Message) {
+			if out[0].Key != nil || !bytes.Equal(out[0].Value, sample.Value) {
+				t.Errorf("Expected nil key and unchanged value")
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fault, func(t *testing.T) {
+			inj := newInjector(t, tt.fault)
+			out := inj.Apply(sample)
+			if len(out) == 0 {
+				t.Fatal("Expected messages")
+			}
+			if got := faultHeader(t, out[0]); got != tt.fault {
+				t.Errorf("Expected fault header %s, got %s", tt.fault, got)
+			}
+			tt.check(t, out)
+			if inj.Stats()[tt.fault] != 1 || inj.Total() != 1 {
+				t.Errorf("Expected fault to be counted, got %v", inj.Stats())
+			}
+		})
+	}
+
+	if len(sample.Headers) != 0 || string(sample.Key) != "k-1" {
+		t.Error("Apply must not modify the original message")
+	}
+}
+
+func TestApplyStructuralFaultsNeedJSON(t *testing.T) {
+	inj := newInjector(t, MissingField)
+	out := inj.Apply(kafka.Message{Value: []byte{0x00, 0x01, 0x02}})
+	if len(out) != 1 || len(out[0].Headers) != 0 {
+		t.Error("Expected binary message to pass through unchanged")
+	}
+	if inj.Total() != 0 {
+		t.Errorf("Expected no faults, got %d", inj.Total())
+	}
+}
+
+func TestApplyRate(t *testing.T) {
+	inj, err := NewInjector(&config.FaultsConfig{Rate: 0.2, Types: map[string]float64{NullKey: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5000; i++ {
+		inj.Apply(sample)
+	}
+	if got := inj.Total(); got < 800 || got > 1200 {
+		t.Errorf("Expected about 1000 faults, got %d", got)
+	}
+
+	var nilInjector *Injector
+	if out := nilInjector.Apply(sample); len(out) != 1 || len(out[0].Headers) != 0 {
+		t.Error("Expected nil injector to pass messages through")
+	}
+}
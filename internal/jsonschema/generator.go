@@ -0,0 +1,554 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/google/uuid"
+)
+
+const (
+	// softDepth is the nesting level beyond which optional properties and
+	// array items are no longer generated, so recursive schemas terminate
+	softDepth = 6
+	// maxDepth is the nesting level at which generation fails
+	maxDepth = 32
+)
+
+// Resolver evaluates template directives such as {{@uuid}} or
+// {{@ref|customers.id}} used by overrides
+type Resolver interface {
+	Resolve(values map[string]string) (map[string]interface{}, error)
+}
+
+// Generator produces random documents conforming to a JSON Schema
+type Generator struct {
+	doc       *document
+	optional  float64
+	overrides map[string]string
+	resolver  Resolver
+	patterns  map[string]*patternGenerator
+}
+
+// NewGenerator loads the schema at cfg.Path. The resolver evaluates
+// overrides and may be nil when none are configured.
+func NewGenerator(cfg *config.JSONSchemaConfig, resolver Resolver) (*Generator, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("json schema config is required")
+	}
+	if len(cfg.Overrides) > 0 && resolver == nil {
+		return nil, fmt.Errorf("resolver is required for overrides")
+	}
+
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON schema: %w", err)
+	}
+
+	return newGenerator(data, cfg, resolver)
+}
+
+// newGenerator creates a generator from schema bytes
+func newGenerator(data []byte, cfg *config.JSONSchemaConfig, resolver Resolver) (*Generator, error) {
+	doc, err := parseDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Generator{
+		doc:       doc,
+		optional:  cfg.OptionalProbability,
+		overrides: cfg.Overrides,
+		resolver:  resolver,
+		patterns:  make(map[string]*patternGenerator),
+	}
+	if err := g.compilePatterns(doc.root, make(map[*Schema]bool)); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Generate creates a new document and applies the overrides.
+// This method is thread-safe
+func (g *Generator) Generate() ([]byte, error) {
+	value, err := g.generate(g.doc.root, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(g.overrides) > 0 {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("overrides require an object document")
+		}
+		resolved, err := g.resolver.Resolve(g.overrides)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve overrides: %w", err)
+		}
+		for path, v := range resolved {
+			setPath(obj, strings.Split(path, "."), v)
+		}
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal document: %w", err)
+	}
+	return data, nil
+}
+
+// compilePatterns parses every pattern in the schema once
+func (g *Generator) compilePatterns(s *Schema, seen map[*Schema]bool) error {
+	if s == nil || seen[s] {
+		return nil
+	}
+	seen[s] = true
+
+	if s.Pattern != "" {
+		if _, ok := g.patterns[s.Pattern]; !ok {
+			p, err := newPatternGenerator(s.Pattern)
+			if err != nil {
+				return err
+			}
+			g.patterns[s.Pattern] = p
+		}
+	}
+	if s.Ref != "" {
+		target, err := g.doc.resolve(s.Ref)
+		if err != nil {
+			return err
+		}
+		if err := g.compilePatterns(target, seen); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.Properties {
+		if err := g.compilePatterns(p, seen); err != nil {
+			return err
+		}
+	}
+	if err := g.compilePatterns(s.Items, seen); err != nil {
+		return err
+	}
+	for _, group := range [][]*Schema{s.AllOf, s.AnyOf, s.OneOf} {
+		for _, sub := range group {
+			if err := g.compilePatterns(sub, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// generate produces a value for a schema at the given nesting depth
+func (g *Generator) generate(s *Schema, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("schema nesting exceeds %d levels", maxDepth)
+	}
+
+	s, err := g.flatten(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.Const) > 0 {
+		var v interface{}
+		if err := json.Unmarshal(s.Const, &v); err != nil {
+			return nil, fmt.Errorf("invalid const: %w", err)
+		}
+		return v, nil
+	}
+	if len(s.Enum) > 0 {
+		return s.Enum[rand.IntN(len(s.Enum))], nil
+	}
+
+	switch s.pickType() {
+	case "object":
+		return g.generateObject(s, depth)
+	case "array":
+		return g.generateArray(s, depth)
+	case "integer":
+		return generateInteger(s), nil
+	case "number":
+		return generateNumber(s), nil
+	case "boolean":
+		return rand.IntN(2) == 1, nil
+	case "null":
+		return nil, nil
+	default:
+		return g.generateString(s), nil
+	}
+}
+
+// flatten resolves references, merges allOf and picks one anyOf/oneOf branch
+func (g *Generator) flatten(s *Schema) (*Schema, error) {
+	for i := 0; s.Ref != ""; i++ {
+		if i > maxDepth {
+			return nil, fmt.Errorf("$ref chain exceeds %d levels", maxDepth)
+		}
+		target, err := g.doc.resolve(s.Ref)
+		if err != nil {
+			return nil, err
+		}
+		s = target
+	}
+
+	if len(s.AllOf) == 0 && len(s.AnyOf) == 0 && len(s.OneOf) == 0 {
+		return s, nil
+	}
+
+	merged := *s
+	merged.AllOf, merged.AnyOf, merged.OneOf = nil, nil, nil
+	parts := append([]*Schema(nil), s.AllOf...)
+	if len(s.AnyOf) > 0 {
+		parts = append(parts, s.AnyOf[rand.IntN(len(s.AnyOf))])
+	}
+	if len(s.OneOf) > 0 {
+		parts = append(parts, s.OneOf[rand.IntN(len(s.OneOf))])
+	}
+	for _, part := range parts {
+		flat, err := g.flatten(part)
+		if err != nil {
+			return nil, err
+		}
+		merged.merge(flat)
+	}
+	return &merged, nil
+}
+
+// merge fills unset keywords of s from other and combines object properties
+func (s *Schema) merge(other *Schema) {
+	if len(s.Type) == 0 {
+		s.Type = other.Type
+	}
+	if s.Enum == nil {
+		s.Enum = other.Enum
+	}
+	if s.Const == nil {
+		s.Const = other.Const
+	}
+	if s.Format == "" {
+		s.Format = other.Format
+	}
+	if s.Pattern == "" {
+		s.Pattern = other.Pattern
+	}
+	if s.MinLength == nil {
+		s.MinLength = other.MinLength
+	}
+	if s.MaxLength == nil {
+		s.MaxLength = other.MaxLength
+	}
+	if s.Minimum == nil {
+		s.Minimum = other.Minimum
+	}
+	if s.Maximum == nil {
+		s.Maximum = other.Maximum
+	}
+	if s.ExclusiveMinimum == nil {
+		s.ExclusiveMinimum = other.ExclusiveMinimum
+	}
+	if s.ExclusiveMaximum == nil {
+		s.ExclusiveMaximum = other.ExclusiveMaximum
+	}
+	if s.MultipleOf == nil {
+		s.MultipleOf = other.MultipleOf
+	}
+	if s.Items == nil {
+		s.Items = other.Items
+	}
+	if s.MinItems == nil {
+		s.MinItems = other.MinItems
+	}
+	if s.MaxItems == nil {
+		s.MaxItems = other.MaxItems
+	}
+	s.UniqueItems = s.UniqueItems || other.UniqueItems
+	if len(other.Properties) > 0 {
+		props := make(map[string]*Schema, len(s.Properties)+len(other.Properties))
+		for name, p := range s.Properties {
+			props[name] = p
+		}
+		for name, p := range other.Properties {
+			if _, ok := props[name]; !ok {
+				props[name] = p
+			}
+		}
+		s.Properties = props
+	}
+	s.Required = append(append([]string(nil), s.Required...), other.Required...)
+}
+
+// pickType returns the type to generate, inferring it from other keywords
+// when absent. Nullable types yield null one time in ten.
+func (s *Schema) pickType() string {
+	switch len(s.Type) {
+	case 0:
+		switch {
+		case s.Properties != nil:
+			return "object"
+		case s.Items != nil:
+			return "array"
+		case s.Minimum != nil || s.Maximum != nil || s.MultipleOf != nil:
+			return "number"
+		default:
+			return "string"
+		}
+	case 1:
+		return s.Type[0]
+	}
+
+	var types []string
+	nullable := false
+	for _, t := range s.Type {
+		if t == "null" {
+			nullable = true
+			continue
+		}
+		types = append(types, t)
+	}
+	if len(types) == 0 || (nullable && rand.IntN(10) == 0) {
+		return "null"
+	}
+	return types[rand.IntN(len(types))]
+}
+
+// generateObject produces all required and a random share of optional properties
+func (g *Generator) generateObject(s *Schema, depth int) (interface{}, error) {
+	required := make(map[string]bool, len(s.Required))
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	obj := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		if !required[name] && (depth >= softDepth || rand.Float64() >= g.optional) {
+			continue
+		}
+		v, err := g.generate(s.Properties[name], depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		obj[name] = v
+	}
+	return obj, nil
+}
+
+// generateArray produces between minItems and maxItems items
+func (g *Generator) generateArray(s *Schema, depth int) (interface{}, error) {
+	lo, hi := 1, 3
+	if s.MinItems != nil {
+		lo = *s.MinItems
+		if hi < lo {
+			hi = lo + 2
+		}
+	}
+	if s.MaxItems != nil {
+		hi = *s.MaxItems
+		if lo > hi {
+			lo = hi
+		}
+	}
+	n := lo
+	if depth < softDepth && hi > lo {
+		n += rand.IntN(hi - lo + 1)
+	}
+
+	items := make([]interface{}, 0, n)
+	if s.Items == nil {
+		for i := 0; i < n; i++ {
+			items = append(items, randomWord(5, 10))
+		}
+		return items, nil
+	}
+
+	seen := make(map[string]bool, n)
+	for attempts := 0; len(items) < n && attempts < n*10; attempts++ {
+		v, err := g.generate(s.Items, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		if s.UniqueItems {
+			key, _ := json.Marshal(v)
+			if seen[string(key)] {
+				continue
+			}
+			seen[string(key)] = true
+		}
+		items = append(items, v)
+	}
+	return items, nil
+}
+
+// generateString honours format, pattern and length constraints
+func (g *Generator) generateString(s *Schema) string {
+	if v, ok := generateFormat(s.Format); ok {
+		return v
+	}
+
+	lo, hi := 5, 0
+	if s.MinLength != nil {
+		lo = *s.MinLength
+	}
+	if s.MaxLength != nil {
+		hi = *s.MaxLength
+		if lo > hi {
+			lo = hi
+		}
+	} else {
+		hi = lo + 10
+	}
+
+	if p := g.patterns[s.Pattern]; p != nil {
+		var v string
+		for attempt := 0; attempt < 20; attempt++ {
+			v = p.generate()
+			if n := len([]rune(v)); n >= lo && n <= hi {
+				break
+			}
+		}
+		return v
+	}
+
+	return randomWord(lo, hi)
+}
+
+// generateFormat produces values for the common string formats
+func generateFormat(format string) (string, bool) {
+	switch format {
+	case "date-time":
+		return randomTime().Format(time.RFC3339), true
+	case "date":
+		return randomTime().Format(time.DateOnly), true
+	case "time":
+		return randomTime().Format("15:04:05Z"), true
+	case "email":
+		return randomWord(6, 10) + "@example.com", true
+	case "uuid":
+		return uuid.NewString(), true
+	case "uri", "url", "uri-reference", "iri":
+		return "https://example.com/" + randomWord(4, 10), true
+	case "hostname", "idn-hostname":
+		return randomWord(4, 10) + ".example.com", true
+	case "ipv4":
+		return net.IPv4(byte(rand.IntN(223)+1), byte(rand.IntN(256)), byte(rand.IntN(256)), byte(rand.IntN(254)+1)).String(), true
+	case "ipv6":
+		ip := make(net.IP, net.IPv6len)
+		for i := range ip {
+			ip[i] = byte(rand.IntN(256))
+		}
+		ip[0], ip[1] = 0x20, 0x01
+		return ip.String(), true
+	default:
+		return "", false
+	}
+}
+
+// generateInteger picks an integer within the bounds, honouring multipleOf
+func generateInteger(s *Schema) int64 {
+	lo, hi := numericRange(s, 1)
+	first, last := math.Ceil(lo), math.Floor(hi)
+	if m := s.MultipleOf; m != nil && *m > 0 {
+		kLo, kHi := math.Ceil(first / *m), math.Floor(last / *m)
+		if kHi >= kLo {
+			return int64((kLo + float64(rand.Int64N(int64(kHi-kLo)+1))) * *m)
+		}
+	}
+	if last < first {
+		return int64(first)
+	}
+	return int64(first) + rand.Int64N(int64(last-first)+1)
+}
+
+// generateNumber picks a number within the bounds, rounded to two decimals
+// unless multipleOf applies
+func generateNumber(s *Schema) float64 {
+	lo, hi := numericRange(s, 0.01)
+	if m := s.MultipleOf; m != nil && *m > 0 {
+		kLo, kHi := math.Ceil(lo / *m), math.Floor(hi / *m)
+		if kHi >= kLo {
+			return (kLo + float64(rand.Int64N(int64(kHi-kLo)+1))) * *m
+		}
+	}
+
+	v := math.Round((lo+rand.Float64()*(hi-lo))*100) / 100
+	if v < lo || v > hi {
+		v = lo + (hi-lo)/2
+	}
+	return v
+}
+
+// numericRange returns inclusive bounds for numbers; exclusive bounds are
+// narrowed by step
+func numericRange(s *Schema, step float64) (float64, float64) {
+	lower, lowerExclusive := bound(s.Minimum, s.ExclusiveMinimum)
+	upper, upperExclusive := bound(s.Maximum, s.ExclusiveMaximum)
+
+	var lo, hi float64
+	switch {
+	case lower != nil && upper != nil:
+		lo, hi = *lower, *upper
+	case lower != nil:
+		lo, hi = *lower, *lower+1000
+	case upper != nil:
+		lo, hi = *upper-1000, *upper
+		if *upper > 0 {
+			lo = 0
+		}
+	default:
+		lo, hi = 0, 1000
+	}
+	if lowerExclusive {
+		lo += step
+	}
+	if upperExclusive {
+		hi -= step
+	}
+	return lo, hi
+}
+
+// randomTime returns a time within the last 30 days
+func randomTime() time.Time {
+	return time.Now().UTC().Add(-time.Duration(rand.Int64N(int64(30 * 24 * time.Hour)))).Truncate(time.Second)
+}
+
+// randomWord returns a lowercase alphanumeric string of lo to hi characters
+func randomWord(lo, hi int) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	n := lo
+	if hi > lo {
+		n += rand.IntN(hi - lo + 1)
+	}
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[rand.IntN(len(alphabet))]
+	}
+	return string(b)
+}
+
+// setPath sets a nested field, creating intermediate objects as needed
+func setPath(obj map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[path[len(path)-1]] = value
+}
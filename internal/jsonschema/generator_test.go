@@ -0,0 +1,285 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/google/uuid"
+)
+
+const orderSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["id", "email", "status", "createdAt", "quantity", "price", "sku", "lines", "address"],
+  "properties": {
+    "id": {"type": "string", "format": "uuid"},
+    "email": {"type": "string", "format": "email"},
+    "status": {"enum": ["NEW", "PAID", "SHIPPED"]},
+    "createdAt": {"type": "string", "format": "date-time"},
+    "quantity": {"type": "integer", "minimum": 1, "maximum": 5},
+    "price": {"type": "number", "exclusiveMinimum": 0, "maximum": 100},
+    "even": {"type": "integer", "multipleOf": 2, "minimum": 10, "maximum": 20},
+    "sku": {"type": "string", "pattern": "^[A-Z]{3}-\\d{4}$"},
+    "note": {"type": ["string", "null"], "maxLength": 8},
+    "lines": {
+      "type": "array",
+      "minItems": 2,
+      "maxItems": 4,
+      "items": {"$ref": "#/$defs/line"}
+    },
+    "address": {
+      "allOf": [
+        {"$ref": "#/$defs/address"},
+        {"required": ["zip"], "properties": {"zip": {"type": "string", "pattern": "^\\d{5}$"}}}
+      ]
+    },
+    "payment": {
+      "oneOf": [
+        {"type": "object", "required": ["card"], "properties": {"card": {"const": "visa"}}},
+        {"type": "object", "required": ["iban"], "properties": {"iban": {"type": "string", "minLength": 10, "maxLength": 10}}}
+      ]
+    }
+  },
+  "$defs": {
+    "line": {
+      "type": "object",
+      "required": ["sku", "qty"],
+      "properties": {
+        "sku": {"type": "string", "minLength": 4, "maxLength": 6},
+        "qty": {"type": "integer", "minimum": 1, "maximum": 3}
+      }
+    },
+    "address": {
+      "type": "object",
+      "required": ["city"],
+      "properties": {
+        "city": {"type": "string"},
+        "ip": {"type": "string", "format": "ipv4"}
+      }
+    }
+  }
+}`
+
+type order struct {
+	ID        string  `json:"id"`
+	Email     string  `json:"email"`
+	Status    string  `json:"status"`
+	CreatedAt string  `json:"createdAt"`
+	Quantity  float64 `json:"quantity"`
+	Price     float64 `json:"price"`
+	Even      *int    `json:"even"`
+	SKU       string  `json:"sku"`
+	Note      *string `json:"note"`
+	Lines     []struct {
+		SKU string `json:"sku"`
+		Qty int    `json:"qty"`
+	} `json:"lines"`
+	Address struct {
+		City string  `json:"city"`
+		Zip  string  `json:"zip"`
+		IP   *string `json:"ip"`
+	} `json:"address"`
+	Payment map[string]interface{} `json:"payment"`
+}
+
+func writeSchema(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGenerateConforms(t *testing.T) {
+	gen, err := NewGenerator(&config.JSONSchemaConfig{Path: writeSchema(t, orderSchema), OptionalProbability: 0.5}, nil)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+
+	sku := regexp.MustCompile(`^[A-Z]{3}-\d{4}$`)
+	zip := regexp.MustCompile(`^\d{5}$`)
+	for i := 0; i < 200; i++ {
+		data, err := gen.Generate()
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		var o order
+		if err := json.Unmarshal(data, &o); err != nil {
+			t.Fatalf("invalid document %s: %v", data, err)
+		}
+
+		if _, err := uuid.Parse(o.ID); err != nil {
+			t.Errorf("id %q is not a uuid", o.ID)
+		}
+		if !regexp.MustCompile(`^[a-z0-9]+@example\.com$`).MatchString(o.Email) {
+			t.Errorf("email %q is invalid", o.Email)
+		}
+		if o.Status != "NEW" && o.Status != "PAID" && o.Status != "SHIPPED" {
+			t.Errorf("status %q is not in enum", o.Status)
+		}
+		if _, err := time.Parse(time.RFC3339, o.CreatedAt); err != nil {
+			t.Errorf("createdAt %q is not a date-time", o.CreatedAt)
+		}
+		if o.Quantity < 1 || o.Quantity > 5 || o.Quantity != float64(int(o.Quantity)) {
+			t.Errorf("quantity %v out of range", o.Quantity)
+		}
+		if o.Price <= 0 || o.Price > 100 {
+			t.Errorf("price %v out of range", o.Price)
+		}
+		if o.Even != nil && (*o.Even%2 != 0 || *o.Even < 10 || *o.Even > 20) {
+			t.Errorf("even %d violates multipleOf or bounds", *o.Even)
+		}
+		if !sku.MatchString(o.SKU) {
+			t.Errorf("sku %q does not match pattern", o.SKU)
+		}
+		if o.Note != nil && len(*o.Note) > 8 {
+			t.Errorf("note %q exceeds maxLength", *o.Note)
+		}
+		if len(o.Lines) < 2 || len(o.Lines) > 4 {
+			t.Errorf("expected 2-4 lines, got %d", len(o.Lines))
+		}
+		for _, line := range o.Lines {
+			if len(line.SKU) < 4 || len(line.SKU) > 6 || line.Qty < 1 || line.Qty > 3 {
+				t.Errorf("line %+v violates constraints", line)
+			}
+		}
+		if o.Address.City == "" || !zip.MatchString(o.Address.Zip) {
+			t.Errorf("address %+v violates allOf", o.Address)
+		}
+		if o.Address.IP != nil && net.ParseIP(*o.Address.IP).To4() == nil {
+			t.Errorf("ip %q is not ipv4", *o.Address.IP)
+		}
+		if o.Payment != nil {
+			card, hasCard := o.Payment["card"]
+			iban, hasIBAN := o.Payment["iban"].(string)
+			if !(hasCard && card == "visa") && !(hasIBAN && len(iban) == 10) {
+				t.Errorf("payment %v matches no oneOf branch", o.Payment)
+			}
+		}
+	}
+}
+
+func TestGenerateOptionalProbability(t *testing.T) {
+	path := writeSchema(t, `{"type":"object","required":["a"],"properties":{"a":{"type":"boolean"},"b":{"type":"boolean"}}}`)
+	gen, err := NewGenerator(&config.JSONSchemaConfig{Path: path, OptionalProbability: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		data, err := gen.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			t.Fatal(err)
+		}
+		if len(v) != 2 {
+			t.Fatalf("Expected both properties with probability 1, got %s", data)
+		}
+	}
+}
+
+func TestGenerateRecursiveSchema(t *testing.T) {
+	path := writeSchema(t, `{
+  "$ref": "#/definitions/node",
+  "definitions": {
+    "node": {
+      "type": "object",
+      "required": ["name"],
+      "properties": {
+        "name": {"type": "string"},
+        "children": {"type": "array", "items": {"$ref": "#/definitions/node"}}
+      }
+    }
+  }
+}`)
+	gen, err := NewGenerator(&config.JSONSchemaConfig{Path: path, OptionalProbability: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gen.Generate(); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+}
+
+type staticResolver map[string]interface{}
+
+func (r staticResolver) Resolve(values map[string]string) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if v, ok := r[value]; ok {
+			out[key] = v
+		} else {
+			out[key] = value
+		}
+	}
+	return out, nil
+}
+
+func TestGenerateOverrides(t *testing.T) {
+	path := writeSchema(t, `{"type":"object","required":["id","customer"],"properties":{"id":{"type":"integer"},"customer":{"type":"object","required":["id"],"properties":{"id":{"type":"string"}}}}}`)
+	gen, err := NewGenerator(&config.JSONSchemaConfig{
+		Path: path,
+		Overrides: map[string]string{
+			"customer.id": "{{@ref|customers.id}}",
+			"source":      "load-test",
+		},
+	}, staticResolver{"{{@ref|customers.id}}": "c-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := gen.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Customer struct {
+			ID string `json:"id"`
+		} `json:"customer"`
+		Source string `json:"source"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Customer.ID != "c-1" || v.Source != "load-test" {
+		t.Errorf("Overrides not applied: %s", data)
+	}
+}
+
+func TestNewGeneratorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"invalid json", `{"type":`},
+		{"missing ref", `{"$ref":"#/$defs/missing"}`},
+		{"remote ref", `{"$ref":"https://example.com/schema.json"}`},
+		{"invalid pattern", `{"type":"string","pattern":"[a-"}`},
+		{"invalid type", `{"type": 1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGenerator(&config.JSONSchemaConfig{Path: writeSchema(t, tt.schema)}, nil); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+
+	if _, err := NewGenerator(&config.JSONSchemaConfig{Path: "missing.json"}, nil); err == nil {
+		t.Error("Expected error for missing file")
+	}
+	if _, err := NewGenerator(&config.JSONSchemaConfig{Path: "x", Overrides: map[string]string{"a": "b"}}, nil); err == nil {
+		t.Error("Expected error for overrides without resolver")
+	}
+}
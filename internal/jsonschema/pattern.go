@@ -0,0 +1,114 @@
+package jsonschema
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp/syntax"
+	"strings"
+)
+
+// maxRepeat bounds unbounded quantifiers such as * and + when generating
+// strings from a pattern
+const maxRepeat = 8
+
+// printable is the preferred alphabet for character classes and wildcards
+var printable = []rune(" !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZ[\\]^_`abcdefghijklmnopqrstuvwxyz{|}~")
+
+// patternGenerator produces strings matching a regular expression
+type patternGenerator struct {
+	re *syntax.Regexp
+}
+
+// newPatternGenerator parses a JSON Schema pattern
+func newPatternGenerator(pattern string) (*patternGenerator, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return &patternGenerator{re: re.Simplify()}, nil
+}
+
+// generate returns a random string matching the pattern
+func (p *patternGenerator) generate() string {
+	var sb strings.Builder
+	writeRegexp(&sb, p.re)
+	return sb.String()
+}
+
+// writeRegexp appends a random match of a regexp node
+func writeRegexp(sb *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			sb.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		sb.WriteRune(pickFromClass(re.Rune))
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		sb.WriteRune(printable[rand.IntN(len(printable))])
+	case syntax.OpCapture:
+		writeRegexp(sb, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writeRegexp(sb, sub)
+		}
+	case syntax.OpAlternate:
+		writeRegexp(sb, re.Sub[rand.IntN(len(re.Sub))])
+	case syntax.OpStar:
+		repeat(sb, re.Sub[0], 0, maxRepeat)
+	case syntax.OpPlus:
+		repeat(sb, re.Sub[0], 1, maxRepeat)
+	case syntax.OpQuest:
+		repeat(sb, re.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		hi := re.Max
+		if hi < 0 {
+			hi = re.Min + maxRepeat
+		}
+		repeat(sb, re.Sub[0], re.Min, hi)
+	default:
+		// Anchors, word boundaries and empty matches produce no output
+	}
+}
+
+// repeat appends between lo and hi matches of a node
+func repeat(sb *strings.Builder, re *syntax.Regexp, lo, hi int) {
+	n := lo
+	if hi > lo {
+		n += rand.IntN(hi - lo + 1)
+	}
+	for i := 0; i < n; i++ {
+		writeRegexp(sb, re)
+	}
+}
+
+// pickFromClass picks a rune from a character class given as range pairs,
+// preferring printable ASCII when the class contains any
+func pickFromClass(ranges []rune) rune {
+	var candidates []rune
+	for _, r := range printable {
+		if inClass(ranges, r) {
+			candidates = append(candidates, r)
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[rand.IntN(len(candidates))]
+	}
+	if len(ranges) < 2 {
+		return 'x'
+	}
+
+	i := rand.IntN(len(ranges)/2) * 2
+	lo, hi := ranges[i], ranges[i+1]
+	return lo + rand.Int32N(hi-lo+1)
+}
+
+// inClass reports whether r falls within one of the range pairs
+func inClass(ranges []rune, r rune) bool {
+	for i := 0; i+1 < len(ranges); i += 2 {
+		if r >= ranges[i] && r <= ranges[i+1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema keywords used for generation
+type Schema struct {
+	Ref   string          `json:"$ref"`
+	Type  typeList        `json:"type"`
+	Enum  []interface{}   `json:"enum"`
+	Const json.RawMessage `json:"const"`
+
+	// Strings
+	Format    string `json:"format"`
+	Pattern   string `json:"pattern"`
+	MinLength *int   `json:"minLength"`
+	MaxLength *int   `json:"maxLength"`
+
+	// Numbers. exclusiveMinimum/exclusiveMaximum are numbers since draft 6
+	// and booleans modifying minimum/maximum in draft 4.
+	Minimum          *float64        `json:"minimum"`
+	Maximum          *float64        `json:"maximum"`
+	ExclusiveMinimum json.RawMessage `json:"exclusiveMinimum"`
+	ExclusiveMaximum json.RawMessage `json:"exclusiveMaximum"`
+	MultipleOf       *float64        `json:"multipleOf"`
+
+	// Objects
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+
+	// Arrays
+	Items       *Schema `json:"items"`
+	MinItems    *int    `json:"minItems"`
+	MaxItems    *int    `json:"maxItems"`
+	UniqueItems bool    `json:"uniqueItems"`
+
+	// Composition
+	AllOf []*Schema `json:"allOf"`
+	AnyOf []*Schema `json:"anyOf"`
+	OneOf []*Schema `json:"oneOf"`
+}
+
+// typeList accepts both "type": "string" and "type": ["string", "null"]
+type typeList []string
+
+// UnmarshalJSON decodes a single type name or a list of names
+func (t *typeList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = typeList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = list
+	return nil
+}
+
+// document holds a parsed schema file and resolves local references
+type document struct {
+	raw  interface{}
+	root *Schema
+	refs map[string]*Schema
+}
+
+// parseDocument parses a schema file
+func parseDocument(data []byte) (*document, error) {
+	var raw interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	var root Schema
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	doc := &document{raw: raw, root: &root, refs: make(map[string]*Schema)}
+	if err := doc.checkRefs(&root, make(map[*Schema]bool)); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// checkRefs resolves every reference up front so generation cannot fail on
+// a broken pointer
+func (d *document) checkRefs(s *Schema, seen map[*Schema]bool) error {
+	if s == nil || seen[s] {
+		return nil
+	}
+	seen[s] = true
+
+	if s.Ref != "" {
+		target, err := d.resolve(s.Ref)
+		if err != nil {
+			return err
+		}
+		if err := d.checkRefs(target, seen); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.Properties {
+		if err := d.checkRefs(p, seen); err != nil {
+			return err
+		}
+	}
+	if err := d.checkRefs(s.Items, seen); err != nil {
+		return err
+	}
+	for _, group := range [][]*Schema{s.AllOf, s.AnyOf, s.OneOf} {
+		for _, sub := range group {
+			if err := d.checkRefs(sub, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the schema a local reference such as "#/$defs/address"
+// points to
+func (d *document) resolve(ref string) (*Schema, error) {
+	if s, ok := d.refs[ref]; ok {
+		return s, nil
+	}
+
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil, fmt.Errorf("unsupported $ref %q: only local references are supported", ref)
+	}
+	pointer, err := url.PathUnescape(pointer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+
+	node := d.raw
+	if pointer != "" {
+		for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch n := node.(type) {
+			case map[string]interface{}:
+				next, ok := n[token]
+				if !ok {
+					return nil, fmt.Errorf("$ref %q not found", ref)
+				}
+				node = next
+			case []interface{}:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(n) {
+					return nil, fmt.Errorf("$ref %q not found", ref)
+				}
+				node = n[i]
+			default:
+				return nil, fmt.Errorf("$ref %q not found", ref)
+			}
+		}
+	}
+
+	data, err := json.Marshal(node)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema at $ref %q: %w", ref, err)
+	}
+	d.refs[ref] = &s
+	return &s, nil
+}
+
+// bound returns the lower or upper numeric bound and whether it is exclusive
+func bound(inclusive *float64, exclusive json.RawMessage) (*float64, bool) {
+	if len(exclusive) == 0 {
+		return inclusive, false
+	}
+	var flag bool
+	if err := json.Unmarshal(exclusive, &flag); err == nil {
+		return inclusive, flag && inclusive != nil
+	}
+	var value float64
+	if err := json.Unmarshal(exclusive, &value); err == nil {
+		if inclusive == nil || value >= *inclusive {
+			return &value, true
+		}
+	}
+	return inclusive, false
+}
@@ -0,0 +1,70 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	schemav "github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// maxReportedErrors limits how many violations are listed per message
+const maxReportedErrors = 3
+
+// Validator checks messages against a JSON Schema
+type Validator struct {
+	schema *schemav.Schema
+}
+
+// NewValidator compiles the schema at path. Formats such as date-time or
+// email are asserted regardless of the schema draft.
+func NewValidator(path string) (*Validator, error) {
+	compiler := schemav.NewCompiler()
+	compiler.AssertFormat = true
+
+	schema, err := compiler.Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile JSON schema: %w", err)
+	}
+	return &Validator{schema: schema}, nil
+}
+
+// Validate returns an error describing why the message does not conform to
+// the schema, or nil when it does.
+// This method is thread-safe
+func (v *Validator) Validate(message []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(message))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("message is not valid JSON: %w", err)
+	}
+
+	err := v.schema.Validate(doc)
+	var ve *schemav.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+
+	// Report the innermost violations with their location in the message
+	var details []string
+	for _, e := range ve.BasicOutput().Errors {
+		if e.Error == "" || strings.HasPrefix(e.Error, "doesn't validate with") {
+			continue
+		}
+		location := e.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		details = append(details, location+": "+e.Error)
+	}
+	if len(details) == 0 {
+		return fmt.Errorf("schema violation: %s", ve.Message)
+	}
+	if len(details) > maxReportedErrors {
+		details = append(details[:maxReportedErrors], fmt.Sprintf("and %d more", len(details)-maxReportedErrors))
+	}
+	return fmt.Errorf("schema violation: %s", strings.Join(details, "; "))
+}
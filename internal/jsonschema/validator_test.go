@@ -0,0 +1,65 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	path := writeSchema(t, `{
+  "type": "object",
+  "required": ["id", "email"],
+  "properties": {
+    "id": {"type": "integer"},
+    "email": {"type": "string", "format": "email"},
+    "tags": {"type": "array", "items": {"type": "string"}}
+  }
+}`)
+	v, err := NewValidator(path)
+	if err != nil {
+		t.Fatalf("NewValidator() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{name: "valid", message: `{"id": 1, "email": "a@example.com", "tags": ["x"]}`},
+		{name: "large integer", message: `{"id": 12345678901234567890, "email": "a@example.com"}`},
+		{name: "missing property", message: `{"id": 1}`, want: []string{"missing properties: 'email'"}},
+		{name: "wrong type", message: `{"id": "1", "email": "a@example.com"}`, want: []string{"/id: expected integer"}},
+		{name: "invalid format", message: `{"id": 1, "email": "nope"}`, want: []string{"/email: 'nope' is not valid 'email'"}},
+		{name: "nested", message: `{"id": 1, "email": "a@example.com", "tags": [1]}`, want: []string{"/tags/0: expected string"}},
+		{name: "not json", message: `{"id":`, want: []string{"not valid JSON"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Validate([]byte(tt.message))
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected violation")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain %q, got %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewValidatorErrors(t *testing.T) {
+	if _, err := NewValidator(writeSchema(t, `{"type": 5}`)); err == nil {
+		t.Error("Expected error for invalid schema")
+	}
+	if _, err := NewValidator("missing.json"); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -0,0 +1,166 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+// leaderPollInterval is how often the metadata of created topics is
+// refreshed while waiting for their partition leaders
+const leaderPollInterval = 200 * time.Millisecond
+
+// Preflight checks that the brokers are reachable and that every topic
+// exists with a leader for each partition, creating missing topics when
+// cfg.CreateTopics is set. The returned error lists every problem found.
+func Preflight(ctx context.Context, cfg *config.KafkaConfig, topics []string, logger *slog.Logger) error {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create kafka transport: %w", err)
+	}
+	defer transport.CloseIdleConnections()
+	client := &kafka.Client{Addr: kafka.TCP(cfg.Brokers...), Timeout: cfg.Timeout, Transport: transport}
+
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return fmt.Errorf("failed to reach brokers %s: %w", strings.Join(cfg.Brokers, ","), err)
+	}
+	logger.Info("connected to kafka cluster",
+		slog.String("cluster_id", meta.ClusterID),
+		slog.Int("brokers", len(meta.Brokers)),
+	)
+
+	missing, problems := checkTopics(meta.Topics, cfg.Partition)
+	if len(missing) > 0 {
+		if cfg.CreateTopics == nil {
+			for _, topic := range missing {
+				problems = append(problems, fmt.Sprintf("topic %s does not exist", topic))
+			}
+		} else {
+			problems = append(problems, createTopics(ctx, client, cfg, missing, logger)...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("pre-flight checks failed: %s", strings.Join(problems, "; "))
+	}
+	logger.Info("pre-flight checks passed", slog.Int("topics", len(topics)))
+	return nil
+}
+
+// checkTopics returns the topics that do not exist and a description of
+// every other problem in their metadata. partition is the configured fixed
+// partition, or negative for none.
+func checkTopics(topics []kafka.Topic, partition int) (missing, problems []string) {
+	for _, t := range topics {
+		switch {
+		case errors.Is(t.Error, kafka.UnknownTopicOrPartition):
+			missing = append(missing, t.Name)
+			continue
+		case t.Error != nil:
+			problems = append(problems, fmt.Sprintf("topic %s: %v", t.Name, t.Error))
+			continue
+		}
+
+		if partition >= len(t.Partitions) {
+			problems = append(problems, fmt.Sprintf("topic %s has %d partitions, partition %d does not exist", t.Name, len(t.Partitions), partition))
+		}
+		for _, p := range t.Partitions {
+			if p.Error != nil {
+				problems = append(problems, fmt.Sprintf("topic %s partition %d: %v", t.Name, p.ID, p.Error))
+			}
+		}
+	}
+	return missing, problems
+}
+
+// createTopics creates the missing topics, waits for their partition
+// leaders and returns the problems found
+func createTopics(ctx context.Context, client *kafka.Client, cfg *config.KafkaConfig, topics []string, logger *slog.Logger) []string {
+	create := cfg.CreateTopics
+	names := make([]string, 0, len(create.Configs))
+	for name := range create.Configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]kafka.ConfigEntry, len(names))
+	for i, name := range names {
+		entries[i] = kafka.ConfigEntry{ConfigName: name, ConfigValue: create.Configs[name]}
+	}
+
+	// -1 leaves partitions and replication to the broker defaults
+	req := &kafka.CreateTopicsRequest{}
+	for _, topic := range topics {
+		req.Topics = append(req.Topics, kafka.TopicConfig{
+			Topic:             topic,
+			NumPartitions:     orBrokerDefault(create.Partitions),
+			ReplicationFactor: orBrokerDefault(create.ReplicationFactor),
+			ConfigEntries:     entries,
+		})
+	}
+
+	res, err := client.CreateTopics(ctx, req)
+	if err != nil {
+		return []string{fmt.Sprintf("failed to create topics %s: %v", strings.Join(topics, ","), err)}
+	}
+
+	var problems, created []string
+	for _, topic := range topics {
+		// Another producer may have created the topic in the meantime
+		if err := res.Errors[topic]; err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+			problems = append(problems, fmt.Sprintf("failed to create topic %s: %v", topic, err))
+			continue
+		}
+		logger.Info("created topic", slog.String("topic", topic))
+		created = append(created, topic)
+	}
+	if len(created) == 0 {
+		return problems
+	}
+	return append(problems, waitForLeaders(ctx, client, cfg, created)...)
+}
+
+// waitForLeaders polls the metadata of new topics until every partition has
+// a leader or the timeout expires, and returns the remaining problems
+func waitForLeaders(ctx context.Context, client *kafka.Client, cfg *config.KafkaConfig, topics []string) []string {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	for {
+		meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+		var missing, problems []string
+		if err == nil {
+			missing, problems = checkTopics(meta.Topics, cfg.Partition)
+			if len(missing) == 0 && len(problems) == 0 {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return []string{fmt.Sprintf("failed to read metadata of created topics: %v", err)}
+			}
+			for _, topic := range missing {
+				problems = append(problems, fmt.Sprintf("created topic %s is not visible yet", topic))
+			}
+			return problems
+		case <-time.After(leaderPollInterval):
+		}
+	}
+}
+
+// orBrokerDefault maps an unset count to -1, the broker default
+func orBrokerDefault(n int) int {
+	if n == 0 {
+		return -1
+	}
+	return n
+}
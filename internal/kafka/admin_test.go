@@ -0,0 +1,35 @@
+package kafka
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestCheckTopics(t *testing.T) {
+	topics := []kafka.Topic{
+		{Name: "orders", Partitions: []kafka.Partition{{ID: 0}, {ID: 1}}},
+		{Name: "payments", Error: kafka.UnknownTopicOrPartition},
+		{Name: "audit", Error: kafka.TopicAuthorizationFailed},
+		{Name: "events", Partitions: []kafka.Partition{{ID: 0, Error: kafka.LeaderNotAvailable}}},
+	}
+
+	missing, problems := checkTopics(topics, -1)
+	if !reflect.DeepEqual(missing, []string{"payments"}) {
+		t.Errorf("missing = %v, want [payments]", missing)
+	}
+	if len(problems) != 2 {
+		t.Errorf("problems = %q, want the audit and events problems", problems)
+	}
+
+	// A fixed partition must exist in every topic
+	_, problems = checkTopics(topics[:1], 2)
+	if len(problems) != 1 {
+		t.Errorf("problems = %q, want one for partition 2", problems)
+	}
+	_, problems = checkTopics(topics[:1], 1)
+	if len(problems) != 0 {
+		t.Errorf("problems = %q, want none for partition 1", problems)
+	}
+}
@@ -0,0 +1,68 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+)
+
+func TestEncodeRecordBatch(t *testing.T) {
+	ts := time.UnixMilli(1700000000123)
+	messages := []kafka.Message{
+		{Key: []byte("order-1"), Value: []byte(`{"id":1}`), Time: ts, Headers: []kafka.Header{{Key: "x-seq", Value: []byte("1")}}},
+		{Value: []byte(`{"id":2}`), Time: ts.Add(5 * time.Millisecond)},
+	}
+	header := batchHeader{producerID: 4242, producerEpoch: 3, baseSequence: 17, transactional: true}
+
+	var rs protocol.RecordSet
+	if _, err := rs.ReadFrom(bytes.NewReader(encodeRecordBatch(header, messages))); err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	if rs.Version != 2 {
+		t.Errorf("Version = %d, want 2", rs.Version)
+	}
+	if !rs.Attributes.Transactional() {
+		t.Error("batch is not marked transactional")
+	}
+
+	stream, ok := rs.Records.(*protocol.RecordStream)
+	if !ok || len(stream.Records) != 1 {
+		t.Fatalf("Records = %T, want a stream with one batch", rs.Records)
+	}
+	batch, ok := stream.Records[0].(*protocol.RecordBatch)
+	if !ok {
+		t.Fatalf("batch = %T, want *protocol.RecordBatch", stream.Records[0])
+	}
+	if batch.ProducerID != 4242 || batch.ProducerEpoch != 3 || batch.BaseSequence != 17 {
+		t.Errorf("producer = %d/%d/%d, want 4242/3/17", batch.ProducerID, batch.ProducerEpoch, batch.BaseSequence)
+	}
+
+	for i, want := range messages {
+		rec, err := batch.ReadRecord()
+		if err != nil {
+			t.Fatalf("ReadRecord() %d error = %v", i, err)
+		}
+		key, _ := protocol.ReadAll(rec.Key)
+		value, _ := protocol.ReadAll(rec.Value)
+		if !bytes.Equal(key, want.Key) || !bytes.Equal(value, want.Value) {
+			t.Errorf("record %d = %q/%q, want %q/%q", i, key, value, want.Key, want.Value)
+		}
+		if rec.Key == nil && want.Key != nil {
+			t.Errorf("record %d lost its key", i)
+		}
+		if !rec.Time.Equal(want.Time) {
+			t.Errorf("record %d time = %v, want %v", i, rec.Time, want.Time)
+		}
+		if len(rec.Headers) != len(want.Headers) {
+			t.Errorf("record %d has %d headers, want %d", i, len(rec.Headers), len(want.Headers))
+		}
+	}
+	if _, err := batch.ReadRecord(); !errors.Is(err, io.EOF) {
+		t.Errorf("ReadRecord() after last record error = %v, want io.EOF", err)
+	}
+}
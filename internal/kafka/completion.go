@@ -0,0 +1,158 @@
+package kafka
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/measure"
+	"github.com/segmentio/kafka-go"
+)
+
+// DeliveryStats holds the delivery results of one message source
+type DeliveryStats struct {
+	Delivered uint64
+	Failed    uint64
+	Aborted   uint64        // discarded by a deliberately aborted transaction
+	P50       time.Duration // time from WriteMessages to the broker acknowledgement
+	P95       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+// writeTag is carried in kafka.Message.WriterData to attribute completions
+type writeTag struct {
+	source   string
+	enqueued time.Time
+}
+
+// sourceStats accumulates delivery results of one source
+type sourceStats struct {
+	delivered uint64
+	failed    uint64
+	aborted   uint64
+	latency   measure.Histogram
+}
+
+// completions collects delivery results reported by the writer
+type completions struct {
+	async      bool
+	deadLetter *deadLetter
+	logger     *slog.Logger
+
+	mu      sync.Mutex
+	sources map[string]*sourceStats
+}
+
+// newCompletions creates an empty completion collector
+func newCompletions(async bool, deadLetter *deadLetter, logger *slog.Logger) *completions {
+	return &completions{
+		async:      async,
+		deadLetter: deadLetter,
+		logger:     logger,
+		sources:    make(map[string]*sourceStats),
+	}
+}
+
+// complete is the writer's completion callback. It runs on writer
+// goroutines for every partition batch, in sync and async mode. Failures in
+// sync mode may still be retried, so they are reported through fail instead.
+func (c *completions) complete(messages []kafka.Message, err error) {
+	if err != nil && !c.async {
+		return
+	}
+	if err != nil && c.deadLetter != nil {
+		if dlqErr := c.deadLetter.write(messages, repeatError(err, len(messages))); dlqErr != nil {
+			c.logger.Error("failed to write dead-letter file", slog.String("error", dlqErr.Error()))
+		}
+	}
+
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	failed := make(map[string]int)
+	for _, msg := range messages {
+		tag, _ := msg.WriterData.(writeTag)
+		source := sourceOf(msg)
+		stats := c.source(source)
+
+		if err != nil {
+			stats.failed++
+			failed[source]++
+			continue
+		}
+		stats.delivered++
+		if !tag.enqueued.IsZero() {
+			stats.latency.Record(now.Sub(tag.enqueued))
+		}
+	}
+
+	for source, count := range failed {
+		c.logger.Warn("message delivery failed",
+			slog.String("source", source),
+			slog.Int("count", count),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// fail records messages that could not be written in sync mode or
+// were discarded by an aborted transaction
+func (c *completions) fail(messages []kafka.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, msg := range messages {
+		c.source(sourceOf(msg)).failed++
+	}
+}
+
+// abort records messages discarded by a deliberately aborted transaction
+func (c *completions) abort(messages []kafka.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, msg := range messages {
+		c.source(sourceOf(msg)).aborted++
+	}
+}
+
+// source returns the statistics of a source, creating them if needed
+func (c *completions) source(name string) *sourceStats {
+	stats := c.sources[name]
+	if stats == nil {
+		stats = &sourceStats{}
+		c.sources[name] = stats
+	}
+	return stats
+}
+
+// sourceOf returns the source a message is counted under
+func sourceOf(msg kafka.Message) string {
+	if tag, ok := msg.WriterData.(writeTag); ok && tag.source != "" {
+		return tag.source
+	}
+	return msg.Topic
+}
+
+// stats returns the delivery results by source
+func (c *completions) stats() map[string]DeliveryStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[string]DeliveryStats, len(c.sources))
+	for source, s := range c.sources {
+		out[source] = DeliveryStats{
+			Delivered: s.delivered,
+			Failed:    s.failed,
+			Aborted:   s.aborted,
+			P50:       s.latency.Percentile(0.50),
+			P95:       s.latency.Percentile(0.95),
+			P99:       s.latency.Percentile(0.99),
+			Max:       s.latency.Max(),
+		}
+	}
+	return out
+}
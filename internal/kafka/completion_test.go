@@ -0,0 +1,87 @@
+package kafka
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// taggedMessages builds messages enqueued for a source some time ago
+func taggedMessages(source string, n int, enqueued time.Time) []kafka.Message {
+	messages := make([]kafka.Message, n)
+	for i := range messages {
+		messages[i] = kafka.Message{Topic: "orders", Value: []byte("v"), WriterData: writeTag{source: source, enqueued: enqueued}}
+	}
+	return messages
+}
+
+func TestCompletionsAttribution(t *testing.T) {
+	c := newCompletions(false, nil, discardLogger())
+	enqueued := time.Now().Add(-50 * time.Millisecond)
+
+	c.complete(taggedMessages("orders-v1", 3, enqueued), nil)
+	c.complete(taggedMessages("orders-v2", 1, enqueued), nil)
+	// Untagged messages count under their topic
+	c.complete([]kafka.Message{{Topic: "audit"}}, nil)
+
+	stats := c.stats()
+	if len(stats) != 3 {
+		t.Fatalf("stats() = %+v, want three sources", stats)
+	}
+	if s := stats["orders-v1"]; s.Delivered != 3 || s.Failed != 0 {
+		t.Errorf("orders-v1 = %+v, want 3 delivered", s)
+	}
+	if s := stats["orders-v1"]; s.P50 < 50*time.Millisecond || s.Max < s.P99 {
+		t.Errorf("orders-v1 latency = %+v, want at least 50ms", s)
+	}
+	if s := stats["audit"]; s.Delivered != 1 || s.Max != 0 {
+		t.Errorf("audit = %+v, want 1 delivered without latency", s)
+	}
+}
+
+func TestCompletionsSyncFailureOrder(t *testing.T) {
+	c := newCompletions(false, nil, discardLogger())
+	messages := taggedMessages("orders", 2, time.Now())
+
+	// A failed attempt is reported by the writer first and may be retried,
+	// so only the final outcome counts
+	c.complete(messages, kafka.NotLeaderForPartition)
+	if s := c.stats()["orders"]; s.Delivered != 0 || s.Failed != 0 {
+		t.Errorf("after a failed attempt = %+v, want nothing counted", s)
+	}
+	c.complete(messages[:1], nil)
+	c.fail(messages[1:])
+
+	if s := c.stats()["orders"]; s.Delivered != 1 || s.Failed != 1 {
+		t.Errorf("stats = %+v, want 1 delivered and 1 failed", s)
+	}
+}
+
+func TestCompletionsAsyncFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	dlq, err := openDeadLetter(&config.DeadLetterConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dlq.close()
+
+	c := newCompletions(true, dlq, discardLogger())
+	c.complete(taggedMessages("orders", 1, time.Now()), nil)
+	c.complete(taggedMessages("orders", 2, time.Now()), kafka.MessageSizeTooLarge)
+
+	if s := c.stats()["orders"]; s.Delivered != 1 || s.Failed != 2 {
+		t.Errorf("stats = %+v, want 1 delivered and 2 failed", s)
+	}
+	if n := dlq.written(); n != 2 {
+		t.Errorf("dead-lettered %d messages, want the 2 failed ones", n)
+	}
+}
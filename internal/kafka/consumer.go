@@ -0,0 +1,149 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+// Consumer reads topics from their current end with one reader per partition
+type Consumer struct {
+	cfg    *config.KafkaConfig
+	logger *slog.Logger
+	client *kafka.Client
+	dialer *kafka.Dialer
+
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	readers []*kafka.Reader
+}
+
+// NewConsumer creates a new Kafka consumer
+func NewConsumer(cfg *config.KafkaConfig, logger *slog.Logger) (*Consumer, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("kafka config is required")
+	}
+
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka transport: %w", err)
+	}
+	dialer, err := newDialer(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka dialer: %w", err)
+	}
+
+	return &Consumer{
+		cfg:    cfg,
+		logger: logger,
+		client: &kafka.Client{Addr: kafka.TCP(cfg.Brokers...), Timeout: cfg.Timeout, Transport: transport},
+		dialer: dialer,
+	}, nil
+}
+
+// Start positions a reader at the current end of every partition of the
+// topics and passes each message read to handle. handle is called
+// concurrently from the partition readers until Close.
+func (c *Consumer) Start(ctx context.Context, topics []string, handle func(kafka.Message)) error {
+	meta, err := c.client.Metadata(ctx, &kafka.MetadataRequest{Topics: topics})
+	if err != nil {
+		return fmt.Errorf("failed to fetch topic metadata: %w", err)
+	}
+
+	offsetReq := &kafka.ListOffsetsRequest{Topics: make(map[string][]kafka.OffsetRequest)}
+	for _, topic := range meta.Topics {
+		if topic.Error != nil {
+			return fmt.Errorf("failed to fetch metadata of topic %s: %w", topic.Name, topic.Error)
+		}
+		for _, p := range topic.Partitions {
+			offsetReq.Topics[topic.Name] = append(offsetReq.Topics[topic.Name], kafka.LastOffsetOf(p.ID))
+		}
+	}
+
+	offsets, err := c.client.ListOffsets(ctx, offsetReq)
+	if err != nil {
+		return fmt.Errorf("failed to list topic offsets: %w", err)
+	}
+
+	readCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	for topic, partitions := range offsets.Topics {
+		for _, p := range partitions {
+			if p.Error != nil {
+				c.Close()
+				return fmt.Errorf("failed to list offset of %s/%d: %w", topic, p.Partition, p.Error)
+			}
+
+			reader := kafka.NewReader(kafka.ReaderConfig{
+				Brokers:   c.cfg.Brokers,
+				Topic:     topic,
+				Partition: p.Partition,
+				Dialer:    c.dialer,
+				MinBytes:  1,
+				MaxBytes:  10e6,
+				MaxWait:   250 * time.Millisecond,
+			})
+			if err := reader.SetOffset(p.LastOffset); err != nil {
+				reader.Close()
+				c.Close()
+				return fmt.Errorf("failed to set offset of %s/%d: %w", topic, p.Partition, err)
+			}
+			c.readers = append(c.readers, reader)
+
+			c.wg.Add(1)
+			go c.consume(readCtx, reader, handle)
+		}
+	}
+
+	c.logger.Info("kafka consumer started",
+		slog.Any("topics", topics),
+		slog.Int("partitions", len(c.readers)),
+	)
+	return nil
+}
+
+// Close stops the readers and waits for pending handler calls
+func (c *Consumer) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+
+	var errs []error
+	for _, reader := range c.readers {
+		if err := reader.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	c.readers = nil
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to close readers: %w", err)
+	}
+	return nil
+}
+
+// consume reads messages until the context is cancelled
+func (c *Consumer) consume(ctx context.Context, reader *kafka.Reader, handle func(kafka.Message)) {
+	defer c.wg.Done()
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				c.logger.Error("failed to read message",
+					slog.String("topic", reader.Config().Topic),
+					slog.Int("partition", reader.Config().Partition),
+					slog.String("error", err.Error()),
+				)
+			}
+			return
+		}
+		handle(msg)
+	}
+}
@@ -0,0 +1,67 @@
+package kafka
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/record"
+	"github.com/segmentio/kafka-go"
+)
+
+// deadLetter appends undeliverable messages to a JSON-lines file
+type deadLetter struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *record.Writer
+	count  uint64
+}
+
+// openDeadLetter opens the dead-letter file for appending
+func openDeadLetter(cfg *config.DeadLetterConfig) (*deadLetter, error) {
+	file, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	return &deadLetter{file: file, writer: record.NewWriter(file)}, nil
+}
+
+// write appends messages with the error that made each of them fail.
+// This method is thread-safe
+func (d *deadLetter) write(messages []kafka.Message, errs []error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, msg := range messages {
+		rec := &record.Record{
+			Topic:     msg.Topic,
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Timestamp: msg.Time,
+			Error:     errs[i].Error(),
+		}
+		for _, h := range msg.Headers {
+			rec.Headers = append(rec.Headers, record.Header{Key: h.Key, Value: h.Value})
+		}
+		if err := d.writer.Write(rec); err != nil {
+			return err
+		}
+		d.count++
+	}
+	return nil
+}
+
+// written returns the number of messages written to the file
+func (d *deadLetter) written() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.count
+}
+
+// close closes the file
+func (d *deadLetter) close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.file.Close()
+}
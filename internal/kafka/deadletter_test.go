@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDeadLetterFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead-letter.jsonl")
+	if err := os.WriteFile(path, []byte("{\"topic\":\"earlier\",\"value\":\"x\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dlq, err := openDeadLetter(&config.DeadLetterConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages := []kafka.Message{
+		{
+			Topic:   "orders",
+			Key:     []byte("order-1"),
+			Value:   []byte(`{"id":"order-1"}`),
+			Headers: []kafka.Header{{Key: "x-seq", Value: []byte("42")}},
+			Time:    time.Date(2024, 11, 20, 10, 30, 0, 123e6, time.UTC),
+		},
+		{Topic: "orders", Value: []byte("plain text")},
+	}
+	errs := []error{kafka.NotLeaderForPartition, errors.New("connection refused")}
+	if err := dlq.write(messages, errs); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if n := dlq.written(); n != 2 {
+		t.Errorf("written() = %d, want 2", n)
+	}
+	if err := dlq.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{
+		`{"topic":"earlier","value":"x"}`,
+		`{"topic":"orders","timestamp":"2024-11-20T10:30:00.123Z","key":"order-1","headers":[{"key":"x-seq","value":"42"}],"value":{"id":"order-1"},"error":"[6] Not Leader For Partition: `,
+		`{"topic":"orders","value":"plain text","error":"connection refused"}`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("dead-letter file has %d lines, want %d:\n%s", len(lines), len(want), data)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(lines[i], w) {
+			t.Errorf("line %d = %s, want it to start with %s", i+1, lines[i], w)
+		}
+	}
+}
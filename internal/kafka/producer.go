@@ -4,17 +4,32 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/alexermolov/go-kafka-pusher/internal/config"
 	"github.com/segmentio/kafka-go"
 )
 
+// Message is a single record handed to the producer
+type Message struct {
+	Key     []byte
+	Value   []byte
+	Headers []kafka.Header
+	Time    time.Time // defaults to the send time
+	Source  string    // payload name delivery results are counted under, defaults to the topic
+}
+
 // Producer handles Kafka message production
 type Producer struct {
-	writer *kafka.Writer
-	cfg    *config.KafkaConfig
-	logger *slog.Logger
+	writer      *kafka.Writer
+	cfg         *config.KafkaConfig
+	logger      *slog.Logger
+	completions *completions
+	deadLetter  *deadLetter
+	session     *session      // idempotent and transactional producing, nil otherwise
+	sequence    atomic.Uint64 // last stamped sequence number
 }
 
 // NewProducer creates a new Kafka producer
@@ -23,8 +38,22 @@ func NewProducer(cfg *config.KafkaConfig, logger *slog.Logger) (*Producer, error
 		return nil, fmt.Errorf("kafka config is required")
 	}
 
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka transport: %w", err)
+	}
+
+	var dlq *deadLetter
+	if cfg.DeadLetter != nil {
+		dlq, err = openDeadLetter(cfg.DeadLetter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	completions := newCompletions(cfg.Async, dlq, logger)
 	writer := &kafka.Writer{
-		Addr:         kafka.TCP(cfg.Brokers...),
+		Addr: kafka.TCP(cfg.Brokers...),
 		// Topic is now set per-message, not at writer level
 		Balancer:     &kafka.Hash{},
 		BatchTimeout: 10 * time.Millisecond,
@@ -35,26 +64,41 @@ func NewProducer(cfg *config.KafkaConfig, logger *slog.Logger) (*Producer, error
 		Compression:  kafka.Snappy,
 		Logger:       kafka.LoggerFunc(logger.Debug),
 		ErrorLogger:  kafka.LoggerFunc(logger.Error),
+		Transport:    transport,
+		// Delivery results are only known here in async mode
+		Completion: completions.complete,
 	}
 
+	applyRetry(writer, cfg)
+
 	// Use manual partitioning if specific partition is configured
 	if cfg.Partition >= 0 {
 		writer.Balancer = nil // Manual partition assignment via Message.Partition
 	}
 
-	return &Producer{
-		writer: writer,
-		cfg:    cfg,
-		logger: logger,
-	}, nil
+	producer := &Producer{
+		writer:      writer,
+		cfg:         cfg,
+		logger:      logger,
+		completions: completions,
+		deadLetter:  dlq,
+	}
+	if cfg.Idempotent {
+		producer.session = newSession(cfg, transport, logger)
+	}
+	return producer, nil
 }
 
 // Send sends a message to Kafka
 func (p *Producer) Send(ctx context.Context, topic string, message []byte) error {
 	msg := kafka.Message{
-		Topic: topic,
-		Value: message,
-		Time:  time.Now(),
+		Topic:      topic,
+		Value:      message,
+		Time:       time.Now(),
+		WriterData: writeTag{source: topic, enqueued: time.Now()},
+	}
+	if p.cfg.Stamp != nil && p.cfg.Stamp.Enabled {
+		msg.Headers = p.stamp(nil)
 	}
 
 	// Set specific partition if configured
@@ -63,7 +107,7 @@ func (p *Producer) Send(ctx context.Context, topic string, message []byte) error
 	}
 
 	start := time.Now()
-	err := p.writer.WriteMessages(ctx, msg)
+	err := p.deliver(ctx, []kafka.Message{msg})
 	duration := time.Since(start)
 
 	if err != nil {
@@ -71,10 +115,10 @@ func (p *Producer) Send(ctx context.Context, topic string, message []byte) error
 			slog.String("error", err.Error()),
 			slog.Duration("duration", duration),
 		)
-		return fmt.Errorf("failed to write message: %w", err)
+		return err
 	}
 
-	p.logger.Info("message sent successfully",
+	p.logger.Info(p.sentMessage("message"),
 		slog.String("topic", topic),
 		slog.Int("size", len(message)),
 		slog.Duration("duration", duration),
@@ -85,6 +129,15 @@ func (p *Producer) Send(ctx context.Context, topic string, message []byte) error
 
 // SendBatch sends multiple messages in a batch
 func (p *Producer) SendBatch(ctx context.Context, topic string, messages [][]byte) error {
+	batch := make([]Message, len(messages))
+	for i, msg := range messages {
+		batch[i] = Message{Value: msg}
+	}
+	return p.SendMessages(ctx, topic, batch)
+}
+
+// SendMessages sends multiple keyed messages in a batch
+func (p *Producer) SendMessages(ctx context.Context, topic string, messages []Message) error {
 	if len(messages) == 0 {
 		return nil
 	}
@@ -92,9 +145,22 @@ func (p *Producer) SendBatch(ctx context.Context, topic string, messages [][]byt
 	kafkaMessages := make([]kafka.Message, len(messages))
 	for i, msg := range messages {
 		kafkaMessages[i] = kafka.Message{
-			Topic: topic,
-			Value: msg,
-			Time:  time.Now(),
+			Topic:   topic,
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: msg.Headers,
+			Time:    msg.Time,
+		}
+		source := msg.Source
+		if source == "" {
+			source = topic
+		}
+		kafkaMessages[i].WriterData = writeTag{source: source, enqueued: time.Now()}
+		if kafkaMessages[i].Time.IsZero() {
+			kafkaMessages[i].Time = time.Now()
+		}
+		if p.cfg.Stamp != nil && p.cfg.Stamp.Enabled {
+			kafkaMessages[i].Headers = p.stamp(msg.Headers)
 		}
 		if p.cfg.Partition >= 0 {
 			kafkaMessages[i].Partition = p.cfg.Partition
@@ -102,7 +168,7 @@ func (p *Producer) SendBatch(ctx context.Context, topic string, messages [][]byt
 	}
 
 	start := time.Now()
-	err := p.writer.WriteMessages(ctx, kafkaMessages...)
+	err := p.deliver(ctx, kafkaMessages)
 	duration := time.Since(start)
 
 	if err != nil {
@@ -111,10 +177,10 @@ func (p *Producer) SendBatch(ctx context.Context, topic string, messages [][]byt
 			slog.Int("count", len(messages)),
 			slog.Duration("duration", duration),
 		)
-		return fmt.Errorf("failed to write batch: %w", err)
+		return err
 	}
 
-	p.logger.Info("batch sent successfully",
+	p.logger.Info(p.sentMessage("batch"),
 		slog.String("topic", topic),
 		slog.Int("count", len(messages)),
 		slog.Duration("duration", duration),
@@ -123,6 +189,110 @@ func (p *Producer) SendBatch(ctx context.Context, topic string, messages [][]byt
 	return nil
 }
 
+// deliver writes messages with the configured retries and spills those that
+// still fail to the dead-letter file
+func (p *Producer) deliver(ctx context.Context, messages []kafka.Message) error {
+	if p.session != nil {
+		return p.deliverSession(ctx, messages)
+	}
+
+	failed, errs := p.writeWithRetry(ctx, messages)
+	if len(failed) == 0 {
+		return nil
+	}
+	return p.reject(failed, errs, len(messages))
+}
+
+// deliverSession writes messages with a producer ID, optionally in a
+// transaction. Its failures are reported like writer failures.
+func (p *Producer) deliverSession(ctx context.Context, messages []kafka.Message) error {
+	written, aborted, failed, err := p.session.send(ctx, messages)
+	p.completions.complete(written, nil)
+	p.completions.abort(aborted)
+	if len(failed) == 0 {
+		return err
+	}
+	return p.reject(failed, repeatError(err, len(failed)), len(messages))
+}
+
+// reject counts messages that could not be written, spills them to the
+// dead-letter file and returns an error describing them
+func (p *Producer) reject(failed []kafka.Message, errs []error, total int) error {
+	p.completions.fail(failed)
+
+	if p.deadLetter == nil {
+		return fmt.Errorf("failed to write %d of %d messages: %w", len(failed), total, errs[0])
+	}
+	if err := p.deadLetter.write(failed, errs); err != nil {
+		return fmt.Errorf("failed to write %d of %d messages: %w, and failed to spill them to the dead-letter file: %v", len(failed), total, errs[0], err)
+	}
+	return fmt.Errorf("failed to write %d of %d messages, spilled to %s: %w", len(failed), total, p.cfg.DeadLetter.Path, errs[0])
+}
+
+// BeginTransaction opens a transaction that spans all sends until
+// EndTransaction. It does nothing unless transactions are configured.
+func (p *Producer) BeginTransaction() {
+	if p.session != nil {
+		p.session.begin()
+	}
+}
+
+// EndTransaction commits the transaction opened by BeginTransaction, or
+// aborts it when err is non-nil or one of its sends failed. Messages
+// discarded by an abort after an error are reported as failed, those of a
+// deliberate abort as aborted.
+func (p *Producer) EndTransaction(ctx context.Context, err error) error {
+	if p.session == nil {
+		return nil
+	}
+
+	committed, aborted, failed, endErr := p.session.finish(ctx, err)
+	p.completions.complete(committed, nil)
+	p.completions.abort(aborted)
+	if len(failed) == 0 {
+		return endErr
+	}
+	return p.reject(failed, repeatError(fmt.Errorf("transaction aborted: %w", endErr), len(failed)), len(failed))
+}
+
+// TransactionStats returns transaction outcomes, and false unless
+// transactions are configured
+func (p *Producer) TransactionStats() (TransactionStats, bool) {
+	if p.session == nil || p.cfg.Transaction == nil {
+		return TransactionStats{}, false
+	}
+	return p.session.transactionStats(), true
+}
+
+// repeatError returns a slice holding err n times
+func repeatError(err error, n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// sentMessage is the log message for a written message or batch; in async
+// mode the write only queued it
+func (p *Producer) sentMessage(what string) string {
+	if p.cfg.Async {
+		return what + " queued for delivery"
+	}
+	return what + " sent successfully"
+}
+
+// stamp appends the send timestamp and sequence number headers, leaving
+// the caller's headers untouched
+func (p *Producer) stamp(headers []kafka.Header) []kafka.Header {
+	out := make([]kafka.Header, len(headers), len(headers)+2)
+	copy(out, headers)
+	return append(out,
+		kafka.Header{Key: p.cfg.Stamp.TimestampHeader, Value: []byte(strconv.FormatInt(time.Now().UnixNano(), 10))},
+		kafka.Header{Key: p.cfg.Stamp.SequenceHeader, Value: []byte(strconv.FormatUint(p.sequence.Add(1), 10))},
+	)
+}
+
 // Close gracefully closes the producer
 func (p *Producer) Close() error {
 	if p.writer == nil {
@@ -130,7 +300,12 @@ func (p *Producer) Close() error {
 	}
 
 	p.logger.Info("closing kafka producer")
-	
+
+	// A transaction left open by an interrupted tick is aborted
+	if err := p.EndTransaction(context.Background(), context.Canceled); err != nil {
+		p.logger.Warn("open transaction aborted", slog.String("error", err.Error()))
+	}
+
 	if err := p.writer.Close(); err != nil {
 		p.logger.Error("failed to close producer",
 			slog.String("error", err.Error()),
@@ -138,9 +313,28 @@ func (p *Producer) Close() error {
 		return fmt.Errorf("failed to close writer: %w", err)
 	}
 
+	// Async completions may still spill messages until the writer is closed
+	if p.deadLetter != nil {
+		if count := p.deadLetter.written(); count > 0 {
+			p.logger.Warn("undeliverable messages written to dead-letter file",
+				slog.String("path", p.cfg.DeadLetter.Path),
+				slog.Uint64("count", count),
+			)
+		}
+		if err := p.deadLetter.close(); err != nil {
+			return fmt.Errorf("failed to close dead-letter file: %w", err)
+		}
+	}
+
 	return nil
 }
 
+// DeliveryStats returns the delivery results by message source. In async
+// mode they are complete only after Close.
+func (p *Producer) DeliveryStats() map[string]DeliveryStats {
+	return p.completions.stats()
+}
+
 // Stats returns producer statistics
 func (p *Producer) Stats() kafka.WriterStats {
 	return p.writer.Stats()
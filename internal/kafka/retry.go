@@ -0,0 +1,133 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+// applyRetry makes the configured retries replace the writer's own ten
+// attempts. Sync writes are retried by writeWithRetry, so the writer tries
+// once; async writes cannot be retried after they are queued, so the writer
+// retries them with the configured backoff before reporting a failure.
+func applyRetry(writer *kafka.Writer, cfg *config.KafkaConfig) {
+	r := cfg.Retry
+	if r == nil {
+		return
+	}
+	writer.MaxAttempts = 1
+	if cfg.Async {
+		writer.MaxAttempts = r.Attempts + 1
+		writer.WriteBackoffMin = r.InitialBackoff
+		writer.WriteBackoffMax = r.MaxBackoff
+	}
+}
+
+// writeWithRetry writes messages, retrying those that failed with a
+// retriable error. It returns the messages that could not be written with
+// their errors.
+func (p *Producer) writeWithRetry(ctx context.Context, messages []kafka.Message) ([]kafka.Message, []error) {
+	attempts := 0
+	if p.cfg.Retry != nil {
+		attempts = p.cfg.Retry.Attempts
+	}
+
+	for attempt := 0; ; attempt++ {
+		failed, errs := p.write(ctx, messages)
+		if len(failed) == 0 {
+			return nil, nil
+		}
+		if attempt == attempts || ctx.Err() != nil || !allRetriable(errs) {
+			return failed, errs
+		}
+
+		delay := backoff(p.cfg.Retry, attempt)
+		p.logger.Warn("retrying failed messages",
+			slog.Int("count", len(failed)),
+			slog.Int("attempt", attempt+1),
+			slog.Duration("backoff", delay),
+			slog.String("error", errs[0].Error()),
+		)
+		select {
+		case <-ctx.Done():
+			return failed, errs
+		case <-time.After(delay):
+		}
+		messages = failed
+	}
+}
+
+// write writes messages once and returns the ones that failed
+func (p *Producer) write(ctx context.Context, messages []kafka.Message) ([]kafka.Message, []error) {
+	err := p.writer.WriteMessages(ctx, messages...)
+	if err == nil {
+		return nil, nil
+	}
+
+	// Partial failures report an error per message
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == len(messages) {
+		var failed []kafka.Message
+		var errs []error
+		for i, msgErr := range writeErrs {
+			if msgErr != nil {
+				failed = append(failed, messages[i])
+				errs = append(errs, msgErr)
+			}
+		}
+		return failed, errs
+	}
+
+	errs := make([]error, len(messages))
+	for i := range errs {
+		errs[i] = err
+	}
+	return messages, errs
+}
+
+// allRetriable reports whether every error is worth retrying
+func allRetriable(errs []error) bool {
+	for _, err := range errs {
+		if !retriable(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// retriable reports whether an error is transient: broker errors marked
+// temporary, network errors and dropped connections
+func retriable(err error) bool {
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) {
+		return kafkaErr.Temporary()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns the delay before a retry: the initial backoff doubled per
+// attempt, capped at the maximum and randomised by the jitter share
+func backoff(cfg *config.RetryConfig, attempt int) time.Duration {
+	delay := cfg.InitialBackoff
+	for i := 0; i < attempt && delay < cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, cfg.MaxBackoff)
+
+	if cfg.Jitter > 0 {
+		spread := float64(delay) * cfg.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return delay
+}
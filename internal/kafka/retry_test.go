@@ -0,0 +1,147 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestApplyRetry(t *testing.T) {
+	retry := &config.RetryConfig{Attempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		name        string
+		cfg         *config.KafkaConfig
+		maxAttempts int
+		backoffMin  time.Duration
+	}{
+		{"no retry keeps the writer default", &config.KafkaConfig{}, 0, 0},
+		{"sync writes once", &config.KafkaConfig{Retry: retry}, 1, 0},
+		{"async retries in the writer", &config.KafkaConfig{Retry: retry, Async: true}, 4, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &kafka.Writer{}
+			applyRetry(writer, tt.cfg)
+			if writer.MaxAttempts != tt.maxAttempts || writer.WriteBackoffMin != tt.backoffMin {
+				t.Errorf("MaxAttempts = %d, WriteBackoffMin = %v, want %d and %v",
+					writer.MaxAttempts, writer.WriteBackoffMin, tt.maxAttempts, tt.backoffMin)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := &config.RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{50, time.Second},
+	}
+	for _, tt := range tests {
+		if got := backoff(cfg, tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	cfg := &config.RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.2}
+
+	for attempt, base := range map[int]time.Duration{0: 100 * time.Millisecond, 1: 200 * time.Millisecond, 10: time.Second} {
+		lo, hi := base-base/5, base+base/5
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 200; i++ {
+			got := backoff(cfg, attempt)
+			if got < lo || got > hi {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", attempt, got, lo, hi)
+			}
+			seen[got] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("backoff(%d) always returned %v, want jitter", attempt, base)
+		}
+	}
+}
+
+func TestRetriable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unknown topic or partition", kafka.UnknownTopicOrPartition, true},
+		{"not leader", fmt.Errorf("write: %w", kafka.NotLeaderForPartition), true},
+		{"network error", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"dropped connection", io.ErrUnexpectedEOF, true},
+		{"message too large", kafka.MessageSizeTooLarge, false},
+		{"authorization", kafka.TopicAuthorizationFailed, false},
+		{"cancelled", context.Canceled, false},
+		// A timed out write is transient; writeWithRetry stops on its own
+		// when the run's context is done
+		{"timeout", context.DeadlineExceeded, true},
+	}
+	for _, tt := range tests {
+		if got := retriable(tt.err); got != tt.want {
+			t.Errorf("retriable(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if allRetriable([]error{kafka.NotLeaderForPartition, kafka.MessageSizeTooLarge}) {
+		t.Error("allRetriable() = true with a permanent error")
+	}
+}
+
+func TestWriteWithRetry(t *testing.T) {
+	// Nothing listens on the discard port, so every write fails with a
+	// retriable network error
+	var logs bytes.Buffer
+	cfg := &config.KafkaConfig{
+		Retry: &config.RetryConfig{Attempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	}
+	writer := &kafka.Writer{Addr: kafka.TCP("127.0.0.1:9")}
+	applyRetry(writer, cfg)
+	p := &Producer{
+		writer: writer,
+		cfg:    cfg,
+		logger: slog.New(slog.NewTextHandler(&logs, nil)),
+	}
+	defer writer.Close()
+
+	messages := []kafka.Message{{Topic: "orders", Value: []byte("a")}, {Topic: "orders", Value: []byte("b")}}
+	failed, errs := p.writeWithRetry(context.Background(), messages)
+	if len(failed) != 2 || len(errs) != 2 {
+		t.Fatalf("writeWithRetry() failed %d messages with %d errors, want 2", len(failed), len(errs))
+	}
+	if n := strings.Count(logs.String(), "retrying failed messages"); n != 2 {
+		t.Errorf("retried %d times, want 2:\n%s", n, logs.String())
+	}
+
+	// A cancelled context is not retried
+	logs.Reset()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if failed, _ := p.writeWithRetry(ctx, messages); len(failed) != 2 {
+		t.Errorf("writeWithRetry() failed %d messages, want 2", len(failed))
+	}
+	if strings.Contains(logs.String(), "retrying") {
+		t.Errorf("retried with a cancelled context:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,479 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/initproducerid"
+)
+
+const (
+	// maxBatchBytes keeps record batches below the broker's default
+	// message.max.bytes of 1MiB
+	maxBatchBytes = 900 * 1024
+
+	// controlAttempts and controlBackoff bound retries of transaction
+	// control requests, which fail transiently while a previous
+	// transaction is still being completed
+	controlAttempts = 10
+	controlBackoff  = 50 * time.Millisecond
+)
+
+// TransactionStats holds transaction outcomes
+type TransactionStats struct {
+	Committed uint64
+	Aborted   uint64 // deliberately aborted
+	Failed    uint64 // aborted after an error
+}
+
+// topicPartition identifies a partition of a topic
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// idempotentInitRequest is an InitProducerId request without a
+// transactional ID. Unlike initproducerid.Request it has no Transaction
+// method, so the transport sends it to any broker instead of looking up a
+// transaction coordinator for an empty ID.
+type idempotentInitRequest initproducerid.Request
+
+// ApiKey implements protocol.Message
+func (r *idempotentInitRequest) ApiKey() protocol.ApiKey { return protocol.InitProducerId }
+
+// session produces record batches with a producer ID and per-partition
+// sequence numbers, optionally inside transactions. kafka-go's Writer only
+// produces anonymously, so this goes through the lower-level Client.
+type session struct {
+	client   *kafka.Client
+	cfg      *config.KafkaConfig
+	txn      *config.TransactionConfig
+	logger   *slog.Logger
+	balancer *kafka.Hash
+
+	mu         sync.Mutex
+	producerID int64 // -1 until initialised or after a failure
+	epoch      int16
+	sequences  map[topicPartition]int32
+	partitions map[string][]int
+	open       bool // a transaction is in progress
+	explicit   bool // the transaction was opened by begin
+	added      map[topicPartition]bool
+	pending    []kafka.Message // written in the transaction opened by begin
+	failure    error           // first send error in the transaction opened by begin
+	stats      TransactionStats
+}
+
+// newSession creates an idempotent or transactional producer session
+func newSession(cfg *config.KafkaConfig, transport *kafka.Transport, logger *slog.Logger) *session {
+	return &session{
+		client:     &kafka.Client{Addr: kafka.TCP(cfg.Brokers...), Timeout: cfg.Timeout, Transport: transport},
+		cfg:        cfg,
+		txn:        cfg.Transaction,
+		logger:     logger,
+		balancer:   &kafka.Hash{},
+		producerID: -1,
+		partitions: make(map[string][]int),
+	}
+}
+
+// send produces messages, in their own transaction unless one was opened
+// with begin. It returns the messages that were durably written, those
+// discarded by a deliberate abort and those that failed. Messages of a
+// transaction opened with begin are only reported by finish.
+func (s *session) send(ctx context.Context, messages []kafka.Message) (written, aborted, failed []kafka.Message, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureProducer(ctx); err != nil {
+		return nil, nil, messages, err
+	}
+
+	implicit := s.txn != nil && !s.open
+	if implicit {
+		s.start(false)
+	}
+
+	written, failed, err = s.produce(ctx, messages)
+	if err != nil {
+		s.producerID = -1 // sequence state is unknown after a failure
+	}
+
+	switch {
+	case implicit:
+		committed, endErr := s.end(ctx, err)
+		if err == nil {
+			err = endErr
+		}
+		if err != nil {
+			// The abort discards whatever part of the batch was written
+			return nil, nil, messages, err
+		}
+		if !committed {
+			return nil, messages, nil, nil
+		}
+		return messages, nil, nil, nil
+	case s.open:
+		s.pending = append(s.pending, written...)
+		if err != nil && s.failure == nil {
+			s.failure = err
+		}
+		return nil, nil, failed, err
+	default:
+		return written, nil, failed, err
+	}
+}
+
+// begin opens a transaction spanning all sends until finish
+func (s *session) begin() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.txn != nil && !s.open {
+		s.start(true)
+	}
+}
+
+// finish commits the transaction opened by begin, or aborts it when err is
+// non-nil or a send failed. It returns the committed messages, those
+// discarded by a deliberate abort and those discarded by an abort after an
+// error.
+func (s *session) finish(ctx context.Context, err error) (committed, aborted, failed []kafka.Message, _ error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.open || !s.explicit {
+		return nil, nil, nil, nil
+	}
+	if err == nil {
+		err = s.failure
+	}
+
+	pending := s.pending
+	ok, endErr := s.end(ctx, err)
+	switch {
+	case err == nil && endErr == nil && ok:
+		return pending, nil, nil, nil
+	case err == nil && endErr == nil:
+		return nil, pending, nil, nil
+	case err != nil:
+		return nil, nil, pending, err
+	default:
+		return nil, nil, pending, endErr
+	}
+}
+
+// transactionStats returns transaction outcomes
+func (s *session) transactionStats() TransactionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// start marks a transaction as open
+func (s *session) start(explicit bool) {
+	s.open = true
+	s.explicit = explicit
+	s.added = make(map[topicPartition]bool)
+	s.pending = nil
+	s.failure = nil
+}
+
+// end commits or aborts the open transaction and reports whether it was
+// committed. A transaction is aborted after an error, and deliberately at
+// the configured abort rate.
+func (s *session) end(ctx context.Context, sendErr error) (bool, error) {
+	s.open = false
+	s.pending = nil
+	if len(s.added) == 0 {
+		// Nothing was produced, there is nothing to complete on the broker
+		return sendErr == nil, nil
+	}
+
+	commit := sendErr == nil && rand.Float64() >= s.txn.AbortRate
+	if s.producerID < 0 {
+		// Re-initialising the producer ID aborts the transaction
+		s.stats.Failed++
+		return false, nil
+	}
+
+	// Complete the transaction even when the run is being cancelled
+	endCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.cfg.Timeout)
+	defer cancel()
+	err := retryControl(endCtx, func() error {
+		res, err := s.client.EndTxn(endCtx, &kafka.EndTxnRequest{
+			TransactionalID: s.txn.ID,
+			ProducerID:      int(s.producerID),
+			ProducerEpoch:   int(s.epoch),
+			Committed:       commit,
+		})
+		if err != nil {
+			return err
+		}
+		return res.Error
+	})
+
+	switch {
+	case err != nil:
+		s.producerID = -1
+		s.stats.Failed++
+		if commit {
+			return false, fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return false, fmt.Errorf("failed to abort transaction: %w", err)
+	case sendErr != nil:
+		s.stats.Failed++
+	case commit:
+		s.stats.Committed++
+	default:
+		s.stats.Aborted++
+		s.logger.Debug("deliberately aborted transaction", slog.String("transactional_id", s.txn.ID))
+	}
+	return commit, nil
+}
+
+// ensureProducer obtains a producer ID, which also aborts any transaction
+// left open by an earlier producer with the same transactional ID
+func (s *session) ensureProducer(ctx context.Context) error {
+	if s.producerID >= 0 {
+		return nil
+	}
+
+	var producerID int64
+	var epoch int16
+	err := retryControl(ctx, func() error {
+		if s.txn == nil {
+			m, err := s.client.Transport.RoundTrip(ctx, s.client.Addr, &idempotentInitRequest{TransactionTimeoutMs: -1, ProducerID: -1, ProducerEpoch: -1})
+			if err != nil {
+				return err
+			}
+			res := m.(*initproducerid.Response)
+			if res.ErrorCode != 0 {
+				return kafka.Error(res.ErrorCode)
+			}
+			producerID, epoch = res.ProducerID, res.ProducerEpoch
+			return nil
+		}
+
+		res, err := s.client.InitProducerID(ctx, &kafka.InitProducerIDRequest{
+			TransactionalID:      s.txn.ID,
+			TransactionTimeoutMs: int(s.txn.Timeout.Milliseconds()),
+			ProducerID:           -1,
+			ProducerEpoch:        -1,
+		})
+		if err != nil {
+			return err
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+		producerID, epoch = int64(res.Producer.ProducerID), int16(res.Producer.ProducerEpoch)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialise producer ID: %w", err)
+	}
+
+	s.producerID, s.epoch = producerID, epoch
+	s.sequences = make(map[topicPartition]int32)
+	if s.open {
+		// The new epoch aborted the open transaction on the broker, so its
+		// partitions must be added again
+		s.added = make(map[topicPartition]bool)
+	}
+	s.logger.Info("producer ID initialised",
+		slog.Int64("producer_id", producerID),
+		slog.Int("epoch", int(epoch)),
+	)
+	return nil
+}
+
+// produce writes messages partition by partition and returns the written
+// messages and those that were not written
+func (s *session) produce(ctx context.Context, messages []kafka.Message) (written, failed []kafka.Message, err error) {
+	groups, order, err := s.partition(ctx, messages)
+	if err != nil {
+		return nil, messages, err
+	}
+
+	for i, tp := range order {
+		n, err := s.producePartition(ctx, tp, groups[tp])
+		written = append(written, groups[tp][:n]...)
+		if err != nil {
+			failed = append(failed, groups[tp][n:]...)
+			for _, rest := range order[i+1:] {
+				failed = append(failed, groups[rest]...)
+			}
+			return written, failed, err
+		}
+	}
+	return written, nil, nil
+}
+
+// producePartition writes the messages of one partition in batches and
+// returns how many were written
+func (s *session) producePartition(ctx context.Context, tp topicPartition, messages []kafka.Message) (int, error) {
+	if s.open && !s.added[tp] {
+		if err := s.addPartition(ctx, tp); err != nil {
+			return 0, err
+		}
+	}
+
+	written := 0
+	for _, chunk := range chunks(messages) {
+		records := encodeRecordBatch(batchHeader{
+			producerID:    s.producerID,
+			producerEpoch: s.epoch,
+			baseSequence:  s.sequences[tp],
+			transactional: s.open,
+		}, chunk)
+
+		req := &kafka.RawProduceRequest{
+			Topic:        tp.topic,
+			Partition:    tp.partition,
+			RequiredAcks: kafka.RequireAll,
+			RawRecords:   protocol.RawRecordSet{Reader: bytes.NewReader(records)},
+		}
+		if s.open {
+			req.TransactionalID = s.txn.ID
+		}
+
+		res, err := s.client.RawProduce(ctx, req)
+		if err == nil && res.Error != nil && !errors.Is(res.Error, kafka.DuplicateSequenceNumber) {
+			err = res.Error
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to produce to %s/%d: %w", tp.topic, tp.partition, err)
+		}
+		s.sequences[tp] += int32(len(chunk))
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+// addPartition registers a partition with the open transaction
+func (s *session) addPartition(ctx context.Context, tp topicPartition) error {
+	err := retryControl(ctx, func() error {
+		res, err := s.client.AddPartitionsToTxn(ctx, &kafka.AddPartitionsToTxnRequest{
+			TransactionalID: s.txn.ID,
+			ProducerID:      int(s.producerID),
+			ProducerEpoch:   int(s.epoch),
+			Topics:          map[string][]kafka.AddPartitionToTxn{tp.topic: {{Partition: tp.partition}}},
+		})
+		if err != nil {
+			return err
+		}
+		for _, p := range res.Topics[tp.topic] {
+			if p.Error != nil {
+				return p.Error
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s/%d to transaction: %w", tp.topic, tp.partition, err)
+	}
+	s.added[tp] = true
+	return nil
+}
+
+// partition groups messages by partition, keeping their order, with the
+// configured partition or the same key hashing as the writer
+func (s *session) partition(ctx context.Context, messages []kafka.Message) (map[topicPartition][]kafka.Message, []topicPartition, error) {
+	groups := make(map[topicPartition][]kafka.Message)
+	var order []topicPartition
+
+	for _, msg := range messages {
+		partition := s.cfg.Partition
+		if partition < 0 {
+			partitions, err := s.topicPartitions(ctx, msg.Topic)
+			if err != nil {
+				return nil, nil, err
+			}
+			partition = s.balancer.Balance(msg, partitions...)
+		}
+
+		tp := topicPartition{topic: msg.Topic, partition: partition}
+		if _, ok := groups[tp]; !ok {
+			order = append(order, tp)
+		}
+		groups[tp] = append(groups[tp], msg)
+	}
+	return groups, order, nil
+}
+
+// topicPartitions returns the partition IDs of a topic, cached after the
+// first lookup
+func (s *session) topicPartitions(ctx context.Context, topic string) ([]int, error) {
+	if partitions, ok := s.partitions[topic]; ok {
+		return partitions, nil
+	}
+
+	meta, err := s.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch metadata of topic %s: %w", topic, err)
+	}
+	if len(meta.Topics) == 0 || meta.Topics[0].Error != nil {
+		var topicErr error
+		if len(meta.Topics) > 0 {
+			topicErr = meta.Topics[0].Error
+		}
+		return nil, fmt.Errorf("failed to fetch metadata of topic %s: %v", topic, topicErr)
+	}
+
+	partitions := make([]int, len(meta.Topics[0].Partitions))
+	for i, p := range meta.Topics[0].Partitions {
+		partitions[i] = p.ID
+	}
+	s.partitions[topic] = partitions
+	return partitions, nil
+}
+
+// chunks splits messages into batches below maxBatchBytes
+func chunks(messages []kafka.Message) [][]kafka.Message {
+	var out [][]kafka.Message
+	start, size := 0, 0
+	for i, msg := range messages {
+		n := len(msg.Key) + len(msg.Value) + 32
+		for _, h := range msg.Headers {
+			n += len(h.Key) + len(h.Value) + 8
+		}
+		if i > start && size+n > maxBatchBytes {
+			out = append(out, messages[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	return append(out, messages[start:])
+}
+
+// retryControl runs a transaction control request, retrying transient
+// errors such as a transaction that is still being completed
+func retryControl(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < controlAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		var kafkaErr kafka.Error
+		if !errors.As(err, &kafkaErr) || !(kafkaErr.Temporary() || kafkaErr == kafka.ConcurrentTransactions) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(controlBackoff * time.Duration(attempt+1)):
+		}
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/addpartitionstotxn"
+	"github.com/segmentio/kafka-go/protocol/endtxn"
+	"github.com/segmentio/kafka-go/protocol/initproducerid"
+	"github.com/segmentio/kafka-go/protocol/produce"
+	"github.com/segmentio/kafka-go/protocol/rawproduce"
+)
+
+// fakeBroker answers the requests of a session and records them. Every
+// InitProducerId bumps the epoch, like a transaction coordinator does.
+type fakeBroker struct {
+	mu         sync.Mutex
+	calls      []string
+	inits      int
+	produceErr []kafka.Error // errors of the next produce requests, 0 for success
+}
+
+// RoundTrip implements kafka.RoundTripper
+func (b *fakeBroker) RoundTrip(_ context.Context, _ net.Addr, req protocol.Message) (protocol.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch r := req.(type) {
+	case *initproducerid.Request:
+		b.calls = append(b.calls, "init")
+		epoch := int16(b.inits)
+		b.inits++
+		return &initproducerid.Response{ProducerID: 7, ProducerEpoch: epoch}, nil
+	case *addpartitionstotxn.Request:
+		res := &addpartitionstotxn.Response{}
+		for _, t := range r.Topics {
+			result := addpartitionstotxn.ResponseResult{Name: t.Name}
+			for _, p := range t.Partitions {
+				b.calls = append(b.calls, fmt.Sprintf("add %s/%d", t.Name, p))
+				result.Results = append(result.Results, addpartitionstotxn.ResponsePartition{PartitionIndex: p})
+			}
+			res.Results = append(res.Results, result)
+		}
+		return res, nil
+	case *rawproduce.Request:
+		t, p := r.Topics[0], r.Topics[0].Partitions[0]
+		var rs protocol.RecordSet
+		if _, err := rs.ReadFrom(p.RecordSet.Reader); err != nil {
+			return nil, err
+		}
+		batch := rs.Records.(*protocol.RecordStream).Records[0].(*protocol.RecordBatch)
+		b.calls = append(b.calls, fmt.Sprintf("produce %s/%d epoch=%d seq=%d txn=%v",
+			t.Topic, p.Partition, batch.ProducerEpoch, batch.BaseSequence, rs.Attributes.Transactional()))
+
+		var code kafka.Error
+		if len(b.produceErr) > 0 {
+			code, b.produceErr = b.produceErr[0], b.produceErr[1:]
+		}
+		return &produce.Response{Topics: []produce.ResponseTopic{{
+			Topic:      t.Topic,
+			Partitions: []produce.ResponsePartition{{Partition: p.Partition, ErrorCode: int16(code)}},
+		}}}, nil
+	case *endtxn.Request:
+		outcome := "abort"
+		if r.Committed {
+			outcome = "commit"
+		}
+		b.calls = append(b.calls, fmt.Sprintf("%s epoch=%d", outcome, r.ProducerEpoch))
+		return &endtxn.Response{}, nil
+	default:
+		return nil, fmt.Errorf("unexpected request %T", req)
+	}
+}
+
+// takeCalls returns and clears the recorded requests
+func (b *fakeBroker) takeCalls() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	calls := b.calls
+	b.calls = nil
+	return calls
+}
+
+// newTestSession creates a transactional session producing to partition 0
+// of the fake broker
+func newTestSession(broker *fakeBroker, abortRate float64) *session {
+	cfg := &config.KafkaConfig{
+		Brokers:     []string{"broker:9092"},
+		Timeout:     time.Second,
+		Transaction: &config.TransactionConfig{ID: "pusher-1", Timeout: time.Minute, AbortRate: abortRate},
+	}
+	s := newSession(cfg, nil, discardLogger())
+	s.client.Transport = broker
+	return s
+}
+
+func checkCalls(t *testing.T, broker *fakeBroker, want ...string) {
+	t.Helper()
+	if got := broker.takeCalls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %q, want %q", got, want)
+	}
+}
+
+func TestSessionCommit(t *testing.T) {
+	broker := &fakeBroker{}
+	s := newTestSession(broker, 0)
+	ctx := context.Background()
+
+	messages := taggedMessages("orders", 2, time.Now())
+	written, aborted, failed, err := s.send(ctx, messages)
+	if err != nil || len(written) != 2 || len(aborted) != 0 || len(failed) != 0 {
+		t.Fatalf("send() = %d written, %d aborted, %d failed, %v, want 2 written", len(written), len(aborted), len(failed), err)
+	}
+	checkCalls(t, broker, "init", "add orders/0", "produce orders/0 epoch=0 seq=0 txn=true", "commit epoch=0")
+
+	// Every batch is its own transaction, sequence numbers continue
+	if _, _, _, err := s.send(ctx, messages); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	checkCalls(t, broker, "add orders/0", "produce orders/0 epoch=0 seq=2 txn=true", "commit epoch=0")
+
+	// A transaction opened by begin spans sends and reports on finish
+	s.begin()
+	for i := 0; i < 2; i++ {
+		if written, _, _, err := s.send(ctx, messages); err != nil || len(written) != 0 {
+			t.Fatalf("send() in transaction = %d written, %v, want nothing reported", len(written), err)
+		}
+	}
+	committed, aborted, failed, err := s.finish(ctx, nil)
+	if err != nil || len(committed) != 4 || len(aborted) != 0 || len(failed) != 0 {
+		t.Fatalf("finish() = %d committed, %d aborted, %d failed, %v, want 4 committed", len(committed), len(aborted), len(failed), err)
+	}
+	checkCalls(t, broker, "add orders/0", "produce orders/0 epoch=0 seq=4 txn=true", "produce orders/0 epoch=0 seq=6 txn=true", "commit epoch=0")
+
+	if stats := s.transactionStats(); stats != (TransactionStats{Committed: 3}) {
+		t.Errorf("transactionStats() = %+v, want 3 committed", stats)
+	}
+}
+
+func TestSessionDeliberateAbort(t *testing.T) {
+	broker := &fakeBroker{}
+	s := newTestSession(broker, 1)
+	ctx := context.Background()
+
+	messages := taggedMessages("orders", 2, time.Now())
+	written, aborted, failed, err := s.send(ctx, messages)
+	if err != nil || len(written) != 0 || len(aborted) != 2 || len(failed) != 0 {
+		t.Fatalf("send() = %d written, %d aborted, %d failed, %v, want 2 aborted", len(written), len(aborted), len(failed), err)
+	}
+	checkCalls(t, broker, "init", "add orders/0", "produce orders/0 epoch=0 seq=0 txn=true", "abort epoch=0")
+
+	s.begin()
+	if _, _, _, err := s.send(ctx, messages); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	committed, aborted, failed, err := s.finish(ctx, nil)
+	if err != nil || len(committed) != 0 || len(aborted) != 2 || len(failed) != 0 {
+		t.Fatalf("finish() = %d committed, %d aborted, %d failed, %v, want 2 aborted", len(committed), len(aborted), len(failed), err)
+	}
+	checkCalls(t, broker, "add orders/0", "produce orders/0 epoch=0 seq=2 txn=true", "abort epoch=0")
+
+	if stats := s.transactionStats(); stats != (TransactionStats{Aborted: 2}) {
+		t.Errorf("transactionStats() = %+v, want 2 aborted", stats)
+	}
+}
+
+func TestSessionFailedSendAborts(t *testing.T) {
+	broker := &fakeBroker{produceErr: []kafka.Error{kafka.MessageSizeTooLarge}}
+	s := newTestSession(broker, 0)
+	ctx := context.Background()
+
+	messages := taggedMessages("orders", 2, time.Now())
+	written, aborted, failed, err := s.send(ctx, messages)
+	if err == nil || len(written) != 0 || len(aborted) != 0 || len(failed) != 2 {
+		t.Fatalf("send() = %d written, %d aborted, %d failed, %v, want 2 failed with an error", len(written), len(aborted), len(failed), err)
+	}
+	// The producer ID is dropped after the failure, re-initialising it
+	// aborts the transaction instead of an EndTxn
+	checkCalls(t, broker, "init", "add orders/0", "produce orders/0 epoch=0 seq=0 txn=true")
+
+	if _, _, _, err := s.send(ctx, messages); err != nil {
+		t.Fatalf("send() after failure error = %v", err)
+	}
+	checkCalls(t, broker, "init", "add orders/0", "produce orders/0 epoch=1 seq=0 txn=true", "commit epoch=1")
+
+	if stats := s.transactionStats(); stats != (TransactionStats{Committed: 1, Failed: 1}) {
+		t.Errorf("transactionStats() = %+v, want 1 committed and 1 failed", stats)
+	}
+}
+
+func TestSessionReinitInTransaction(t *testing.T) {
+	broker := &fakeBroker{produceErr: []kafka.Error{0, kafka.MessageSizeTooLarge}}
+	s := newTestSession(broker, 0)
+	ctx := context.Background()
+
+	s.begin()
+	first := taggedMessages("orders", 1, time.Now())
+	if _, _, failed, err := s.send(ctx, first); err != nil || len(failed) != 0 {
+		t.Fatalf("send() = %d failed, %v, want success", len(failed), err)
+	}
+	if _, _, failed, err := s.send(ctx, taggedMessages("orders", 1, time.Now())); err == nil || len(failed) != 1 {
+		t.Fatalf("send() = %d failed, %v, want 1 failed with an error", len(failed), err)
+	}
+	// The next send obtains a new epoch, which aborted the transaction on
+	// the broker, so the partition is added again and sequences restart
+	last := taggedMessages("orders", 1, time.Now())
+	if _, _, failed, err := s.send(ctx, last); err != nil || len(failed) != 0 {
+		t.Fatalf("send() after re-init = %d failed, %v, want success", len(failed), err)
+	}
+
+	committed, aborted, failed, err := s.finish(ctx, nil)
+	if err == nil || len(committed) != 0 || len(aborted) != 0 || len(failed) != 2 {
+		t.Fatalf("finish() = %d committed, %d aborted, %d failed, %v, want the 2 written messages failed", len(committed), len(aborted), len(failed), err)
+	}
+	checkCalls(t, broker,
+		"init", "add orders/0", "produce orders/0 epoch=0 seq=0 txn=true",
+		"produce orders/0 epoch=0 seq=1 txn=true",
+		"init", "add orders/0", "produce orders/0 epoch=1 seq=0 txn=true",
+		"abort epoch=1",
+	)
+
+	if stats := s.transactionStats(); stats != (TransactionStats{Failed: 1}) {
+		t.Errorf("transactionStats() = %+v, want 1 failed", stats)
+	}
+}
@@ -0,0 +1,224 @@
+package lifecycle
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/template"
+)
+
+// Event is a message emitted by an entity state transition
+type Event struct {
+	Key   []byte
+	Value []byte
+	State string
+}
+
+// Stats holds lifecycle statistics
+type Stats struct {
+	Active    int
+	Created   uint64
+	Completed uint64
+}
+
+// entity is a single in-memory state machine instance
+type entity struct {
+	id         string
+	state      string
+	next       string
+	due        time.Time
+	attributes map[string]interface{}
+}
+
+// Engine drives entities through a configured state machine and renders a
+// message from the payload template for every transition.
+// Template variables `entityId`, `state` and `previousState` are set for
+// each message, alongside the entity attributes.
+type Engine struct {
+	cfg       *config.LifecycleConfig
+	generator *template.Generator
+	mu        sync.Mutex
+	queue     entityQueue
+	created   uint64
+	completed uint64
+}
+
+// NewEngine creates a lifecycle engine on top of a template generator
+func NewEngine(cfg *config.LifecycleConfig, generator *template.Generator) (*Engine, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("lifecycle config is required")
+	}
+	if generator == nil {
+		return nil, fmt.Errorf("generator is required")
+	}
+	if len(cfg.Transitions[cfg.Initial]) == 0 {
+		return nil, fmt.Errorf("initial state %s has no transitions", cfg.Initial)
+	}
+
+	return &Engine{
+		cfg:       cfg,
+		generator: generator,
+	}, nil
+}
+
+// Next returns up to n events. Entities whose next transition is due are
+// advanced first; remaining capacity is used to create new entities as long
+// as fewer than max_entities are in flight.
+// This method is thread-safe
+func (e *Engine) Next(n int) ([]Event, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	events := make([]Event, 0, n)
+
+	for len(events) < n && e.queue.Len() > 0 && !e.queue[0].due.After(now) {
+		ent := heap.Pop(&e.queue).(*entity)
+		previous := ent.state
+		ent.state = ent.next
+
+		event, err := e.render(ent, previous)
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+
+		if e.schedule(ent, now) {
+			heap.Push(&e.queue, ent)
+		} else {
+			e.completed++
+		}
+	}
+
+	for len(events) < n && e.queue.Len() < e.cfg.MaxEntities {
+		ent, err := e.newEntity()
+		if err != nil {
+			return events, err
+		}
+
+		event, err := e.render(ent, "")
+		if err != nil {
+			return events, err
+		}
+		events = append(events, event)
+		e.created++
+
+		e.schedule(ent, now)
+		heap.Push(&e.queue, ent)
+	}
+
+	return events, nil
+}
+
+// Stats returns a snapshot of lifecycle statistics
+func (e *Engine) Stats() Stats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return Stats{
+		Active:    e.queue.Len(),
+		Created:   e.created,
+		Completed: e.completed,
+	}
+}
+
+// newEntity creates an entity in the initial state. The key and attributes
+// are resolved together, so that references to a pool pick the same member.
+func (e *Engine) newEntity() (*entity, error) {
+	exprs := map[string]string{"key": e.cfg.Key}
+	for name, expr := range e.cfg.Attributes {
+		exprs[attributePrefix+name] = expr
+	}
+	resolved, err := e.generator.Resolve(exprs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve entity: %w", err)
+	}
+
+	attributes := make(map[string]interface{}, len(e.cfg.Attributes))
+	for name := range e.cfg.Attributes {
+		attributes[name] = resolved[attributePrefix+name]
+	}
+	return &entity{
+		id:         fmt.Sprint(resolved["key"]),
+		state:      e.cfg.Initial,
+		attributes: attributes,
+	}, nil
+}
+
+// attributePrefix keeps attribute names apart from the key when they are
+// resolved together
+const attributePrefix = "attributes."
+
+// render generates the message for the entity's current state
+func (e *Engine) render(ent *entity, previous string) (Event, error) {
+	vars := make(map[string]interface{}, len(ent.attributes)+3)
+	for key, value := range ent.attributes {
+		vars[key] = value
+	}
+	vars["entityId"] = ent.id
+	vars["state"] = ent.state
+	vars["previousState"] = previous
+
+	value, err := e.generator.GenerateWith(vars)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to generate message for entity %s in state %s: %w", ent.id, ent.state, err)
+	}
+
+	return Event{
+		Key:   []byte(ent.id),
+		Value: value,
+		State: ent.state,
+	}, nil
+}
+
+// schedule picks the next transition of an entity.
+// It returns false when the entity reached a terminal state.
+func (e *Engine) schedule(ent *entity, now time.Time) bool {
+	transitions := e.cfg.Transitions[ent.state]
+	if len(transitions) == 0 {
+		return false
+	}
+
+	t := transitions[len(transitions)-1]
+	roll := rand.Float64()
+	for _, candidate := range transitions {
+		if roll < candidate.Probability {
+			t = candidate
+			break
+		}
+		roll -= candidate.Probability
+	}
+
+	delay := t.Delay
+	if t.MaxDelay > t.Delay {
+		delay += rand.N(t.MaxDelay - t.Delay)
+	}
+
+	ent.next = t.To
+	ent.due = now.Add(delay)
+	return true
+}
+
+// entityQueue is a min-heap of entities ordered by their next due time
+type entityQueue []*entity
+
+func (q entityQueue) Len() int           { return len(q) }
+func (q entityQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+func (q entityQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *entityQueue) Push(x interface{}) {
+	*q = append(*q, x.(*entity))
+}
+
+func (q *entityQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	ent := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return ent
+}
@@ -0,0 +1,195 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/template"
+)
+
+func newTestGenerator(t *testing.T, opts ...template.Option) *template.Generator {
+	t.Helper()
+
+	content := `
+substitution:
+  now: "{{@now|RFC3339}}"
+
+template:
+  orderId: "{{.entityId}}"
+  status: "{{.state}}"
+  previous: "{{.previousState}}"
+  amount: "{{.amount}}"
+  updatedAt: "{{.now}}"
+`
+	path := filepath.Join(t.TempDir(), "order.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := template.NewGenerator(path, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gen
+}
+
+func TestEngineWalksStateMachine(t *testing.T) {
+	cfg := &config.LifecycleConfig{
+		Initial:     "CREATED",
+		Key:         "{{@uuid}}",
+		MaxEntities: 10,
+		Attributes:  map[string]string{"amount": "{{@rnd|4}}"},
+		Transitions: map[string][]config.TransitionConfig{
+			"CREATED": {
+				{To: "PAID", Probability: 0.7},
+				{To: "CANCELLED", Probability: 0.3},
+			},
+			"PAID":    {{To: "SHIPPED", Probability: 1}},
+			"SHIPPED": {{To: "DELIVERED", Probability: 1}},
+		},
+	}
+
+	engine, err := NewEngine(cfg, newTestGenerator(t))
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	allowed := map[string]map[string]bool{
+		"":        {"CREATED": true},
+		"CREATED": {"PAID": true, "CANCELLED": true},
+		"PAID":    {"SHIPPED": true},
+		"SHIPPED": {"DELIVERED": true},
+	}
+
+	type order struct {
+		OrderID  string `json:"orderId"`
+		Status   string `json:"status"`
+		Previous string `json:"previous"`
+		Amount   string `json:"amount"`
+	}
+	last := make(map[string]order)
+
+	for i := 0; i < 20; i++ {
+		events, err := engine.Next(5)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+
+		for _, event := range events {
+			var o order
+			if err := json.Unmarshal(event.Value, &o); err != nil {
+				t.Fatalf("Generated message is not valid JSON: %v", err)
+			}
+			if string(event.Key) != o.OrderID {
+				t.Errorf("Expected key %s to match entity id %s", event.Key, o.OrderID)
+			}
+			if event.State != o.Status {
+				t.Errorf("Expected event state %s, got %s", o.Status, event.State)
+			}
+
+			prev := last[o.OrderID]
+			if prev.Status != o.Previous {
+				t.Errorf("Entity %s: expected previous state %q, got %q", o.OrderID, prev.Status, o.Previous)
+			}
+			if !allowed[prev.Status][o.Status] {
+				t.Errorf("Entity %s: invalid transition %q -> %q", o.OrderID, prev.Status, o.Status)
+			}
+			if prev.Amount != "" && prev.Amount != o.Amount {
+				t.Errorf("Entity %s: attributes changed between messages", o.OrderID)
+			}
+			last[o.OrderID] = o
+		}
+	}
+
+	stats := engine.Stats()
+	if stats.Completed == 0 {
+		t.Error("Expected some entities to reach a terminal state")
+	}
+	if stats.Active > cfg.MaxEntities {
+		t.Errorf("Expected at most %d active entities, got %d", cfg.MaxEntities, stats.Active)
+	}
+	if stats.Created != uint64(len(last)) {
+		t.Errorf("Expected %d created entities, got %d", len(last), stats.Created)
+	}
+}
+
+func TestEngineResolvesPoolMemberOnce(t *testing.T) {
+	pool, err := template.NewPool("customers", &config.PoolConfig{
+		Size:   3,
+		Fields: map[string]string{"id": "{{@uuid}}", "name": "{{@rnd|8}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.LifecycleConfig{
+		Initial:     "CREATED",
+		Key:         "{{@ref|customers.id}}",
+		MaxEntities: 50,
+		Attributes:  map[string]string{"amount": "{{@ref|customers.name}}"},
+		Transitions: map[string][]config.TransitionConfig{
+			"CREATED": {{To: "DONE", Probability: 1}},
+		},
+	}
+	engine, err := NewEngine(cfg, newTestGenerator(t, template.WithPools(map[string]*template.Pool{"customers": pool})))
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	names := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		events, err := engine.Next(10)
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		for _, event := range events {
+			var o struct {
+				Amount string `json:"amount"`
+			}
+			if err := json.Unmarshal(event.Value, &o); err != nil {
+				t.Fatalf("Generated message is not valid JSON: %v", err)
+			}
+			key := string(event.Key)
+			if name, ok := names[key]; ok && name != o.Amount {
+				t.Fatalf("Customer %s: attribute %s comes from another member than %s", key, o.Amount, name)
+			}
+			names[key] = o.Amount
+		}
+	}
+}
+
+func TestEngineHonoursDelays(t *testing.T) {
+	cfg := &config.LifecycleConfig{
+		Initial:     "CREATED",
+		Key:         "{{@uuid}}",
+		MaxEntities: 2,
+		Transitions: map[string][]config.TransitionConfig{
+			"CREATED": {{To: "PAID", Probability: 1, Delay: time.Hour}},
+		},
+	}
+
+	engine, err := NewEngine(cfg, newTestGenerator(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := engine.Next(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 new entities limited by max_entities, got %d", len(events))
+	}
+
+	// Transitions are not due yet and no capacity is left for new entities
+	events, err = engine.Next(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events before delay elapsed, got %d", len(events))
+	}
+}
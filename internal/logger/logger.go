@@ -20,6 +20,9 @@ func New(cfg *config.LoggingConfig) *slog.Logger {
 	}
 
 	writer := io.Writer(os.Stdout)
+	if cfg.Output == "stderr" {
+		writer = os.Stderr
+	}
 	
 	switch strings.ToLower(cfg.Format) {
 	case "json":
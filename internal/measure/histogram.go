@@ -0,0 +1,89 @@
+package measure
+
+import (
+	"math"
+	"time"
+)
+
+// precision is the relative width of a histogram bucket
+const precision = 0.01
+
+// logBase is the natural logarithm of the bucket growth factor
+var logBase = math.Log1p(precision)
+
+// Histogram records durations in logarithmic buckets, keeping percentiles
+// within about 1% of the exact value in constant memory
+type Histogram struct {
+	counts []uint64
+	count  uint64
+	max    time.Duration
+}
+
+// Record adds a duration; negative durations, e.g. from clock skew between
+// hosts, are recorded as zero
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	b := bucket(d)
+	if b >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, b-len(h.counts)+1)...)
+	}
+	h.counts[b]++
+	h.count++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// Count returns the number of recorded durations
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Max returns the largest recorded duration
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Percentile returns the upper bound of the bucket holding the p-th
+// percentile, p in [0, 1]
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(p * float64(h.count)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for b, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			return min(upperBound(b), h.max)
+		}
+	}
+	return h.max
+}
+
+// Reset clears the histogram
+func (h *Histogram) Reset() {
+	clear(h.counts)
+	h.count = 0
+	h.max = 0
+}
+
+// bucket returns the bucket index of a duration; bucket 0 holds everything
+// up to a microsecond
+func bucket(d time.Duration) int {
+	if d <= time.Microsecond {
+		return 0
+	}
+	return int(math.Ceil(math.Log(float64(d)/float64(time.Microsecond)) / logBase))
+}
+
+// upperBound returns the largest duration of a bucket
+func upperBound(b int) time.Duration {
+	return time.Duration(float64(time.Microsecond) * math.Exp(float64(b)*logBase))
+}
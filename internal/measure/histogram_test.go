@@ -0,0 +1,50 @@
+package measure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramPercentile(t *testing.T) {
+	var h Histogram
+	for i := 1; i <= 1000; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0.50, 500 * time.Millisecond},
+		{0.95, 950 * time.Millisecond},
+		{0.99, 990 * time.Millisecond},
+		{1, 1000 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		got := h.Percentile(tt.p)
+		if got < tt.want || float64(got) > float64(tt.want)*(1+precision) {
+			t.Errorf("Percentile(%v) = %v, want within 1%% above %v", tt.p, got, tt.want)
+		}
+	}
+	if h.Max() != time.Second {
+		t.Errorf("Max() = %v, want 1s", h.Max())
+	}
+}
+
+func TestHistogramEdgeCases(t *testing.T) {
+	var h Histogram
+	if got := h.Percentile(0.5); got != 0 {
+		t.Errorf("Percentile() on empty histogram = %v, want 0", got)
+	}
+
+	h.Record(-time.Second)
+	h.Record(0)
+	if h.Count() != 2 || h.Max() != 0 {
+		t.Errorf("Count() = %d, Max() = %v, want 2 and 0", h.Count(), h.Max())
+	}
+
+	h.Reset()
+	if h.Count() != 0 {
+		t.Errorf("Count() after Reset() = %d, want 0", h.Count())
+	}
+}
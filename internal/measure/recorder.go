@@ -0,0 +1,145 @@
+package measure
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Snapshot summarises the messages received over a period
+type Snapshot struct {
+	Count      uint64
+	Unstamped  uint64 // messages without a valid timestamp header
+	Reordered  uint64 // messages with a lower sequence than an earlier one on the same partition
+	Elapsed    time.Duration
+	Throughput float64 // messages per second
+	P50        time.Duration
+	P95        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+}
+
+// counters holds the per-period statistics
+type counters struct {
+	latency   Histogram
+	unstamped uint64
+	reordered uint64
+	start     time.Time
+}
+
+// partition identifies a topic partition
+type partition struct {
+	topic string
+	id    int
+}
+
+// Recorder computes end-to-end latency from the send timestamp headers
+// stamped by the producer, over the whole run and per reporting window
+type Recorder struct {
+	timestampHeader string
+	sequenceHeader  string
+
+	mu      sync.Mutex
+	total   counters
+	window  counters
+	lastSeq map[partition]uint64
+}
+
+// NewRecorder creates a recorder reading the stamp headers, started at now
+func NewRecorder(cfg *config.StampConfig, now time.Time) *Recorder {
+	r := &Recorder{
+		timestampHeader: "x-sent-at",
+		sequenceHeader:  "x-seq",
+		total:           counters{start: now},
+		window:          counters{start: now},
+		lastSeq:         make(map[partition]uint64),
+	}
+	if cfg != nil && cfg.TimestampHeader != "" {
+		r.timestampHeader = cfg.TimestampHeader
+	}
+	if cfg != nil && cfg.SequenceHeader != "" {
+		r.sequenceHeader = cfg.SequenceHeader
+	}
+	return r
+}
+
+// Observe records a message received at the given time.
+// This method is thread-safe
+func (r *Recorder) Observe(msg kafkago.Message, at time.Time) {
+	var sentAt int64
+	var seq uint64
+	var stamped, sequenced bool
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case r.timestampHeader:
+			v, err := strconv.ParseInt(string(h.Value), 10, 64)
+			sentAt, stamped = v, err == nil
+		case r.sequenceHeader:
+			v, err := strconv.ParseUint(string(h.Value), 10, 64)
+			seq, sequenced = v, err == nil
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !stamped {
+		r.total.unstamped++
+		r.window.unstamped++
+		return
+	}
+	latency := at.Sub(time.Unix(0, sentAt))
+	r.total.latency.Record(latency)
+	r.window.latency.Record(latency)
+
+	if sequenced {
+		p := partition{topic: msg.Topic, id: msg.Partition}
+		if seq < r.lastSeq[p] {
+			r.total.reordered++
+			r.window.reordered++
+		} else {
+			r.lastSeq[p] = seq
+		}
+	}
+}
+
+// Window returns the statistics since the previous call and starts a new
+// window at now
+func (r *Recorder) Window(now time.Time) Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.window.snapshot(now)
+	r.window.latency.Reset()
+	r.window.unstamped, r.window.reordered = 0, 0
+	r.window.start = now
+	return s
+}
+
+// Total returns the statistics of the whole run
+func (r *Recorder) Total(now time.Time) Snapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.total.snapshot(now)
+}
+
+// snapshot summarises the counters up to now
+func (c *counters) snapshot(now time.Time) Snapshot {
+	s := Snapshot{
+		Count:     c.latency.Count(),
+		Unstamped: c.unstamped,
+		Reordered: c.reordered,
+		Elapsed:   now.Sub(c.start),
+		P50:       c.latency.Percentile(0.50),
+		P95:       c.latency.Percentile(0.95),
+		P99:       c.latency.Percentile(0.99),
+		Max:       c.latency.Max(),
+	}
+	if s.Elapsed > 0 {
+		s.Throughput = float64(s.Count+s.Unstamped) / s.Elapsed.Seconds()
+	}
+	return s
+}
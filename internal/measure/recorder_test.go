@@ -0,0 +1,63 @@
+package measure
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+func stamped(partition int, sentAt time.Time, seq uint64) kafkago.Message {
+	return kafkago.Message{
+		Topic:     "out",
+		Partition: partition,
+		Headers: []kafkago.Header{
+			{Key: "sent", Value: []byte(strconv.FormatInt(sentAt.UnixNano(), 10))},
+			{Key: "seq", Value: []byte(strconv.FormatUint(seq, 10))},
+		},
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	r := NewRecorder(&config.StampConfig{TimestampHeader: "sent", SequenceHeader: "seq"}, start)
+
+	r.Observe(stamped(0, start, 1), start.Add(10*time.Millisecond))
+	r.Observe(stamped(0, start, 3), start.Add(20*time.Millisecond))
+	r.Observe(stamped(0, start, 2), start.Add(30*time.Millisecond))
+	r.Observe(stamped(1, start, 1), start.Add(40*time.Millisecond))
+	r.Observe(kafkago.Message{Topic: "out"}, start)
+
+	w := r.Window(start.Add(time.Second))
+	if w.Count != 4 || w.Unstamped != 1 || w.Reordered != 1 {
+		t.Errorf("Window() = %+v, want 4 stamped, 1 unstamped, 1 reordered", w)
+	}
+	if w.Throughput != 5 {
+		t.Errorf("Throughput = %v, want 5", w.Throughput)
+	}
+	if w.Max != 40*time.Millisecond {
+		t.Errorf("Max = %v, want 40ms", w.Max)
+	}
+
+	r.Observe(stamped(0, start, 4), start.Add(time.Second+50*time.Millisecond))
+	if w := r.Window(start.Add(2 * time.Second)); w.Count != 1 || w.Reordered != 0 {
+		t.Errorf("second Window() = %+v, want 1 message and no reordering", w)
+	}
+	if total := r.Total(start.Add(2 * time.Second)); total.Count != 5 || total.Reordered != 1 {
+		t.Errorf("Total() = %+v, want 5 messages and 1 reordered", total)
+	}
+}
+
+func TestRecorderDefaultHeaders(t *testing.T) {
+	now := time.Now()
+	r := NewRecorder(nil, now)
+	r.Observe(kafkago.Message{Headers: []kafkago.Header{
+		{Key: "x-sent-at", Value: []byte(strconv.FormatInt(now.UnixNano(), 10))},
+	}}, now)
+
+	if s := r.Total(now); s.Count != 1 || s.Unstamped != 0 {
+		t.Errorf("Total() = %+v, want one stamped message", s)
+	}
+}
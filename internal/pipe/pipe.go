@@ -0,0 +1,242 @@
+package pipe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/record"
+	"github.com/alexermolov/go-kafka-pusher/internal/template"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Input line formats
+const (
+	FormatRaw  = "raw"  // the whole line is the value
+	FormatTSV  = "tsv"  // key<TAB>value
+	FormatJSON = "json" // a record envelope with key, headers and value
+)
+
+// Sender publishes batches of messages to a topic
+type Sender interface {
+	SendMessages(ctx context.Context, topic string, messages []kafka.Message) error
+}
+
+// Options describes how input lines are turned into messages
+type Options struct {
+	Topic     string
+	Format    string  // raw (default), tsv or json
+	BatchSize int     // messages per batch
+	Rate      float64 // messages per second, 0 for as fast as possible
+	Key       []byte  // key of messages whose line has none
+	Headers   []kafkago.Header
+}
+
+// Stats holds pipe statistics
+type Stats struct {
+	Records uint64
+	Batches uint64
+}
+
+// Pipe publishes newline-delimited records read from a stream
+type Pipe struct {
+	opts      Options
+	sender    Sender
+	generator *template.Generator
+	stats     Stats
+}
+
+// New creates a pipe. The generator expands directives embedded in keys,
+// values and headers, e.g. `{{@uuid}}`, and may be nil to send lines as
+// they are.
+func New(opts Options, sender Sender, generator *template.Generator) (*Pipe, error) {
+	if opts.Topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+	if sender == nil {
+		return nil, fmt.Errorf("sender is required")
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = FormatRaw
+	case FormatRaw, FormatTSV, FormatJSON:
+	default:
+		return nil, fmt.Errorf("unknown input format %q, expected raw, tsv or json", opts.Format)
+	}
+	if opts.Rate < 0 {
+		return nil, fmt.Errorf("rate must not be negative")
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 1
+	}
+
+	return &Pipe{
+		opts:      opts,
+		sender:    sender,
+		generator: generator,
+	}, nil
+}
+
+// Run sends every non-empty line of r until it is exhausted or the context
+// is cancelled
+func (p *Pipe) Run(ctx context.Context, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	start := time.Now()
+	var line, index int
+	var batch []kafka.Message
+
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(bytes.TrimSpace(text)) == 0 {
+			continue
+		}
+
+		rec, err := Parse(text, p.opts.Format)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		msg, err := p.Message(rec)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		// Flush what is pending before waiting for a message that is not yet due
+		if wait := time.Until(p.dueTime(start, index)); wait > 0 {
+			if err := p.flush(ctx, batch); err != nil {
+				return err
+			}
+			batch = nil
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+		}
+		index++
+
+		batch = append(batch, msg)
+		if len(batch) >= p.opts.BatchSize {
+			if err := p.flush(ctx, batch); err != nil {
+				return err
+			}
+			batch = nil
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+
+	return p.flush(ctx, batch)
+}
+
+// Stats returns pipe statistics. It must not be called while Run is active.
+func (p *Pipe) Stats() Stats {
+	return p.stats
+}
+
+// Message builds the message of a parsed record, applying the default key
+// and headers and expanding directives
+func (p *Pipe) Message(rec *record.Record) (kafka.Message, error) {
+	msg := kafka.Message{
+		Key:     rec.Key,
+		Value:   rec.Value,
+		Time:    rec.Timestamp,
+		Headers: append([]kafkago.Header(nil), p.opts.Headers...),
+	}
+	if msg.Key == nil && len(p.opts.Key) > 0 {
+		msg.Key = p.opts.Key
+	}
+	for _, h := range rec.Headers {
+		msg.Headers = append(msg.Headers, kafkago.Header{Key: h.Key, Value: h.Value})
+	}
+
+	if p.generator == nil {
+		return msg, nil
+	}
+
+	// Key, value and header values are expanded together so that pool
+	// references resolve to the same member
+	texts := []string{string(msg.Key), string(msg.Value)}
+	for _, h := range msg.Headers {
+		texts = append(texts, string(h.Value))
+	}
+	expanded, err := p.generator.Expand(texts)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	if msg.Key != nil {
+		msg.Key = []byte(expanded[0])
+	}
+	msg.Value = []byte(expanded[1])
+	for i := range msg.Headers {
+		msg.Headers[i].Value = []byte(expanded[i+2])
+	}
+	return msg, nil
+}
+
+// dueTime returns when the message with the given index should be sent
+func (p *Pipe) dueTime(start time.Time, index int) time.Time {
+	if p.opts.Rate == 0 {
+		return start
+	}
+	return start.Add(time.Duration(float64(index) / p.opts.Rate * float64(time.Second)))
+}
+
+// flush sends pending messages as one batch
+func (p *Pipe) flush(ctx context.Context, batch []kafka.Message) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := p.sender.SendMessages(ctx, p.opts.Topic, batch); err != nil {
+		return fmt.Errorf("failed to send batch to %s: %w", p.opts.Topic, err)
+	}
+	p.stats.Batches++
+	p.stats.Records += uint64(len(batch))
+	return nil
+}
+
+// Parse decodes one input line in the given format. The line is copied, so
+// the caller may reuse it.
+func Parse(line []byte, format string) (*record.Record, error) {
+	switch format {
+	case "", FormatRaw:
+		return &record.Record{Value: bytes.Clone(line)}, nil
+	case FormatTSV:
+		key, value, ok := bytes.Cut(line, []byte("\t"))
+		if !ok {
+			return nil, fmt.Errorf("expected key<TAB>value")
+		}
+		rec := &record.Record{Value: bytes.Clone(value)}
+		if len(key) > 0 {
+			// An empty key is absent, so that the default key applies
+			rec.Key = bytes.Clone(key)
+		}
+		return rec, nil
+	case FormatJSON:
+		var rec record.Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("invalid JSON envelope: %w", err)
+		}
+		if rec.Value == nil {
+			return nil, fmt.Errorf("JSON envelope has no value")
+		}
+		return &rec, nil
+	default:
+		return nil, fmt.Errorf("unknown input format %q", format)
+	}
+}
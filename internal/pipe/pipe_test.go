@@ -0,0 +1,179 @@
+package pipe
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/template"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+type fakeSender struct {
+	batches [][]kafka.Message
+	times   []time.Time
+}
+
+func (f *fakeSender) SendMessages(_ context.Context, topic string, messages []kafka.Message) error {
+	f.batches = append(f.batches, messages)
+	f.times = append(f.times, time.Now())
+	return nil
+}
+
+func TestParse(t *testing.T) {
+	rec, err := Parse([]byte("k1\t{\"a\":1}"), FormatTSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rec.Key) != "k1" || string(rec.Value) != `{"a":1}` {
+		t.Errorf("unexpected tsv record %q=%q", rec.Key, rec.Value)
+	}
+
+	rec, err = Parse([]byte("\tvalue"), FormatTSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Key != nil || string(rec.Value) != "value" {
+		t.Errorf("expected an empty tsv key to be absent, got %q=%q", rec.Key, rec.Value)
+	}
+
+	rec, err = Parse([]byte(`{"key":"k2","headers":{"h":"v"},"value":{"b":2}}`), FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rec.Key) != "k2" || string(rec.Value) != `{"b":2}` {
+		t.Errorf("unexpected json record %q=%q", rec.Key, rec.Value)
+	}
+	if len(rec.Headers) != 1 || rec.Headers[0].Key != "h" || string(rec.Headers[0].Value) != "v" {
+		t.Errorf("unexpected headers %v", rec.Headers)
+	}
+
+	line := []byte("plain text")
+	rec, err = Parse(line, FormatRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line[0] = 'X'
+	if string(rec.Value) != "plain text" || rec.Key != nil {
+		t.Errorf("expected a copied value without key, got %q=%q", rec.Key, rec.Value)
+	}
+
+	for _, tc := range []struct {
+		format, line string
+	}{
+		{FormatTSV, "no tab"},
+		{FormatJSON, "not json"},
+		{FormatJSON, `{"key":"k"}`},
+	} {
+		if _, err := Parse([]byte(tc.line), tc.format); err == nil {
+			t.Errorf("expected %s line %q to fail", tc.format, tc.line)
+		}
+	}
+}
+
+func TestPipeBatchesWithDefaults(t *testing.T) {
+	sender := &fakeSender{}
+	p, err := New(Options{
+		Topic:     "orders",
+		Format:    FormatJSON,
+		BatchSize: 2,
+		Key:       []byte("default"),
+		Headers:   []kafkago.Header{{Key: "source", Value: []byte("pipe")}},
+	}, sender, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := `{"value":{"n":1}}
+
+{"key":"own","value":{"n":2},"headers":{"h":"v"}}
+{"value":"text"}
+`
+	if err := p.Run(context.Background(), strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.batches) != 2 || len(sender.batches[0]) != 2 || len(sender.batches[1]) != 1 {
+		t.Fatalf("expected batches of 2 and 1, got %v", sender.batches)
+	}
+	first, second := sender.batches[0][0], sender.batches[0][1]
+	if string(first.Key) != "default" || string(second.Key) != "own" {
+		t.Errorf("unexpected keys %q and %q", first.Key, second.Key)
+	}
+	if len(second.Headers) != 2 || second.Headers[0].Key != "source" || second.Headers[1].Key != "h" {
+		t.Errorf("unexpected headers %v", second.Headers)
+	}
+	if string(sender.batches[1][0].Value) != "text" {
+		t.Errorf("unexpected value %q", sender.batches[1][0].Value)
+	}
+	if stats := p.Stats(); stats.Records != 3 || stats.Batches != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPipeReportsLine(t *testing.T) {
+	p, err := New(Options{Topic: "orders", Format: FormatTSV}, &fakeSender{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Run(context.Background(), strings.NewReader("a\t1\nbroken\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error naming line 2, got %v", err)
+	}
+}
+
+func TestPipeExpandsDirectives(t *testing.T) {
+	gen, err := template.New(&template.Template{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := &fakeSender{}
+	p, err := New(Options{Topic: "orders", BatchSize: 10}, sender, gen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Run(context.Background(), strings.NewReader("id={{@uuid}}\nid={{@uuid}}\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := string(sender.batches[0][0].Value), string(sender.batches[0][1].Value)
+	if len(first) != len("id=")+36 || first == second {
+		t.Errorf("expected two different expanded ids, got %s and %s", first, second)
+	}
+}
+
+func TestPipeRate(t *testing.T) {
+	sender := &fakeSender{}
+	p, err := New(Options{Topic: "orders", BatchSize: 10, Rate: 20}, sender, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := p.Run(context.Background(), strings.NewReader("1\n2\n3\n4\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Four messages at 20 per second take at least 150ms and are flushed
+	// one by one while waiting
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("expected the rate to be limited, took %s", elapsed)
+	}
+	if p.Stats().Records != 4 || len(sender.batches) < 3 {
+		t.Errorf("expected 4 messages in several batches, got %d batches", len(sender.batches))
+	}
+}
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Format: FormatRaw},
+		{Topic: "orders", Format: "csv"},
+		{Topic: "orders", Rate: -1},
+	} {
+		if _, err := New(opts, &fakeSender{}, nil); err == nil {
+			t.Errorf("expected options %+v to be rejected", opts)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Header is a single record header
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Record is a captured Kafka message stored as one JSON object per line.
+//
+// Values holding a compact JSON object or array are stored inline, other
+// values are stored as strings, or base64-encoded when they are not valid
+// UTF-8, so that values read back are byte-exact. Headers are stored as an
+// ordered list. Besides this format, kcat `-J` output (`payload`, `ts` and
+// flat header arrays) and headers as an object are accepted when reading.
+type Record struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+	Timestamp time.Time
+	Error     string // why the record could not be delivered, for dead letters
+}
+
+// jsonRecord is the on-disk representation of a record
+type jsonRecord struct {
+	Topic         string          `json:"topic,omitempty"`
+	Partition     int             `json:"partition,omitempty"`
+	Offset        int64           `json:"offset,omitempty"`
+	Timestamp     json.RawMessage `json:"timestamp,omitempty"`
+	TS            json.RawMessage `json:"ts,omitempty"`
+	Key           json.RawMessage `json:"key,omitempty"`
+	KeyEncoding   string          `json:"key_encoding,omitempty"`
+	Headers       json.RawMessage `json:"headers,omitempty"`
+	Value         json.RawMessage `json:"value,omitempty"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	ValueEncoding string          `json:"value_encoding,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// jsonHeader is the on-disk representation of a header
+type jsonHeader struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// MarshalJSON encodes the record in the JSON-lines format
+func (r Record) MarshalJSON() ([]byte, error) {
+	out := jsonRecord{
+		Topic:     r.Topic,
+		Partition: r.Partition,
+		Offset:    r.Offset,
+		Error:     r.Error,
+	}
+
+	var err error
+	if !r.Timestamp.IsZero() {
+		out.Timestamp, _ = json.Marshal(r.Timestamp.Format(time.RFC3339Nano))
+	}
+	if r.Key != nil {
+		out.Key, out.KeyEncoding, err = encodeBytes(r.Key, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.Value != nil {
+		out.Value, out.ValueEncoding, err = encodeBytes(r.Value, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(r.Headers) > 0 {
+		headers := make([]jsonHeader, len(r.Headers))
+		for i, h := range r.Headers {
+			headers[i] = jsonHeader{Key: h.Key, Value: string(h.Value)}
+			if !utf8.Valid(h.Value) {
+				headers[i].Value, headers[i].Encoding = base64.StdEncoding.EncodeToString(h.Value), "base64"
+			}
+		}
+		out.Headers, err = json.Marshal(headers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a record from the JSON-lines or kcat format
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var in jsonRecord
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*r = Record{
+		Topic:     in.Topic,
+		Partition: in.Partition,
+		Offset:    in.Offset,
+		Error:     in.Error,
+	}
+
+	var err error
+	ts := in.Timestamp
+	if len(ts) == 0 {
+		ts = in.TS
+	}
+	if r.Timestamp, err = decodeTime(ts); err != nil {
+		return fmt.Errorf("invalid timestamp: %w", err)
+	}
+	if r.Key, err = decodeBytes(in.Key, in.KeyEncoding); err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	value := in.Value
+	if len(value) == 0 {
+		value = in.Payload
+	}
+	if r.Value, err = decodeBytes(value, in.ValueEncoding); err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+	if r.Headers, err = decodeHeaders(in.Headers); err != nil {
+		return fmt.Errorf("invalid headers: %w", err)
+	}
+
+	return nil
+}
+
+// Reader reads records from a JSON-lines stream
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader creates a record reader
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &Reader{scanner: scanner}
+}
+
+// Next returns the next record, skipping blank lines.
+// It returns io.EOF when the stream is exhausted.
+func (r *Reader) Next() (*Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := bytes.TrimSpace(r.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", r.line, err)
+		}
+		return &rec, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read records: %w", err)
+	}
+	return nil, io.EOF
+}
+
+// Writer writes records as JSON lines
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter creates a record writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes a record as a single line
+func (w *Writer) Write(rec *Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	if _, err := w.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// encodeBytes picks the most readable representation for raw bytes
+func encodeBytes(b []byte, allowInline bool) (json.RawMessage, string, error) {
+	if allowInline && inlinable(b) {
+		return json.RawMessage(b), "", nil
+	}
+	if utf8.Valid(b) {
+		raw, err := json.Marshal(string(b))
+		return raw, "", err
+	}
+	raw, err := json.Marshal(base64.StdEncoding.EncodeToString(b))
+	return raw, "base64", err
+}
+
+// inlinable reports whether b is a JSON object or array that json.Marshal
+// writes unchanged: compact and without characters it escapes
+func inlinable(b []byte) bool {
+	if len(b) == 0 || (b[0] != '{' && b[0] != '[') {
+		return false
+	}
+	if bytes.ContainsAny(b, "<>&\u2028\u2029") {
+		return false
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, b); err != nil {
+		return false
+	}
+	return bytes.Equal(compact.Bytes(), b)
+}
+
+// decodeBytes converts a JSON value back into raw bytes
+func decodeBytes(raw json.RawMessage, encoding string) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	if raw[0] != '"' {
+		// Objects, arrays, numbers and booleans are used as written
+		return []byte(raw), nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, err
+	}
+	return decodeString(s, encoding)
+}
+
+// decodeString converts a stored string back into raw bytes
+func decodeString(s, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(s), nil
+	case "base64":
+		return base64.StdEncoding.DecodeString(s)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// decodeTime accepts RFC 3339 strings and unix milliseconds
+func decodeTime(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, nil
+	}
+
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return time.Time{}, err
+		}
+		if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.UnixMilli(ms), nil
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	}
+
+	ms, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms), nil
+}
+
+// decodeHeaders accepts an object, a list of {key, value} objects, or the
+// flat [key, value, key, value] array produced by kcat
+func decodeHeaders(raw json.RawMessage) ([]Header, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var byName map[string]string
+	if err := json.Unmarshal(raw, &byName); err == nil {
+		keys := make([]string, 0, len(byName))
+		for key := range byName {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		headers := make([]Header, len(keys))
+		for i, key := range keys {
+			headers[i] = Header{Key: key, Value: []byte(byName[key])}
+		}
+		return headers, nil
+	}
+
+	var list []jsonHeader
+	if err := json.Unmarshal(raw, &list); err == nil {
+		headers := make([]Header, len(list))
+		for i, h := range list {
+			value, err := decodeString(h.Value, h.Encoding)
+			if err != nil {
+				return nil, fmt.Errorf("header %s: %w", h.Key, err)
+			}
+			headers[i] = Header{Key: h.Key, Value: value}
+		}
+		return headers, nil
+	}
+
+	var flat []string
+	if err := json.Unmarshal(raw, &flat); err != nil {
+		return nil, err
+	}
+	if len(flat)%2 != 0 {
+		return nil, fmt.Errorf("flat header list must have an even number of entries")
+	}
+	headers := make([]Header, 0, len(flat)/2)
+	for i := 0; i < len(flat); i += 2 {
+		headers = append(headers, Header{Key: flat[i], Value: []byte(flat[i+1])})
+	}
+	return headers, nil
+}
@@ -0,0 +1,174 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 11, 20, 10, 30, 0, 123000000, time.UTC)
+	tests := []struct {
+		name string
+		rec  Record
+	}{
+		{
+			name: "json value",
+			rec: Record{
+				Topic:     "orders",
+				Key:       []byte("order-1"),
+				Value:     []byte(`{"id":"order-1","amount":10}`),
+				Headers:   []Header{{Key: "source", Value: []byte("capture")}},
+				Timestamp: ts,
+			},
+		},
+		{
+			name: "text value",
+			rec:  Record{Topic: "logs", Value: []byte(`"quoted" line`)},
+		},
+		{
+			name: "binary value",
+			rec:  Record{Topic: "raw", Key: []byte{0xff, 0x00}, Value: []byte{0xde, 0xad, 0xbe, 0xef}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.rec)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+
+			var got Record
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal() error = %v\nData: %s", err, data)
+			}
+
+			if got.Topic != tt.rec.Topic {
+				t.Errorf("Expected topic %s, got %s", tt.rec.Topic, got.Topic)
+			}
+			if !bytes.Equal(got.Key, tt.rec.Key) {
+				t.Errorf("Expected key %q, got %q", tt.rec.Key, got.Key)
+			}
+			if !bytes.Equal(got.Value, tt.rec.Value) {
+				t.Errorf("Expected value %q, got %q", tt.rec.Value, got.Value)
+			}
+			if !got.Timestamp.Equal(tt.rec.Timestamp) {
+				t.Errorf("Expected timestamp %v, got %v", tt.rec.Timestamp, got.Timestamp)
+			}
+			if len(got.Headers) != len(tt.rec.Headers) {
+				t.Errorf("Expected %d headers, got %d", len(tt.rec.Headers), len(got.Headers))
+			}
+		})
+	}
+}
+
+func TestReaderFormats(t *testing.T) {
+	input := `{"topic":"orders","key":"k1","value":{"id":1},"headers":{"a":"1"},"timestamp":"2024-11-20T10:30:00Z"}
+
+{"topic":"orders","partition":2,"offset":42,"tstype":"create","ts":1732098600000,"broker":1,"headers":["trace","abc","env","prod"],"key":null,"payload":"plain text"}
+{"topic":"orders","value":"x","headers":[{"key":"h","value":"v"}]}
+`
+	r := NewReader(strings.NewReader(input))
+
+	first, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if string(first.Value) != `{"id":1}` || string(first.Key) != "k1" {
+		t.Errorf("Unexpected first record: key=%q value=%q", first.Key, first.Value)
+	}
+
+	kcat, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if string(kcat.Value) != "plain text" {
+		t.Errorf("Expected kcat payload, got %q", kcat.Value)
+	}
+	if kcat.Key != nil {
+		t.Errorf("Expected null key, got %q", kcat.Key)
+	}
+	if kcat.Partition != 2 || kcat.Offset != 42 {
+		t.Errorf("Expected partition 2 offset 42, got %d/%d", kcat.Partition, kcat.Offset)
+	}
+	if kcat.Timestamp.UnixMilli() != 1732098600000 {
+		t.Errorf("Expected kcat timestamp, got %v", kcat.Timestamp)
+	}
+	if len(kcat.Headers) != 2 || kcat.Headers[1].Key != "env" || string(kcat.Headers[1].Value) != "prod" {
+		t.Errorf("Unexpected kcat headers: %+v", kcat.Headers)
+	}
+
+	third, err := r.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if len(third.Headers) != 1 || third.Headers[0].Key != "h" {
+		t.Errorf("Unexpected header list: %+v", third.Headers)
+	}
+
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestReaderInvalidLine(t *testing.T) {
+	r := NewReader(strings.NewReader("{\"value\":\"ok\"}\nnot json\n"))
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := r.Next()
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error mentioning line 2, got %v", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	records := []Record{
+		{Topic: "orders", Key: []byte("order-1"), Value: []byte(`{"id":"order-1"}`), Error: "leader not available"},
+		{Topic: "orders", Value: []byte{0xff}},
+		{
+			Topic: "orders",
+			Value: []byte("{\n  \"note\": \"<b>fish & chips</b>\"\n}"),
+			Headers: []Header{
+				{Key: "trace", Value: []byte("b")},
+				{Key: "trace", Value: []byte("a")},
+				{Key: "sig", Value: []byte{0x00, 0xfe, 0xff}},
+				{Key: "empty", Value: []byte{}},
+			},
+		},
+	}
+	for i := range records {
+		if err := w.Write(&records[i]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	r := NewReader(&buf)
+	for _, want := range records {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if got.Topic != want.Topic || !bytes.Equal(got.Value, want.Value) || got.Error != want.Error {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+		if len(got.Headers) != len(want.Headers) {
+			t.Fatalf("Expected headers %+v, got %+v", want.Headers, got.Headers)
+		}
+		for i, h := range want.Headers {
+			if got.Headers[i].Key != h.Key || !bytes.Equal(got.Headers[i].Value, h.Value) {
+				t.Errorf("Expected header %d to be %s=%q, got %s=%q", i, h.Key, h.Value, got.Headers[i].Key, got.Headers[i].Value)
+			}
+		}
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
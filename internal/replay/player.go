@@ -0,0 +1,268 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/record"
+	"github.com/alexermolov/go-kafka-pusher/internal/template"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// Sender publishes batches of messages to a topic
+type Sender interface {
+	SendMessages(ctx context.Context, topic string, messages []kafka.Message) error
+}
+
+// Stats holds replay statistics
+type Stats struct {
+	Records uint64
+	Batches uint64
+	Passes  uint64
+}
+
+// Player publishes recorded messages from a JSON-lines file
+type Player struct {
+	cfg       *config.ReplayConfig
+	topic     string
+	batchSize int
+	sender    Sender
+	generator *template.Generator
+	logger    *slog.Logger
+	stats     Stats
+}
+
+// NewPlayer creates a replay player. Records are published to topic, or to
+// their recorded topic when topic is empty. The generator resolves the
+// directives of the `set` overrides and may be nil when none are configured.
+func NewPlayer(cfg *config.ReplayConfig, topic string, batchSize int, sender Sender, generator *template.Generator, logger *slog.Logger) (*Player, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("replay config is required")
+	}
+	if sender == nil {
+		return nil, fmt.Errorf("sender is required")
+	}
+	if len(cfg.Set) > 0 && generator == nil {
+		return nil, fmt.Errorf("generator is required for set overrides")
+	}
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	return &Player{
+		cfg:       cfg,
+		topic:     topic,
+		batchSize: batchSize,
+		sender:    sender,
+		generator: generator,
+		logger:    logger,
+	}, nil
+}
+
+// Run replays the file until it is exhausted (or forever with loop enabled)
+// or the context is cancelled
+func (p *Player) Run(ctx context.Context) error {
+	for {
+		if err := p.play(ctx); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		p.stats.Passes++
+		p.logger.Info("replay pass completed",
+			slog.String("path", p.cfg.Path),
+			slog.Uint64("pass", p.stats.Passes),
+			slog.Uint64("records", p.stats.Records),
+		)
+
+		if !p.cfg.Loop {
+			return nil
+		}
+	}
+}
+
+// Stats returns replay statistics. It must not be called while Run is active.
+func (p *Player) Stats() Stats {
+	return p.stats
+}
+
+// play performs a single pass over the file
+func (p *Player) play(ctx context.Context) error {
+	f, err := os.Open(p.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open replay file: %w", err)
+	}
+	defer f.Close()
+
+	reader := record.NewReader(f)
+	start := time.Now()
+	var first time.Time
+	var index int
+	var batch []*record.Record
+
+	for {
+		rec, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read replay file: %w", err)
+		}
+		if first.IsZero() {
+			// Timing is relative to the first record that has a timestamp
+			first = rec.Timestamp
+		}
+
+		due := p.dueTime(start, first, rec.Timestamp, index)
+		index++
+
+		// Flush what is pending before waiting for a record that is not yet due
+		if wait := time.Until(due); wait > 0 {
+			if err := p.flush(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			case <-timer.C:
+			}
+		}
+
+		if p.cfg.ShiftTimestamps && !rec.Timestamp.IsZero() && !first.IsZero() {
+			rec.Timestamp = start.Add(rec.Timestamp.Sub(first))
+		}
+		if err := p.applyOverrides(rec); err != nil {
+			return fmt.Errorf("record %d: %w", index, err)
+		}
+
+		batch = append(batch, rec)
+		if len(batch) >= p.batchSize {
+			if err := p.flush(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+
+	return p.flush(ctx, batch)
+}
+
+// dueTime returns when a record should be published according to the mode
+func (p *Player) dueTime(start, first, ts time.Time, index int) time.Time {
+	switch p.cfg.Mode {
+	case "rate":
+		return start.Add(time.Duration(float64(index) / p.cfg.Rate * float64(time.Second)))
+	case "original":
+		if ts.IsZero() || first.IsZero() {
+			return start
+		}
+		return start.Add(time.Duration(float64(ts.Sub(first)) / p.cfg.Speed))
+	default:
+		return start
+	}
+}
+
+// flush sends pending records grouped by topic, preserving order per topic
+func (p *Player) flush(ctx context.Context, batch []*record.Record) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var topics []string
+	byTopic := make(map[string][]kafka.Message)
+	for _, rec := range batch {
+		topic := p.topic
+		if topic == "" {
+			topic = rec.Topic
+		}
+		if topic == "" {
+			return fmt.Errorf("record has no topic and payload topic is not set")
+		}
+
+		msg := kafka.Message{
+			Key:   rec.Key,
+			Value: rec.Value,
+			Time:  rec.Timestamp,
+		}
+		for _, h := range rec.Headers {
+			msg.Headers = append(msg.Headers, kafkago.Header{Key: h.Key, Value: h.Value})
+		}
+
+		if _, ok := byTopic[topic]; !ok {
+			topics = append(topics, topic)
+		}
+		byTopic[topic] = append(byTopic[topic], msg)
+	}
+
+	for _, topic := range topics {
+		if err := p.sender.SendMessages(ctx, topic, byTopic[topic]); err != nil {
+			return fmt.Errorf("failed to send replayed batch to %s: %w", topic, err)
+		}
+		p.stats.Batches++
+		p.stats.Records += uint64(len(byTopic[topic]))
+	}
+
+	return nil
+}
+
+// applyOverrides replaces fields of a JSON object value with freshly
+// resolved directives, e.g. new IDs or current timestamps
+func (p *Player) applyOverrides(rec *record.Record) error {
+	if len(p.cfg.Set) == 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(rec.Value))
+	dec.UseNumber()
+	var value map[string]interface{}
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("set overrides require a JSON object value: %w", err)
+	}
+
+	resolved, err := p.generator.Resolve(p.cfg.Set)
+	if err != nil {
+		return fmt.Errorf("failed to resolve overrides: %w", err)
+	}
+	for path, v := range resolved {
+		setPath(value, strings.Split(path, "."), v)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	rec.Value = data
+	return nil
+}
+
+// setPath sets a nested field, creating intermediate objects as needed
+func setPath(obj map[string]interface{}, path []string, value interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			obj[key] = next
+		}
+		obj = next
+	}
+	obj[path[len(path)-1]] = value
+}
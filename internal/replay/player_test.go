@@ -0,0 +1,200 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/template"
+)
+
+type sentBatch struct {
+	topic    string
+	messages []kafka.Message
+	at       time.Time
+}
+
+type fakeSender struct {
+	mu      sync.Mutex
+	batches []sentBatch
+}
+
+func (f *fakeSender) SendMessages(_ context.Context, topic string, messages []kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, sentBatch{topic: topic, messages: messages, at: time.Now()})
+	return nil
+}
+
+func writeReplayFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestPlayerAsapBatchesByTopic(t *testing.T) {
+	path := writeReplayFile(t, `{"topic":"a","key":"1","value":{"n":1}}
+{"topic":"b","key":"2","value":{"n":2}}
+{"topic":"a","key":"3","value":{"n":3},"headers":{"h":"v"}}
+`)
+	sender := &fakeSender{}
+	player, err := NewPlayer(&config.ReplayConfig{Path: path, Mode: "asap", Speed: 1}, "", 10, sender, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := player.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(sender.batches) != 2 {
+		t.Fatalf("Expected 2 batches (one per topic), got %d", len(sender.batches))
+	}
+	a := sender.batches[0]
+	if a.topic != "a" || len(a.messages) != 2 || string(a.messages[1].Key) != "3" {
+		t.Errorf("Unexpected batch for topic a: %+v", a)
+	}
+	if len(a.messages[1].Headers) != 1 || a.messages[1].Headers[0].Key != "h" {
+		t.Errorf("Expected headers to be replayed, got %+v", a.messages[1].Headers)
+	}
+
+	stats := player.Stats()
+	if stats.Records != 3 || stats.Passes != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+}
+
+func TestPlayerOriginalTiming(t *testing.T) {
+	path := writeReplayFile(t, `{"value":"1","timestamp":"2024-11-20T10:00:00Z"}
+{"value":"2","timestamp":"2024-11-20T10:00:00.200Z"}
+`)
+	sender := &fakeSender{}
+	cfg := &config.ReplayConfig{Path: path, Mode: "original", Speed: 2, ShiftTimestamps: true}
+	player, err := NewPlayer(cfg, "events", 10, sender, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := player.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.batches) != 2 {
+		t.Fatalf("Expected 2 batches split by the inter-arrival gap, got %d", len(sender.batches))
+	}
+	// 200ms recorded gap replayed at 2x speed
+	if gap := sender.batches[1].at.Sub(start); gap < 90*time.Millisecond {
+		t.Errorf("Expected second record after ~100ms, got %v", gap)
+	}
+	for _, b := range sender.batches {
+		if b.topic != "events" {
+			t.Errorf("Expected payload topic to override record topic, got %s", b.topic)
+		}
+	}
+	if ts := sender.batches[0].messages[0].Time; ts.Before(start) {
+		t.Errorf("Expected shifted timestamp after replay start, got %v", ts)
+	}
+}
+
+func TestPlayerOriginalTimingSkipsMissingTimestamps(t *testing.T) {
+	path := writeReplayFile(t, `{"value":"0"}
+{"value":"1","timestamp":"2024-11-20T10:00:00Z"}
+{"value":"2","timestamp":"2024-11-20T10:00:00.200Z"}
+`)
+	sender := &fakeSender{}
+	cfg := &config.ReplayConfig{Path: path, Mode: "original", Speed: 2}
+	player, err := NewPlayer(cfg, "events", 10, sender, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := player.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sender.batches) != 2 || len(sender.batches[0].messages) != 2 {
+		t.Fatalf("Expected the first two records sent at once and the third paced, got %d batches", len(sender.batches))
+	}
+	if gap := sender.batches[1].at.Sub(start); gap < 90*time.Millisecond {
+		t.Errorf("Expected third record after ~100ms, got %v", gap)
+	}
+}
+
+func TestPlayerSetOverrides(t *testing.T) {
+	path := writeReplayFile(t, `{"topic":"orders","value":{"id":"old","customer":{"name":"x"},"amount":12.5}}
+`)
+	gen, err := template.New(&template.Template{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sender := &fakeSender{}
+	cfg := &config.ReplayConfig{
+		Path: path,
+		Mode: "asap",
+		Set: map[string]string{
+			"id":          "{{@uuid}}",
+			"customer.id": "{{@rnd|6}}",
+		},
+	}
+	player, err := NewPlayer(cfg, "", 1, sender, gen, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := player.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var value map[string]interface{}
+	if err := json.Unmarshal(sender.batches[0].messages[0].Value, &value); err != nil {
+		t.Fatal(err)
+	}
+	if value["id"] == "old" {
+		t.Error("Expected id to be replaced")
+	}
+	customer := value["customer"].(map[string]interface{})
+	if customer["name"] != "x" || len(customer["id"].(string)) != 6 {
+		t.Errorf("Unexpected customer after overrides: %v", customer)
+	}
+	if value["amount"] != 12.5 {
+		t.Errorf("Expected untouched fields to be kept, got %v", value["amount"])
+	}
+}
+
+func TestPlayerStopsOnCancel(t *testing.T) {
+	path := writeReplayFile(t, `{"topic":"a","value":"1"}
+{"topic":"a","value":"2"}
+`)
+	sender := &fakeSender{}
+	cfg := &config.ReplayConfig{Path: path, Mode: "rate", Rate: 0.1, Loop: true}
+	player, err := NewPlayer(cfg, "", 1, sender, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := player.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := player.Stats(); stats.Records != 1 {
+		t.Errorf("Expected only the first record before cancellation, got %d", stats.Records)
+	}
+}
@@ -0,0 +1,37 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+)
+
+// Discard drops messages and only counts them, for measuring generator
+// throughput
+type Discard struct {
+	counter
+}
+
+// Send counts a single message
+func (d *Discard) Send(ctx context.Context, topic string, message []byte) error {
+	d.record(valuesOf([][]byte{message}), nil)
+	return nil
+}
+
+// SendBatch counts message values
+func (d *Discard) SendBatch(ctx context.Context, topic string, messages [][]byte) error {
+	return d.SendMessages(ctx, topic, valuesOf(messages))
+}
+
+// SendMessages counts messages
+func (d *Discard) SendMessages(ctx context.Context, topic string, messages []kafka.Message) error {
+	if len(messages) > 0 {
+		d.record(messages, nil)
+	}
+	return nil
+}
+
+// Close does nothing
+func (d *Discard) Close() error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+)
+
+// Fanout sends every batch to all of its sinks, for payloads mirrored to
+// several clusters. It does not own the sinks: Close does nothing and Stats
+// sums their statistics.
+type Fanout []Sink
+
+// Send sends a single message to every sink
+func (f Fanout) Send(ctx context.Context, topic string, message []byte) error {
+	return f.SendMessages(ctx, topic, valuesOf([][]byte{message}))
+}
+
+// SendBatch sends message values to every sink
+func (f Fanout) SendBatch(ctx context.Context, topic string, messages [][]byte) error {
+	return f.SendMessages(ctx, topic, valuesOf(messages))
+}
+
+// SendMessages sends messages to every sink, also after one of them failed
+func (f Fanout) SendMessages(ctx context.Context, topic string, messages []kafka.Message) error {
+	var errs []error
+	for _, s := range f {
+		if err := s.SendMessages(ctx, topic, messages); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close does nothing, the sinks are closed by their owner
+func (f Fanout) Close() error {
+	return nil
+}
+
+// Stats returns the sum of the sinks' statistics
+func (f Fanout) Stats() Stats {
+	var total Stats
+	for _, s := range f {
+		stats := s.Stats()
+		total.Messages += stats.Messages
+		total.Batches += stats.Batches
+		total.Bytes += stats.Bytes
+		total.Errors += stats.Errors
+	}
+	return total
+}
@@ -0,0 +1,222 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/record"
+)
+
+// Records writes messages as JSON lines in the replay record format
+type Records struct {
+	counter
+
+	mu     sync.Mutex
+	file   io.Closer // nil for stdout
+	buf    *bufio.Writer
+	writer *record.Writer
+}
+
+// NewStdout creates a sink printing records to standard output
+func NewStdout() *Records {
+	return newRecords(os.Stdout, nil)
+}
+
+// NewFile creates a sink appending records to a JSON-lines file
+func NewFile(path string) (*Records, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sink file: %w", err)
+	}
+	return newRecords(file, file), nil
+}
+
+// newRecords creates a record sink writing to w
+func newRecords(w io.Writer, file io.Closer) *Records {
+	buf := bufio.NewWriter(w)
+	return &Records{file: file, buf: buf, writer: record.NewWriter(buf)}
+}
+
+// Send writes a single message
+func (r *Records) Send(ctx context.Context, topic string, message []byte) error {
+	return r.SendMessages(ctx, topic, valuesOf([][]byte{message}))
+}
+
+// SendBatch writes message values
+func (r *Records) SendBatch(ctx context.Context, topic string, messages [][]byte) error {
+	return r.SendMessages(ctx, topic, valuesOf(messages))
+}
+
+// SendMessages writes messages, flushing after every batch
+func (r *Records) SendMessages(ctx context.Context, topic string, messages []kafka.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.write(topic, messages)
+	r.record(messages, err)
+	return err
+}
+
+// write writes and flushes a batch
+func (r *Records) write(topic string, messages []kafka.Message) error {
+	for _, msg := range messages {
+		if err := r.writer.Write(toRecord(topic, msg)); err != nil {
+			return err
+		}
+	}
+	if err := r.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to flush records: %w", err)
+	}
+	return nil
+}
+
+// Close flushes buffered records and closes the file
+func (r *Records) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to flush records: %w", err)
+	}
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// toRecord converts a message to a record, stamped with the current time
+// unless it carries its own
+func toRecord(topic string, msg kafka.Message) *record.Record {
+	rec := &record.Record{
+		Topic:     topic,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Timestamp: msg.Time,
+	}
+	if rec.Timestamp.IsZero() {
+		rec.Timestamp = time.Now()
+	}
+	for _, h := range msg.Headers {
+		rec.Headers = append(rec.Headers, record.Header{Key: h.Key, Value: h.Value})
+	}
+	return rec
+}
+
+// Files writes the value of every message to its own file, named
+// <dir>/<topic>/<sequence>.<extension>. Numbering continues after the files
+// already in the directory, existing files are never overwritten.
+type Files struct {
+	counter
+	dir       string
+	extension string
+
+	mu        sync.Mutex
+	sequences map[string]uint64
+}
+
+// NewFiles creates a sink writing one file per message below dir
+func NewFiles(dir, extension string) *Files {
+	return &Files{dir: dir, extension: extension, sequences: make(map[string]uint64)}
+}
+
+// Send writes a single message
+func (f *Files) Send(ctx context.Context, topic string, message []byte) error {
+	return f.SendMessages(ctx, topic, valuesOf([][]byte{message}))
+}
+
+// SendBatch writes message values
+func (f *Files) SendBatch(ctx context.Context, topic string, messages [][]byte) error {
+	return f.SendMessages(ctx, topic, valuesOf(messages))
+}
+
+// SendMessages writes every message value to a new file. Keys and headers
+// are not kept.
+func (f *Files) SendMessages(ctx context.Context, topic string, messages []kafka.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err := f.write(topic, messages)
+	f.record(messages, err)
+	return err
+}
+
+// write writes the files of a batch
+func (f *Files) write(topic string, messages []kafka.Message) error {
+	dir := filepath.Join(f.dir, topic)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create sink directory: %w", err)
+	}
+
+	if _, ok := f.sequences[topic]; !ok {
+		last, err := lastSequence(dir, f.extension)
+		if err != nil {
+			return fmt.Errorf("failed to read sink directory: %w", err)
+		}
+		f.sequences[topic] = last
+	}
+
+	for _, msg := range messages {
+		f.sequences[topic]++
+		name := filepath.Join(dir, fmt.Sprintf("%06d.%s", f.sequences[topic], f.extension))
+		if err := writeNewFile(name, msg.Value); err != nil {
+			return fmt.Errorf("failed to write message file: %w", err)
+		}
+	}
+	return nil
+}
+
+// lastSequence returns the highest sequence number of the message files in
+// dir, or 0 when there are none
+func lastSequence(dir, extension string) (uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var last uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), "."+extension)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if n, err := strconv.ParseUint(name, 10, 64); err == nil && n > last {
+			last = n
+		}
+	}
+	return last, nil
+}
+
+// writeNewFile writes data to a file that must not exist yet
+func writeNewFile(name string, data []byte) error {
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Close does nothing, every file is complete once written
+func (f *Files) Close() error {
+	return nil
+}
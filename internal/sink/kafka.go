@@ -0,0 +1,57 @@
+package sink
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+)
+
+// Kafka sends messages to the Kafka cluster
+type Kafka struct {
+	counter
+	producer *kafka.Producer
+}
+
+// NewKafka creates a Kafka sink
+func NewKafka(cfg *config.KafkaConfig, logger *slog.Logger) (*Kafka, error) {
+	producer, err := kafka.NewProducer(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &Kafka{producer: producer}, nil
+}
+
+// Producer returns the underlying producer for Kafka-only features such as
+// transactions and delivery statistics
+func (k *Kafka) Producer() *kafka.Producer {
+	return k.producer
+}
+
+// Send sends a single message
+func (k *Kafka) Send(ctx context.Context, topic string, message []byte) error {
+	err := k.producer.Send(ctx, topic, message)
+	k.record(valuesOf([][]byte{message}), err)
+	return err
+}
+
+// SendBatch sends message values in a batch
+func (k *Kafka) SendBatch(ctx context.Context, topic string, messages [][]byte) error {
+	return k.SendMessages(ctx, topic, valuesOf(messages))
+}
+
+// SendMessages sends keyed messages in a batch
+func (k *Kafka) SendMessages(ctx context.Context, topic string, messages []kafka.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	err := k.producer.SendMessages(ctx, topic, messages)
+	k.record(messages, err)
+	return err
+}
+
+// Close flushes and closes the producer
+func (k *Kafka) Close() error {
+	return k.producer.Close()
+}
@@ -0,0 +1,92 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+)
+
+// Sink receives generated messages
+type Sink interface {
+	Send(ctx context.Context, topic string, message []byte) error
+	SendBatch(ctx context.Context, topic string, messages [][]byte) error
+	SendMessages(ctx context.Context, topic string, messages []kafka.Message) error
+	Close() error
+	Stats() Stats
+}
+
+// Stats holds the messages a sink has accepted
+type Stats struct {
+	Messages uint64
+	Batches  uint64
+	Bytes    uint64 // key and value bytes
+	Errors   uint64 // failed batches
+}
+
+// New creates the sink selected by cfg, the Kafka producer when cfg is nil
+func New(cfg *config.SinkConfig, kafkaCfg *config.KafkaConfig, logger *slog.Logger) (Sink, error) {
+	if cfg.UsesKafka() {
+		return NewKafka(kafkaCfg, logger)
+	}
+
+	switch cfg.Type {
+	case "stdout":
+		return NewStdout(), nil
+	case "file":
+		if cfg.PerMessage {
+			return NewFiles(cfg.Path, cfg.Extension), nil
+		}
+		return NewFile(cfg.Path)
+	case "discard":
+		return &Discard{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sink type %q", cfg.Type)
+	}
+}
+
+// counter accumulates sink statistics. This type is thread-safe
+type counter struct {
+	messages atomic.Uint64
+	batches  atomic.Uint64
+	bytes    atomic.Uint64
+	errors   atomic.Uint64
+}
+
+// record counts a batch
+func (c *counter) record(messages []kafka.Message, err error) {
+	if err != nil {
+		c.errors.Add(1)
+		return
+	}
+
+	var size int
+	for _, msg := range messages {
+		size += len(msg.Key) + len(msg.Value)
+	}
+	c.messages.Add(uint64(len(messages)))
+	c.batches.Add(1)
+	c.bytes.Add(uint64(size))
+}
+
+// Stats returns the counted messages
+func (c *counter) Stats() Stats {
+	return Stats{
+		Messages: c.messages.Load(),
+		Batches:  c.batches.Load(),
+		Bytes:    c.bytes.Load(),
+		Errors:   c.errors.Load(),
+	}
+}
+
+// valuesOf wraps message values without keys or headers
+func valuesOf(values [][]byte) []kafka.Message {
+	messages := make([]kafka.Message, len(values))
+	for i, value := range values {
+		messages[i] = kafka.Message{Value: value}
+	}
+	return messages
+}
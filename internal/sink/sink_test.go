@@ -0,0 +1,115 @@
+package sink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/alexermolov/go-kafka-pusher/internal/record"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.jsonl")
+	s, err := New(&config.SinkConfig{Type: "file", Path: path}, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := context.Background()
+	messages := []kafka.Message{
+		{Key: []byte("order-1"), Value: []byte(`{"id":1}`), Headers: []kafkago.Header{{Key: "x-type", Value: []byte("created")}}},
+		{Key: []byte("order-2"), Value: []byte(`{"id":2}`)},
+	}
+	if err := s.SendMessages(ctx, "orders", messages); err != nil {
+		t.Fatalf("SendMessages() error = %v", err)
+	}
+	if err := s.Send(ctx, "events", []byte("plain")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	stats := s.Stats()
+	if stats.Messages != 3 || stats.Batches != 2 || stats.Errors != 0 {
+		t.Errorf("Stats() = %+v, want 3 messages in 2 batches", stats)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := record.NewReader(file)
+
+	first, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if first.Topic != "orders" || string(first.Key) != "order-1" || string(first.Value) != `{"id":1}` {
+		t.Errorf("first record = %+v", first)
+	}
+	if len(first.Headers) != 1 || first.Headers[0].Key != "x-type" || first.Timestamp.IsZero() {
+		t.Errorf("first record headers = %v, timestamp = %v", first.Headers, first.Timestamp)
+	}
+
+	reader.Next()
+	last, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if last.Topic != "events" || string(last.Value) != "plain" {
+		t.Errorf("last record = %+v", last)
+	}
+}
+
+func TestFilesSink(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(&config.SinkConfig{Type: "file", Path: dir, PerMessage: true, Extension: "json"}, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := s.SendBatch(context.Background(), "orders", [][]byte{[]byte(`{"id":1}`), []byte(`{"id":2}`)}); err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "orders", "000002.json"))
+	if err != nil {
+		t.Fatalf("second message file: %v", err)
+	}
+	if string(data) != `{"id":2}` {
+		t.Errorf("second message file = %s", data)
+	}
+
+	// A later run continues after the existing files
+	s, err = New(&config.SinkConfig{Type: "file", Path: dir, PerMessage: true, Extension: "json"}, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := s.SendBatch(context.Background(), "orders", [][]byte{[]byte(`{"id":3}`)}); err != nil {
+		t.Fatalf("SendBatch() error = %v", err)
+	}
+	for name, want := range map[string]string{"000002.json": `{"id":2}`, "000003.json": `{"id":3}`} {
+		data, err := os.ReadFile(filepath.Join(dir, "orders", name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %s, %v, want %s", name, data, err, want)
+		}
+	}
+}
+
+func TestDiscardSink(t *testing.T) {
+	s, err := New(&config.SinkConfig{Type: "discard"}, nil, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	s.SendMessages(context.Background(), "orders", []kafka.Message{{Key: []byte("k"), Value: []byte("value")}})
+	if stats := s.Stats(); stats.Messages != 1 || stats.Bytes != 6 {
+		t.Errorf("Stats() = %+v, want 1 message of 6 bytes", stats)
+	}
+}
@@ -0,0 +1,176 @@
+package template
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// DatasetSpec describes a lookup dataset loaded from a CSV or JSON-lines file
+type DatasetSpec struct {
+	Path   string `yaml:"path" json:"path"`
+	Format string `yaml:"format" json:"format"` // csv or jsonl, detected from extension if empty
+	Mode   string `yaml:"mode" json:"mode"`     // random (default) or sequential
+}
+
+// dataset holds the rows of a loaded lookup file
+type dataset struct {
+	name       string
+	rows       []map[string]interface{}
+	columns    map[string]bool
+	sequential bool
+	next       atomic.Uint64
+}
+
+// loadDataset reads a dataset file; relative paths are resolved against baseDir
+func loadDataset(name string, spec DatasetSpec, baseDir string) (*dataset, error) {
+	if spec.Path == "" {
+		return nil, fmt.Errorf("dataset %s: path is required", name)
+	}
+
+	path := spec.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("dataset %s: failed to read file: %w", name, err)
+	}
+
+	format := strings.ToLower(spec.Format)
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".jsonl", ".ndjson", ".json":
+			format = "jsonl"
+		default:
+			format = "csv"
+		}
+	}
+
+	var rows []map[string]interface{}
+	switch format {
+	case "csv":
+		rows, err = parseCSVRows(data)
+	case "jsonl", "json":
+		rows, err = parseJSONLines(data)
+	default:
+		return nil, fmt.Errorf("dataset %s: unsupported format %q", name, spec.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dataset %s: %w", name, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("dataset %s: file %s has no rows", name, path)
+	}
+
+	ds := &dataset{
+		name:    name,
+		rows:    rows,
+		columns: make(map[string]bool),
+	}
+	for _, row := range rows {
+		for column := range row {
+			ds.columns[column] = true
+		}
+	}
+
+	switch strings.ToLower(spec.Mode) {
+	case "", "random":
+	case "sequential":
+		ds.sequential = true
+	default:
+		return nil, fmt.Errorf("dataset %s: unsupported mode %q", name, spec.Mode)
+	}
+
+	return ds, nil
+}
+
+// hasColumn reports whether any row of the dataset has the given column
+func (d *dataset) hasColumn(column string) bool {
+	return d.columns[column]
+}
+
+// pick returns the next row, either at random or in file order wrapping around.
+// Rows are shared and must not be modified by callers.
+func (d *dataset) pick(src *Source) (map[string]interface{}, error) {
+	if d.sequential {
+		i := (d.next.Add(1) - 1) % uint64(len(d.rows))
+		return d.rows[i], nil
+	}
+
+	i, err := randomInt(src, len(d.rows))
+	if err != nil {
+		return nil, err
+	}
+	return d.rows[i], nil
+}
+
+// parseCSVRows parses CSV data using the first record as the header
+func parseCSVRows(data []byte) ([]map[string]interface{}, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	var rows []map[string]interface{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV: %w", err)
+		}
+
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			row[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseJSONLines parses one JSON object per line, skipping blank lines
+func parseJSONLines(data []byte) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.UseNumber()
+		var row map[string]interface{}
+		if err := dec.Decode(&row); err != nil {
+			return nil, fmt.Errorf("failed to parse line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read lines: %w", err)
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,162 @@
+package template
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDatasetCSVRandom(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "countries.csv", `country,currency
+DE,EUR
+US,USD
+GB,GBP
+JP,JPY
+`)
+	path := writeFile(t, dir, "payment.yaml", `
+datasets:
+  countries:
+    path: countries.csv
+
+substitution:
+  country: "{{@row|countries.country}}"
+  currency: "{{@row|countries.currency}}"
+
+template:
+  country: "{{.country}}"
+  currency: "{{.currency}}"
+`)
+
+	gen, err := NewGenerator(path)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+
+	currencies := map[string]string{"DE": "EUR", "US": "USD", "GB": "GBP", "JP": "JPY"}
+	for i := 0; i < 50; i++ {
+		msg, err := gen.Generate()
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+
+		var result map[string]string
+		if err := json.Unmarshal(msg, &result); err != nil {
+			t.Fatalf("Generated message is not valid JSON: %v", err)
+		}
+		if currencies[result["country"]] != result["currency"] {
+			t.Errorf("Expected columns from the same row, got %s/%s", result["country"], result["currency"])
+		}
+	}
+}
+
+func TestDatasetJSONLinesSequential(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "skus.jsonl", `{"sku": "A-1", "price": 10}
+{"sku": "B-2", "price": 20}
+
+{"sku": "C-3", "price": 30}
+`)
+	path := writeFile(t, dir, "order.yaml", `
+datasets:
+  skus:
+    path: skus.jsonl
+    mode: sequential
+
+substitution:
+  sku: "{{@row|skus.sku}}"
+  price: "{{@row|skus.price}}"
+
+template:
+  sku: "{{.sku}}"
+  price: "{{.price}}"
+`)
+
+	gen, err := NewGenerator(path)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+
+	expected := []string{"A-1:10", "B-2:20", "C-3:30", "A-1:10"}
+	for i, want := range expected {
+		msg, err := gen.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result map[string]string
+		if err := json.Unmarshal(msg, &result); err != nil {
+			t.Fatal(err)
+		}
+		if got := result["sku"] + ":" + result["price"]; got != want {
+			t.Errorf("Message %d: expected %s, got %s", i, want, got)
+		}
+	}
+}
+
+func TestDatasetErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "skus.csv", "sku,price\nA-1,10\n")
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "unknown column",
+			content: `
+datasets:
+  skus: {path: skus.csv}
+substitution:
+  sku: "{{@row|skus.name}}"
+template:
+  sku: "{{.sku}}"
+`,
+		},
+		{
+			name: "unknown dataset",
+			content: `
+substitution:
+  sku: "{{@row|skus.sku}}"
+template:
+  sku: "{{.sku}}"
+`,
+		},
+		{
+			name: "missing file",
+			content: `
+datasets:
+  skus: {path: missing.csv}
+template:
+  sku: "x"
+`,
+		},
+		{
+			name: "unsupported mode",
+			content: `
+datasets:
+  skus: {path: skus.csv, mode: shuffled}
+template:
+  sku: "x"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, dir, "template.yaml", tt.content)
+			if _, err := NewGenerator(path); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
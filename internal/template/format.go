@@ -0,0 +1,320 @@
+package template
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	tmpl "text/template"
+	"unicode/utf8"
+)
+
+// Output formats of a template
+const (
+	FormatJSON  = "json"
+	FormatText  = "text"
+	FormatCSV   = "csv"
+	FormatXML   = "xml"
+	FormatBytes = "bytes"
+)
+
+// CSVSpec configures the CSV renderer
+type CSVSpec struct {
+	Columns   []string `yaml:"columns" json:"columns"`     // column order, defaults to sorted template keys
+	Delimiter string   `yaml:"delimiter" json:"delimiter"` // single character, defaults to ","
+}
+
+// XMLSpec configures the XML renderer
+type XMLSpec struct {
+	Root string `yaml:"root" json:"root"` // root element name, defaults to "message"
+}
+
+var xmlNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// bodyFuncs are available in raw template bodies
+var bodyFuncs = tmpl.FuncMap{
+	"padLeft":  padLeft,
+	"padRight": padRight,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+}
+
+// compileFormat validates the output format settings of a template and
+// parses its raw body
+func (g *Generator) compileFormat() error {
+	t := g.template
+	switch t.Format {
+	case "", FormatJSON:
+		return nil
+	case FormatText, FormatBytes:
+		if t.Body == "" {
+			return fmt.Errorf("template format %s requires a body", t.Format)
+		}
+		if t.Format == FormatBytes && t.Encoding != "" && t.Encoding != "base64" && t.Encoding != "hex" {
+			return fmt.Errorf("template encoding must be base64 or hex")
+		}
+		body, err := tmpl.New("body").Funcs(bodyFuncs).Parse(t.Body)
+		if err != nil {
+			return fmt.Errorf("failed to parse template body: %w", err)
+		}
+		g.body = body
+		return nil
+	case FormatCSV:
+		if t.CSV != nil && t.CSV.Delimiter != "" {
+			if r, size := utf8.DecodeRuneInString(t.CSV.Delimiter); size != len(t.CSV.Delimiter) || r == '"' || r == '\n' || r == '\r' {
+				return fmt.Errorf("csv delimiter must be a single character other than quote or newline")
+			}
+		}
+		if t.CSV != nil {
+			for _, column := range t.CSV.Columns {
+				if _, ok := t.Template[column]; !ok {
+					return fmt.Errorf("csv column %q is not defined in the template", column)
+				}
+			}
+		}
+		return nil
+	case FormatXML:
+		if t.XML != nil && t.XML.Root != "" && !xmlNamePattern.MatchString(t.XML.Root) {
+			return fmt.Errorf("invalid xml root element %q", t.XML.Root)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported template format %q", t.Format)
+	}
+}
+
+// render produces the message in the template's output format
+func (g *Generator) render(substitutions map[string]interface{}) ([]byte, error) {
+	switch g.template.Format {
+	case FormatText:
+		return g.renderBody(substitutions)
+	case FormatBytes:
+		text, err := g.renderBody(substitutions)
+		if err != nil {
+			return nil, err
+		}
+		return decodeBody(bytes.TrimSpace(text), g.template.Encoding)
+	case FormatCSV:
+		return g.renderCSV(substitutions)
+	case FormatXML:
+		return g.renderXML(substitutions)
+	default:
+		templateJSON, err := json.Marshal(g.template.Template)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal template: %w", err)
+		}
+		return g.applySubstitutions(templateJSON, substitutions)
+	}
+}
+
+// renderBody executes the raw template body
+func (g *Generator) renderBody(substitutions map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := g.body.Execute(&buf, substitutions); err != nil {
+		return nil, fmt.Errorf("failed to execute template body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderCSV writes the template values as a single CSV row
+func (g *Generator) renderCSV(substitutions map[string]interface{}) ([]byte, error) {
+	var columns []string
+	delimiter := ','
+	if spec := g.template.CSV; spec != nil {
+		columns = spec.Columns
+		if spec.Delimiter != "" {
+			delimiter, _ = utf8.DecodeRuneInString(spec.Delimiter)
+		}
+	}
+	if len(columns) == 0 {
+		columns = sortedKeys(g.template.Template)
+	}
+
+	row := make([]string, len(columns))
+	for i, column := range columns {
+		value, err := renderLeaf(g.template.Template[column], substitutions)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		row[i] = value
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = delimiter
+	if err := w.Write(row); err != nil {
+		return nil, fmt.Errorf("failed to write csv row: %w", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write csv row: %w", err)
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// renderXML writes the template as an XML document. Keys starting with "-"
+// become attributes of their element and "#text" sets its character data;
+// lists repeat the element once per item.
+func (g *Generator) renderXML(substitutions map[string]interface{}) ([]byte, error) {
+	root := "message"
+	if g.template.XML != nil && g.template.XML.Root != "" {
+		root = g.template.XML.Root
+	}
+
+	var buf bytes.Buffer
+	if err := writeXMLElement(&buf, root, g.template.Template, substitutions); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeXMLElement writes a single element and its children
+func writeXMLElement(buf *bytes.Buffer, name string, value interface{}, substitutions map[string]interface{}) error {
+	if !xmlNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid xml element name %q", name)
+	}
+
+	if items, ok := value.([]interface{}); ok {
+		for _, item := range items {
+			if err := writeXMLElement(buf, name, item, substitutions); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	children, ok := value.(map[string]interface{})
+	if !ok {
+		text, err := renderLeaf(value, substitutions)
+		if err != nil {
+			return fmt.Errorf("element %s: %w", name, err)
+		}
+		buf.WriteString("<" + name + ">")
+		if err := xml.EscapeText(buf, []byte(text)); err != nil {
+			return err
+		}
+		buf.WriteString("</" + name + ">")
+		return nil
+	}
+
+	buf.WriteString("<" + name)
+	var elements []string
+	for _, key := range sortedKeys(children) {
+		attr, isAttr := strings.CutPrefix(key, "-")
+		if !isAttr {
+			elements = append(elements, key)
+			continue
+		}
+		if !xmlNamePattern.MatchString(attr) {
+			return fmt.Errorf("invalid xml attribute name %q", attr)
+		}
+		text, err := renderLeaf(children[key], substitutions)
+		if err != nil {
+			return fmt.Errorf("attribute %s: %w", attr, err)
+		}
+		buf.WriteString(" " + attr + `="`)
+		if err := xml.EscapeText(buf, []byte(text)); err != nil {
+			return err
+		}
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+
+	for _, key := range elements {
+		if key == "#text" {
+			text, err := renderLeaf(children[key], substitutions)
+			if err != nil {
+				return fmt.Errorf("element %s: %w", name, err)
+			}
+			if err := xml.EscapeText(buf, []byte(text)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeXMLElement(buf, key, children[key], substitutions); err != nil {
+			return err
+		}
+	}
+
+	buf.WriteString("</" + name + ">")
+	return nil
+}
+
+// renderLeaf executes a template string, or formats any other scalar value
+func renderLeaf(value interface{}, substitutions map[string]interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		t, err := tmpl.New("value").Funcs(bodyFuncs).Parse(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse template: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, substitutions); err != nil {
+			return "", fmt.Errorf("failed to execute template: %w", err)
+		}
+		return buf.String(), nil
+	case map[string]interface{}, []interface{}:
+		return "", fmt.Errorf("nested values are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// decodeBody converts rendered text into raw bytes
+func decodeBody(text []byte, encoding string) ([]byte, error) {
+	if encoding == "hex" {
+		// Allow whitespace between hex groups for readability
+		compact := strings.Join(strings.Fields(string(text)), "")
+		data, err := hex.DecodeString(compact)
+		if err != nil {
+			return nil, fmt.Errorf("rendered body is not valid hex: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("rendered body is not valid base64: %w", err)
+	}
+	return data, nil
+}
+
+// sortedKeys returns the keys of a map in lexical order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// padLeft right-aligns v in a field of width characters, truncating longer values
+func padLeft(width int, v interface{}) string {
+	s := fmt.Sprint(v)
+	if n := utf8.RuneCountInString(s); n < width {
+		return strings.Repeat(" ", width-n) + s
+	}
+	return string([]rune(s)[:width])
+}
+
+// padRight left-aligns v in a field of width characters, truncating longer values
+func padRight(width int, v interface{}) string {
+	s := fmt.Sprint(v)
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return string([]rune(s)[:width])
+}
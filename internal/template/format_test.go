@@ -0,0 +1,140 @@
+package template
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGenerateText(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "syslog.yaml", `
+format: text
+substitution:
+  host: "web-01"
+  level: "warn"
+  code: "{{@rnd|3}}"
+body: "<134>1 {{.host}} app - {{upper .level}} [{{padRight 6 .code}}] request failed"
+`)
+
+	gen, err := NewGenerator(path)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+	if gen.Format() != FormatText {
+		t.Errorf("Expected format text, got %s", gen.Format())
+	}
+
+	msg, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	want := "<134>1 web-01 app - WARN ["
+	if !bytes.HasPrefix(msg, []byte(want)) || !bytes.HasSuffix(msg, []byte("   ] request failed")) {
+		t.Errorf("Unexpected text message %q", msg)
+	}
+}
+
+func TestGenerateCSV(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "rows.yaml", `
+format: csv
+csv:
+  columns: [id, name, amount]
+  delimiter: ";"
+substitution:
+  name: "Smith; John"
+template:
+  id: 42
+  name: "{{.name}}"
+  amount: "10.50"
+`)
+
+	gen, err := NewGenerator(path)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+	msg, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if want := `42;"Smith; John";10.50`; string(msg) != want {
+		t.Errorf("Expected %q, got %q", want, msg)
+	}
+}
+
+func TestGenerateXML(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "order.yaml", `
+format: xml
+xml:
+  root: order
+substitution:
+  customer: "Tom & Jerry"
+template:
+  -id: "o-1"
+  customer: "{{.customer}}"
+  items:
+    item:
+      - sku: "a"
+      - sku: "b"
+  total:
+    -currency: "EUR"
+    "#text": 12.5
+`)
+
+	gen, err := NewGenerator(path)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+	msg, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	want := `<order id="o-1"><customer>Tom &amp; Jerry</customer><items><item><sku>a</sku></item><item><sku>b</sku></item></items><total currency="EUR">12.5</total></order>`
+	if string(msg) != want {
+		t.Errorf("Expected %s, got %s", want, msg)
+	}
+}
+
+func TestGenerateBytes(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "raw.yaml", `
+format: bytes
+encoding: hex
+substitution:
+  type: "0a"
+body: "ca fe {{.type}} 00"
+`)
+
+	gen, err := NewGenerator(path)
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+	msg, err := gen.Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if want := []byte{0xca, 0xfe, 0x0a, 0x00}; !bytes.Equal(msg, want) {
+		t.Errorf("Expected % x, got % x", want, msg)
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unknown format", "format: yaml\ntemplate:\n  a: 1\n"},
+		{"text without body", "format: text\n"},
+		{"invalid body", "format: text\nbody: \"{{.a\"\n"},
+		{"bytes encoding", "format: bytes\nencoding: base32\nbody: \"AA==\"\n"},
+		{"csv unknown column", "format: csv\ncsv:\n  columns: [b]\ntemplate:\n  a: 1\n"},
+		{"csv delimiter", "format: csv\ncsv:\n  delimiter: \";;\"\ntemplate:\n  a: 1\n"},
+		{"xml root", "format: xml\nxml:\n  root: \"1st\"\ntemplate:\n  a: 1\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "template.yaml", tt.content)
+			if _, err := NewGenerator(path); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
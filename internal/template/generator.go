@@ -10,12 +10,14 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	tmpl "text/template"
 	"time"
 
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
 	"github.com/google/uuid"
 	"gopkg.in/yaml.v3"
 )
@@ -24,7 +26,15 @@ import (
 type Template struct {
 	Substitution map[string]interface{} `yaml:"substitution" json:"substitution"`
 	Template     map[string]interface{} `yaml:"template" json:"template"`
-	
+	Datasets     map[string]DatasetSpec `yaml:"datasets" json:"datasets"`
+
+	// Format selects the output format: json (default), text, csv, xml or bytes
+	Format   string   `yaml:"format" json:"format"`
+	Body     string   `yaml:"body" json:"body"`         // raw text/template body for text and bytes
+	Encoding string   `yaml:"encoding" json:"encoding"` // body encoding for bytes: base64 (default) or hex
+	CSV      *CSVSpec `yaml:"csv" json:"csv"`
+	XML      *XMLSpec `yaml:"xml" json:"xml"`
+
 	compiledTemplate *tmpl.Template
 	mu               sync.RWMutex
 }
@@ -32,12 +42,49 @@ type Template struct {
 // Generator is a thread-safe template generator
 type Generator struct {
 	template *Template
+	pools    map[string]*Pool
+	datasets map[string]*dataset
+	body     *tmpl.Template
+	source   *Source
 	mu       sync.RWMutex
 }
 
+// Option configures optional generator dependencies
+type Option func(*Generator)
+
+// WithPools makes the given entity pools available to @ref directives
+func WithPools(pools map[string]*Pool) Option {
+	return func(g *Generator) {
+		g.pools = pools
+	}
+}
+
+// WithSource draws generated values from src instead of crypto/rand, e.g. a
+// seeded source for reproducible messages
+func WithSource(src *Source) Option {
+	return func(g *Generator) {
+		g.source = src
+	}
+}
+
+// messageContext holds state shared by all substitutions of a single message
+type messageContext struct {
+	// members holds the pool member picked for each pool referenced by the message
+	members map[string]map[string]interface{}
+	// rows holds the dataset row picked for each dataset referenced by the message
+	rows map[string]map[string]interface{}
+}
+
+var (
+	refPattern = regexp.MustCompile(`{{\s*@ref\|([A-Za-z0-9_-]+)\.([A-Za-z0-9_.-]+)\s*}}`)
+	rowPattern = regexp.MustCompile(`{{\s*@row\|([A-Za-z0-9_-]+)\.([^}\s]+)\s*}}`)
+	// directivePattern matches any directive embedded in free text
+	directivePattern = regexp.MustCompile(`{{\s*@[^}]*}}`)
+)
+
 // NewGenerator creates a new template generator from a file
 // Supports both YAML and JSON formats based on file extension
-func NewGenerator(path string) (*Generator, error) {
+func NewGenerator(path string, opts ...Option) (*Generator, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read template file: %w", err)
@@ -45,33 +92,166 @@ func NewGenerator(path string) (*Generator, error) {
 
 	var t Template
 	ext := strings.ToLower(filepath.Ext(path))
-	
+
 	switch ext {
 	case ".json":
-		if err := json.Unmarshal(data, &t); err != nil {
+		if err := decodeJSON(data, &t); err != nil {
 			return nil, fmt.Errorf("failed to parse JSON template: %w", err)
 		}
 	case ".yaml", ".yml":
-		if err := yaml.Unmarshal(data, &t); err != nil {
+		if err := decodeYAML(data, &t); err != nil {
 			return nil, fmt.Errorf("failed to parse YAML template: %w", err)
 		}
 	default:
 		// Try YAML first, then JSON
-		if err := yaml.Unmarshal(data, &t); err != nil {
-			if jsonErr := json.Unmarshal(data, &t); jsonErr != nil {
+		if err := decodeYAML(data, &t); err != nil {
+			if jsonErr := decodeJSON(data, &t); jsonErr != nil {
 				return nil, fmt.Errorf("failed to parse template as YAML or JSON: YAML error: %w, JSON error: %v", err, jsonErr)
 			}
 		}
 	}
 
-	return &Generator{
-		template: &t,
-	}, nil
+	return newGenerator(&t, filepath.Dir(path), opts)
+}
+
+// decodeYAML parses a YAML template, expanding environment variables in its values
+func decodeYAML(data []byte, t *Template) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if err := config.ExpandEnvNode(&doc); err != nil {
+		return err
+	}
+	return doc.Decode(t)
+}
+
+// decodeJSON parses a JSON template, expanding environment variables in its
+// string values. Values are expanded after parsing, so quotes and
+// backslashes in a variable cannot break the document.
+func decodeJSON(data []byte, t *Template) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	doc, err := expandJSON(doc)
+	if err != nil {
+		return err
+	}
+	expanded, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(expanded, t)
+}
+
+// expandJSON expands environment variables in every string value of a
+// decoded JSON document. Object keys are left as they are.
+func expandJSON(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return config.ExpandEnv(v)
+	case map[string]interface{}:
+		for key, value := range v {
+			expanded, err := expandJSON(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			v[key] = expanded
+		}
+	case []interface{}:
+		for i, value := range v {
+			expanded, err := expandJSON(value)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			v[i] = expanded
+		}
+	}
+	return v, nil
+}
+
+// New creates a generator from an in-memory template.
+// Relative dataset paths are resolved against the working directory.
+func New(t *Template, opts ...Option) (*Generator, error) {
+	if t == nil {
+		return nil, fmt.Errorf("template is required")
+	}
+	return newGenerator(t, ".", opts)
+}
+
+// newGenerator applies options, loads datasets and validates references
+func newGenerator(t *Template, baseDir string, opts []Option) (*Generator, error) {
+	g := &Generator{
+		template: t,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	if len(t.Datasets) > 0 {
+		g.datasets = make(map[string]*dataset, len(t.Datasets))
+		for name, spec := range t.Datasets {
+			ds, err := loadDataset(name, spec, baseDir)
+			if err != nil {
+				return nil, err
+			}
+			g.datasets[name] = ds
+		}
+	}
+
+	if err := g.validateRefs(); err != nil {
+		return nil, err
+	}
+	if err := g.compileFormat(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// validateRefs checks that every @ref and @row directive points to a known
+// pool field or dataset column
+func (g *Generator) validateRefs() error {
+	for key, value := range g.template.Substitution {
+		strValue, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if matches := refPattern.FindStringSubmatch(strValue); matches != nil {
+			pool, ok := g.pools[matches[1]]
+			if !ok {
+				return fmt.Errorf("substitution %s references unknown pool %q", key, matches[1])
+			}
+			if !pool.HasField(matches[2]) {
+				return fmt.Errorf("substitution %s references unknown field %q of pool %q", key, matches[2], matches[1])
+			}
+		}
+		if matches := rowPattern.FindStringSubmatch(strValue); matches != nil {
+			ds, ok := g.datasets[matches[1]]
+			if !ok {
+				return fmt.Errorf("substitution %s references unknown dataset %q", key, matches[1])
+			}
+			if !ds.hasColumn(matches[2]) {
+				return fmt.Errorf("substitution %s references unknown column %q of dataset %q", key, matches[2], matches[1])
+			}
+		}
+	}
+	return nil
 }
 
 // Generate creates a new message from the template
 // This method is thread-safe
 func (g *Generator) Generate() ([]byte, error) {
+	return g.GenerateWith(nil)
+}
+
+// GenerateWith creates a new message from the template with additional
+// variables that take precedence over generated substitutions.
+// This method is thread-safe
+func (g *Generator) GenerateWith(vars map[string]interface{}) ([]byte, error) {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
@@ -80,15 +260,12 @@ func (g *Generator) Generate() ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to build substitutions: %w", err)
 	}
-
-	// Convert template to JSON
-	templateJSON, err := json.Marshal(g.template.Template)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal template: %w", err)
+	for key, value := range vars {
+		substitutions[key] = value
 	}
 
-	// Apply substitutions
-	result, err := g.applySubstitutions(templateJSON, substitutions)
+	// Render the template in its output format
+	result, err := g.render(substitutions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to apply substitutions: %w", err)
 	}
@@ -96,11 +273,73 @@ func (g *Generator) Generate() ([]byte, error) {
 	return result, nil
 }
 
+// Format returns the output format of generated messages
+func (g *Generator) Format() string {
+	if g.template.Format == "" {
+		return FormatJSON
+	}
+	return g.template.Format
+}
+
+// Resolve evaluates a set of directive expressions using the generator's
+// pools. References to the same pool resolve to the same member.
+func (g *Generator) Resolve(values map[string]string) (map[string]interface{}, error) {
+	exprs := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		exprs[key] = value
+	}
+	return g.resolve(exprs)
+}
+
+// Expand replaces every directive embedded in the texts with its resolved
+// value, e.g. `order {{@uuid}}`. References to the same pool resolve to the
+// same member across all texts. Unknown directives are left as they are.
+func (g *Generator) Expand(texts []string) ([]string, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	mc := &messageContext{}
+	result := make([]string, len(texts))
+	for i, text := range texts {
+		var expandErr error
+		result[i] = directivePattern.ReplaceAllStringFunc(text, func(directive string) string {
+			if expandErr != nil {
+				return directive
+			}
+			value, err := g.processValue(directive, mc)
+			if err != nil {
+				expandErr = fmt.Errorf("failed to expand %s: %w", directive, err)
+				return directive
+			}
+			return fmt.Sprint(value)
+		})
+		if expandErr != nil {
+			return nil, expandErr
+		}
+	}
+	return result, nil
+}
+
 // buildSubstitutions generates all substitution values
 func (g *Generator) buildSubstitutions() (map[string]interface{}, error) {
+	return g.resolve(g.template.Substitution)
+}
+
+// resolve processes template functions of every string value in the map
+func (g *Generator) resolve(values map[string]interface{}) (map[string]interface{}, error) {
 	result := make(map[string]interface{})
+	mc := &messageContext{}
 
-	for key, value := range g.template.Substitution {
+	// Keys are resolved in order, so that a seeded source gives every key the
+	// same values on every run
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := values[key]
 		strValue, ok := value.(string)
 		if !ok {
 			result[key] = value
@@ -108,7 +347,7 @@ func (g *Generator) buildSubstitutions() (map[string]interface{}, error) {
 		}
 
 		// Process template functions
-		processedValue, err := g.processValue(strValue)
+		processedValue, err := g.processValue(strValue, mc)
 		if err != nil {
 			return nil, fmt.Errorf("failed to process key %s: %w", key, err)
 		}
@@ -119,15 +358,19 @@ func (g *Generator) buildSubstitutions() (map[string]interface{}, error) {
 }
 
 // processValue processes a single substitution value with template functions
-func (g *Generator) processValue(value string) (interface{}, error) {
+func (g *Generator) processValue(value string, mc *messageContext) (interface{}, error) {
 	// GUID generator
 	if matched, _ := regexp.MatchString(`{{\s*@guid\s*}}`, value); matched {
-		return generateGUID()
+		return generateGUID(g.source)
 	}
 
 	// UUID generator
 	if matched, _ := regexp.MatchString(`{{\s*@uuid\s*}}`, value); matched {
-		return uuid.New().String(), nil
+		id, err := uuid.NewRandomFromReader(g.source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate UUID: %w", err)
+		}
+		return id.String(), nil
 	}
 
 	// Now/timestamp generator
@@ -147,13 +390,77 @@ func (g *Generator) processValue(value string) (interface{}, error) {
 		if len(matches) > 1 && matches[1] != "" {
 			digits, _ = strconv.Atoi(matches[1])
 		}
-		return generateRandomNumber(digits)
+		return generateRandomNumber(digits, g.source)
+	}
+
+	// Pool reference, one member per pool is shared by the whole message
+	if matches := refPattern.FindStringSubmatch(value); matches != nil {
+		return g.resolveRef(matches[1], matches[2], mc)
+	}
+
+	// Dataset row, one row per dataset is shared by the whole message
+	if matches := rowPattern.FindStringSubmatch(value); matches != nil {
+		return g.resolveRow(matches[1], matches[2], mc)
 	}
 
 	// If no special pattern, return as is
 	return value, nil
 }
 
+// resolveRef returns a field of the pool member picked for the current message
+func (g *Generator) resolveRef(poolName, field string, mc *messageContext) (interface{}, error) {
+	pool, ok := g.pools[poolName]
+	if !ok {
+		return nil, fmt.Errorf("unknown pool %q", poolName)
+	}
+
+	member, ok := mc.members[poolName]
+	if !ok {
+		var err error
+		member, err = pool.pick(g.source)
+		if err != nil {
+			return nil, err
+		}
+		if mc.members == nil {
+			mc.members = make(map[string]map[string]interface{})
+		}
+		mc.members[poolName] = member
+	}
+
+	value, ok := member[field]
+	if !ok {
+		return nil, fmt.Errorf("pool %q has no field %q", poolName, field)
+	}
+	return value, nil
+}
+
+// resolveRow returns a column of the dataset row picked for the current message
+func (g *Generator) resolveRow(name, column string, mc *messageContext) (interface{}, error) {
+	ds, ok := g.datasets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown dataset %q", name)
+	}
+
+	row, ok := mc.rows[name]
+	if !ok {
+		var err error
+		row, err = ds.pick(g.source)
+		if err != nil {
+			return nil, err
+		}
+		if mc.rows == nil {
+			mc.rows = make(map[string]map[string]interface{})
+		}
+		mc.rows[name] = row
+	}
+
+	value, ok := row[column]
+	if !ok {
+		return nil, fmt.Errorf("dataset %q has no column %q", name, column)
+	}
+	return value, nil
+}
+
 // applySubstitutions applies the substitution map to the template
 func (g *Generator) applySubstitutions(templateJSON []byte, substitutions map[string]interface{}) ([]byte, error) {
 	t, err := tmpl.New("message").Parse(string(templateJSON))
@@ -169,10 +476,10 @@ func (g *Generator) applySubstitutions(templateJSON []byte, substitutions map[st
 	return buf.Bytes(), nil
 }
 
-// generateGUID generates a cryptographically secure GUID
-func generateGUID() (string, error) {
+// generateGUID generates a random GUID
+func generateGUID(src *Source) (string, error) {
 	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
+	if _, err := src.Read(b); err != nil {
 		return "", fmt.Errorf("failed to generate random bytes: %w", err)
 	}
 	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
@@ -180,8 +487,7 @@ func generateGUID() (string, error) {
 }
 
 // generateRandomNumber generates a random number with specified digits
-// Uses crypto/rand for thread-safety and security
-func generateRandomNumber(digits int) (string, error) {
+func generateRandomNumber(digits int, src *Source) (string, error) {
 	if digits <= 0 {
 		return "0", nil
 	}
@@ -193,7 +499,7 @@ func generateRandomNumber(digits int) (string, error) {
 	max := new(big.Int)
 	max.Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
 
-	n, err := rand.Int(rand.Reader, max)
+	n, err := rand.Int(src, max)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate random number: %w", err)
 	}
@@ -206,7 +512,7 @@ func generateRandomNumber(digits int) (string, error) {
 // formatTime formats time according to the specified format
 func formatTime(t time.Time, format string) (string, error) {
 	format = strings.ToUpper(format)
-	
+
 	switch format {
 	case "RFC822":
 		return t.Format(time.RFC822), nil
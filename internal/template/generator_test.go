@@ -5,6 +5,8 @@ import (
 	"os"
 	"regexp"
 	"testing"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
 )
 
 func TestNewGenerator(t *testing.T) {
@@ -232,7 +234,7 @@ func TestGenerateRandomNumber(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run("digits", func(t *testing.T) {
-			result, err := generateRandomNumber(tt.digits)
+			result, err := generateRandomNumber(tt.digits, nil)
 			if err != nil {
 				t.Fatalf("generateRandomNumber() error = %v", err)
 			}
@@ -252,3 +254,90 @@ func isValidUUID(s string) bool {
 	match, _ := regexp.MatchString(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`, s)
 	return match
 }
+
+func TestNewGeneratorExpandsEnv(t *testing.T) {
+	t.Setenv("PUSHER_REGION", "eu-west")
+	// Quotes and backslashes must not break out of the value
+	note := `say "hi" \ bye", "admin": "true`
+	t.Setenv("PUSHER_NOTE", note)
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"template.yaml": "template:\n  region: ${PUSHER_REGION}\n  tier: ${PUSHER_TIER:-free}\n  note: ${PUSHER_NOTE}\n",
+		"template.json": `{"template": {"region": "${PUSHER_REGION}", "tier": "${PUSHER_TIER:-free}", "note": "${PUSHER_NOTE}"}}`,
+	}
+	for name, content := range files {
+		path := dir + "/" + name
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gen, err := NewGenerator(path)
+		if err != nil {
+			t.Fatalf("%s: failed to create generator: %v", name, err)
+		}
+		message, err := gen.Generate()
+		if err != nil {
+			t.Fatalf("%s: failed to generate: %v", name, err)
+		}
+
+		var result map[string]string
+		if err := json.Unmarshal(message, &result); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", name, err)
+		}
+		if result["region"] != "eu-west" || result["tier"] != "free" || result["note"] != note || len(result) != 3 {
+			t.Errorf("%s: unexpected message %s", name, message)
+		}
+	}
+}
+
+func TestGenerateSeeded(t *testing.T) {
+	cfg := &config.PoolConfig{Size: 20, Fields: map[string]string{"id": "{{@uuid}}"}}
+	tmpl := func() *Template {
+		return &Template{
+			Substitution: map[string]interface{}{
+				"id":       "{{@uuid}}",
+				"guid":     "{{@guid}}",
+				"amount":   "{{@rnd|8}}",
+				"customer": "{{@ref|customers.id}}",
+			},
+			Template: map[string]interface{}{
+				"id":       "{{.id}}",
+				"guid":     "{{.guid}}",
+				"amount":   "{{.amount}}",
+				"customer": "{{.customer}}",
+			},
+		}
+	}
+	generate := func(seed uint64) []string {
+		pool, err := NewPoolWithSource("customers", cfg, NewSource(seed, "pool:customers"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		gen, err := New(tmpl(), WithPools(map[string]*Pool{"customers": pool}), WithSource(NewSource(seed, "payload:orders")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var messages []string
+		for i := 0; i < 5; i++ {
+			message, err := gen.Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages = append(messages, string(message))
+		}
+		return messages
+	}
+
+	first, again, other := generate(42), generate(42), generate(43)
+	for i := range first {
+		if first[i] != again[i] {
+			t.Errorf("message %d differs with the same seed:\n%s\n%s", i, first[i], again[i])
+		}
+		if first[i] == other[i] {
+			t.Errorf("message %d is the same with another seed: %s", i, first[i])
+		}
+	}
+	if first[0] == first[1] {
+		t.Errorf("seeded messages repeat: %s", first[0])
+	}
+}
@@ -0,0 +1,116 @@
+package template
+
+import (
+	"fmt"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+)
+
+// Pool is a fixed set of entities created once and shared between generators,
+// so that payloads written to different topics reference the same IDs
+type Pool struct {
+	name       string
+	members    []map[string]interface{}
+	fields     map[string]string
+	hotCount   int
+	hotTraffic float64
+}
+
+// NewPool creates a pool and generates all of its members up front.
+// Member fields support the same directives as template substitutions.
+func NewPool(name string, cfg *config.PoolConfig) (*Pool, error) {
+	return NewPoolWithSource(name, cfg, nil)
+}
+
+// NewPoolWithSource creates a pool whose members are generated from src
+func NewPoolWithSource(name string, cfg *config.PoolConfig, src *Source) (*Pool, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("pool config is required")
+	}
+	if cfg.Size < 1 {
+		return nil, fmt.Errorf("pool %s: size must be at least 1", name)
+	}
+
+	substitution := make(map[string]interface{}, len(cfg.Fields))
+	for field, value := range cfg.Fields {
+		substitution[field] = value
+	}
+	gen := &Generator{template: &Template{Substitution: substitution}, source: src}
+
+	members := make([]map[string]interface{}, cfg.Size)
+	for i := range members {
+		member, err := gen.buildSubstitutions()
+		if err != nil {
+			return nil, fmt.Errorf("pool %s: failed to create member %d: %w", name, i, err)
+		}
+		members[i] = member
+	}
+
+	p := &Pool{
+		name:     name,
+		members:  members,
+		fields:   cfg.Fields,
+		hotCount: cfg.Size,
+	}
+	if cfg.Selection == "hotkey" {
+		p.hotCount = int(float64(cfg.Size) * cfg.HotKeys)
+		if p.hotCount < 1 {
+			p.hotCount = 1
+		}
+		p.hotTraffic = cfg.HotTraffic
+	}
+
+	return p, nil
+}
+
+// Name returns the pool name
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Size returns the number of members in the pool
+func (p *Pool) Size() int {
+	return len(p.members)
+}
+
+// HasField reports whether pool members carry the given field
+func (p *Pool) HasField(field string) bool {
+	_, ok := p.fields[field]
+	return ok
+}
+
+// Pick selects a member according to the pool's selection strategy.
+// With hot-key selection the first members of the pool form the hot set.
+// Members are shared and must not be modified by callers.
+func (p *Pool) Pick() (map[string]interface{}, error) {
+	return p.pick(nil)
+}
+
+// pick selects a member drawing from src
+func (p *Pool) pick(src *Source) (map[string]interface{}, error) {
+	if p.hotCount >= len(p.members) {
+		i, err := randomInt(src, len(p.members))
+		if err != nil {
+			return nil, err
+		}
+		return p.members[i], nil
+	}
+
+	f, err := randomFloat(src)
+	if err != nil {
+		return nil, err
+	}
+	if f < p.hotTraffic {
+		i, err := randomInt(src, p.hotCount)
+		if err != nil {
+			return nil, err
+		}
+		return p.members[i], nil
+	}
+
+	i, err := randomInt(src, len(p.members)-p.hotCount)
+	if err != nil {
+		return nil, err
+	}
+	return p.members[p.hotCount+i], nil
+}
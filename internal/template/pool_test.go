@@ -0,0 +1,207 @@
+package template
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+)
+
+func TestNewPool(t *testing.T) {
+	pool, err := NewPool("customers", &config.PoolConfig{
+		Size: 50,
+		Fields: map[string]string{
+			"id":   "{{@uuid}}",
+			"code": "{{@rnd|4}}",
+		},
+		Selection: "uniform",
+	})
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+
+	if pool.Size() != 50 {
+		t.Errorf("Expected 50 members, got %d", pool.Size())
+	}
+
+	ids := make(map[interface{}]bool)
+	for _, member := range pool.members {
+		if !isValidUUID(member["id"].(string)) {
+			t.Errorf("Expected valid UUID, got %v", member["id"])
+		}
+		if code := member["code"].(string); len(code) != 4 {
+			t.Errorf("Expected 4-digit code, got %s", code)
+		}
+		ids[member["id"]] = true
+	}
+	if len(ids) != 50 {
+		t.Errorf("Expected 50 unique ids, got %d", len(ids))
+	}
+}
+
+func TestPoolHotKeySelection(t *testing.T) {
+	pool, err := NewPool("accounts", &config.PoolConfig{
+		Size:       100,
+		Fields:     map[string]string{"id": "{{@uuid}}"},
+		Selection:  "hotkey",
+		HotKeys:    0.1,
+		HotTraffic: 0.9,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hot := make(map[interface{}]bool)
+	for _, member := range pool.members[:10] {
+		hot[member["id"]] = true
+	}
+
+	const picks = 2000
+	hotPicks := 0
+	for i := 0; i < picks; i++ {
+		member, err := pool.Pick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hot[member["id"]] {
+			hotPicks++
+		}
+	}
+
+	// 90% of traffic is expected on the hot set, allow generous slack
+	if ratio := float64(hotPicks) / picks; ratio < 0.8 || ratio > 0.97 {
+		t.Errorf("Expected about 90%% hot picks, got %.2f", ratio)
+	}
+}
+
+func TestGeneratorPoolReferences(t *testing.T) {
+	pool, err := NewPool("customers", &config.PoolConfig{
+		Size: 5,
+		Fields: map[string]string{
+			"id":   "{{@uuid}}",
+			"name": "{{@rnd|8}}",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pools := map[string]*Pool{"customers": pool}
+
+	content := `
+substitution:
+  customerId: "{{@ref|customers.id}}"
+  customerName: "{{@ref|customers.name}}"
+
+template:
+  customer:
+    id: "{{.customerId}}"
+    name: "{{.customerName}}"
+`
+	tmpfile, err := os.CreateTemp("", "template-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	gen, err := NewGenerator(tmpfile.Name(), WithPools(pools))
+	if err != nil {
+		t.Fatalf("NewGenerator() error = %v", err)
+	}
+
+	members := make(map[string]string)
+	for _, member := range pool.members {
+		members[member["id"].(string)] = member["name"].(string)
+	}
+
+	for i := 0; i < 20; i++ {
+		msg, err := gen.Generate()
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+
+		var result struct {
+			Customer struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"customer"`
+		}
+		if err := json.Unmarshal(msg, &result); err != nil {
+			t.Fatalf("Generated message is not valid JSON: %v", err)
+		}
+
+		name, ok := members[result.Customer.ID]
+		if !ok {
+			t.Fatalf("Customer id %s is not a pool member", result.Customer.ID)
+		}
+		if name != result.Customer.Name {
+			t.Errorf("Expected fields from the same member, got id %s with name %s", result.Customer.ID, result.Customer.Name)
+		}
+	}
+
+	// Unknown pools and fields are rejected when the generator is created
+	if _, err := NewGenerator(tmpfile.Name()); err == nil {
+		t.Error("Expected error for reference to unknown pool")
+	}
+	other, err := NewPool("customers", &config.PoolConfig{Size: 1, Fields: map[string]string{"id": "{{@uuid}}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewGenerator(tmpfile.Name(), WithPools(map[string]*Pool{"customers": other})); err == nil {
+		t.Error("Expected error for reference to unknown pool field")
+	}
+}
+
+func TestExpand(t *testing.T) {
+	pool, err := NewPool("customers", &config.PoolConfig{
+		Size:   20,
+		Fields: map[string]string{"id": "{{@uuid}}"},
+	})
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	gen, err := New(&Template{}, WithPools(map[string]*Pool{"customers": pool}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	texts, err := gen.Expand([]string{
+		"{{@ref|customers.id}}",
+		`{"customer":"{{ @ref|customers.id }}","order":"{{@uuid}}","code":"{{@rnd|3}}"}`,
+		"{{@unknown}} and {{.field}} are kept",
+	})
+	if err != nil {
+		t.Fatalf("Expand() error = %v", err)
+	}
+
+	var value struct {
+		Customer string `json:"customer"`
+		Order    string `json:"order"`
+		Code     string `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(texts[1]), &value); err != nil {
+		t.Fatalf("expanded text is not valid JSON: %v: %s", err, texts[1])
+	}
+	if value.Customer != texts[0] {
+		t.Errorf("Expected references to share a member, got %s and %s", texts[0], value.Customer)
+	}
+	if !isValidUUID(value.Order) {
+		t.Errorf("Expected valid UUID, got %s", value.Order)
+	}
+	if len(value.Code) != 3 {
+		t.Errorf("Expected 3-digit code, got %s", value.Code)
+	}
+	if texts[2] != "{{@unknown}} and {{.field}} are kept" {
+		t.Errorf("Expected unknown directives to be kept, got %s", texts[2])
+	}
+
+	if _, err := gen.Expand([]string{"{{@ref|missing.id}}"}); err == nil {
+		t.Error("Expected error for unknown pool")
+	}
+}
@@ -0,0 +1,65 @@
+package template
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	mrand "math/rand/v2"
+	"sync"
+)
+
+// Source supplies the randomness of generated values. A nil Source reads
+// crypto/rand; a seeded one makes generated values reproducible.
+type Source struct {
+	mu  sync.Mutex
+	rnd *mrand.Rand
+}
+
+// NewSource returns a source seeded with seed. Sources with the same seed
+// and different streams produce unrelated sequences, so that every pool and
+// payload draws from its own.
+func NewSource(seed uint64, stream string) *Source {
+	h := fnv.New64a()
+	h.Write([]byte(stream))
+	return &Source{rnd: mrand.New(mrand.NewPCG(seed, h.Sum64()))}
+}
+
+// Read fills b with random bytes.
+// This method is thread-safe
+func (s *Source) Read(b []byte) (int, error) {
+	if s == nil {
+		return rand.Read(b)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < len(b); i += 8 {
+		var word [8]byte
+		binary.LittleEndian.PutUint64(word[:], s.rnd.Uint64())
+		copy(b[i:], word[:])
+	}
+	return len(b), nil
+}
+
+// randomInt returns a uniformly distributed integer in [0, n)
+func randomInt(src *Source, n int) (int, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid random range %d", n)
+	}
+	v, err := rand.Int(src, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate random number: %w", err)
+	}
+	return int(v.Int64()), nil
+}
+
+// randomFloat returns a uniformly distributed float in [0, 1)
+func randomFloat(src *Source) (float64, error) {
+	var b [8]byte
+	if _, err := src.Read(b[:]); err != nil {
+		return 0, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return float64(binary.BigEndian.Uint64(b[:])>>11) / (1 << 53), nil
+}
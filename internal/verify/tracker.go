@@ -0,0 +1,188 @@
+package verify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/measure"
+)
+
+// Report summarises a verification run
+type Report struct {
+	Sent       uint64
+	Received   uint64
+	Lost       uint64 // sent but never received
+	Duplicated uint64 // received more than once, counting every extra copy
+	OutOfOrder uint64 // received before an earlier message with the same key
+	Latency    Latency
+}
+
+// Latency holds end-to-end latency percentiles of received messages
+type Latency struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// sentMessage is the bookkeeping for a tracked message
+type sentMessage struct {
+	id     string
+	stream string // topic and key, ordering is checked per stream
+	seq    uint64 // position within the stream
+	sentAt time.Time
+}
+
+// stream holds the ordering state of the messages sent with one key
+type stream struct {
+	next        uint64 // last assigned sequence
+	lastSeen    uint64 // highest received sequence
+	outstanding int    // messages neither received nor expired
+}
+
+// tracker matches received message IDs against sent ones. Messages are
+// forgotten once they are older than the timeout: unmatched ones count as
+// lost, and copies of matched ones are only counted as duplicates within
+// that window, so memory stays bounded during long runs.
+type tracker struct {
+	mu        sync.Mutex
+	prefix    string
+	timeout   time.Duration
+	next      uint64
+	sent      map[string]*sentMessage // awaiting their first copy
+	matched   map[string]bool         // received, kept to detect duplicates
+	queue     []*sentMessage          // every remembered message in send order
+	streams   map[string]*stream
+	received  uint64
+	dups      uint64
+	reordered uint64
+	latencies measure.Histogram
+}
+
+// newTracker creates a tracker whose IDs start with prefix. A timeout of
+// zero remembers every message until the end of the run.
+func newTracker(prefix string, timeout time.Duration) *tracker {
+	return &tracker{
+		prefix:  prefix,
+		timeout: timeout,
+		sent:    make(map[string]*sentMessage),
+		matched: make(map[string]bool),
+		streams: make(map[string]*stream),
+	}
+}
+
+// track registers a message about to be sent and returns its ID.
+// Ordering is only checked for keyed messages, as keyless messages are
+// spread across partitions.
+func (t *tracker) track(topic string, key []byte, at time.Time) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire(at)
+
+	t.next++
+	msg := &sentMessage{id: fmt.Sprintf("%s-%d", t.prefix, t.next), sentAt: at}
+	if len(key) > 0 {
+		msg.stream = topic + "\x00" + string(key)
+		s := t.streams[msg.stream]
+		if s == nil {
+			s = &stream{}
+			t.streams[msg.stream] = s
+		}
+		s.next++
+		s.outstanding++
+		msg.seq = s.next
+	}
+	t.sent[msg.id] = msg
+	t.queue = append(t.queue, msg)
+	return msg.id
+}
+
+// observe records a received message ID. IDs from other runs or producers,
+// and of messages that have expired, are ignored.
+func (t *tracker) observe(id string, at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.matched[id] {
+		t.dups++
+		return
+	}
+	msg, ok := t.sent[id]
+	if !ok {
+		return
+	}
+	delete(t.sent, id)
+	t.matched[id] = true
+	t.received++
+	t.latencies.Record(at.Sub(msg.sentAt))
+
+	if msg.stream != "" {
+		s := t.streams[msg.stream]
+		if msg.seq < s.lastSeen {
+			t.reordered++
+		} else {
+			s.lastSeen = msg.seq
+		}
+		t.settle(msg.stream, s)
+	}
+}
+
+// expire forgets the messages sent more than the timeout before now
+func (t *tracker) expire(now time.Time) {
+	if t.timeout <= 0 {
+		return
+	}
+	cutoff := now.Add(-t.timeout)
+	n := 0
+	for n < len(t.queue) && t.queue[n].sentAt.Before(cutoff) {
+		msg := t.queue[n]
+		if _, lost := t.sent[msg.id]; lost {
+			delete(t.sent, msg.id)
+			if msg.stream != "" {
+				t.settle(msg.stream, t.streams[msg.stream])
+			}
+		}
+		delete(t.matched, msg.id)
+		t.queue[n] = nil
+		n++
+	}
+	t.queue = t.queue[n:]
+}
+
+// settle counts a message of the stream as done and drops the stream once
+// nothing is outstanding, as there is nothing left to order against
+func (t *tracker) settle(name string, s *stream) {
+	s.outstanding--
+	if s.outstanding == 0 {
+		delete(t.streams, name)
+	}
+}
+
+// complete reports whether every sent message has been received or expired
+func (t *tracker) complete() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.sent) == 0
+}
+
+// report builds the verification report
+func (t *tracker) report() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return Report{
+		Sent:       t.next,
+		Received:   t.received,
+		Lost:       t.next - t.received,
+		Duplicated: t.dups,
+		OutOfOrder: t.reordered,
+		Latency: Latency{
+			P50: t.latencies.Percentile(0.50),
+			P95: t.latencies.Percentile(0.95),
+			P99: t.latencies.Percentile(0.99),
+			Max: t.latencies.Max(),
+		},
+	}
+}
@@ -0,0 +1,91 @@
+package verify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrackerReport(t *testing.T) {
+	tr := newTracker("run", 0)
+	start := time.Unix(1700000000, 0)
+
+	a1 := tr.track("orders", []byte("a"), start)
+	a2 := tr.track("orders", []byte("a"), start)
+	b1 := tr.track("orders", []byte("b"), start)
+	lost := tr.track("orders", nil, start)
+	if a1 == a2 || a1 == b1 || a1 == lost {
+		t.Fatalf("track() returned duplicate IDs: %s %s %s %s", a1, a2, b1, lost)
+	}
+
+	// a2 overtakes a1, b1 arrives twice, lost never arrives
+	tr.observe(a2, start.Add(10*time.Millisecond))
+	tr.observe(a1, start.Add(20*time.Millisecond))
+	tr.observe(b1, start.Add(30*time.Millisecond))
+	tr.observe(b1, start.Add(40*time.Millisecond))
+	tr.observe("other-1", start)
+
+	if tr.complete() {
+		t.Error("complete() = true with a lost message")
+	}
+
+	r := tr.report()
+	want := Report{Sent: 4, Received: 3, Lost: 1, Duplicated: 1, OutOfOrder: 1}
+	if r.Sent != want.Sent || r.Received != want.Received || r.Lost != want.Lost ||
+		r.Duplicated != want.Duplicated || r.OutOfOrder != want.OutOfOrder {
+		t.Errorf("report() = %+v, want %+v", r, want)
+	}
+	// The histogram keeps percentiles within 1%
+	if p50 := r.Latency.P50; p50 < 20*time.Millisecond || p50 > 20200*time.Microsecond || r.Latency.Max != 30*time.Millisecond {
+		t.Errorf("latency = %+v, want p50 about 20ms and max 30ms", r.Latency)
+	}
+}
+
+func TestTrackerKeylessOrder(t *testing.T) {
+	tr := newTracker("run", 0)
+	now := time.Now()
+
+	first := tr.track("orders", nil, now)
+	second := tr.track("orders", nil, now)
+	tr.observe(second, now)
+	tr.observe(first, now)
+
+	if !tr.complete() {
+		t.Error("complete() = false, want true")
+	}
+	if r := tr.report(); r.OutOfOrder != 0 {
+		t.Errorf("OutOfOrder = %d, want 0 for keyless messages", r.OutOfOrder)
+	}
+}
+
+func TestTrackerExpires(t *testing.T) {
+	tr := newTracker("run", time.Minute)
+	start := time.Unix(1700000000, 0)
+
+	received := tr.track("orders", []byte("a"), start)
+	lost := tr.track("orders", []byte("b"), start)
+	tr.observe(received, start.Add(time.Second))
+	tr.observe(received, start.Add(2*time.Second))
+
+	// Tracking a message two minutes later forgets both earlier ones
+	last := tr.track("orders", []byte("a"), start.Add(2*time.Minute))
+	if len(tr.sent) != 1 || len(tr.matched) != 0 || len(tr.queue) != 1 || len(tr.streams) != 1 {
+		t.Errorf("tracker holds %d sent, %d matched, %d queued and %d streams, want only the last message",
+			len(tr.sent), len(tr.matched), len(tr.queue), len(tr.streams))
+	}
+
+	// Late copies of forgotten messages are ignored
+	tr.observe(lost, start.Add(3*time.Minute))
+	tr.observe(received, start.Add(3*time.Minute))
+	tr.observe(last, start.Add(3*time.Minute))
+	if !tr.complete() {
+		t.Error("complete() = false, want true once the last message arrived")
+	}
+	if len(tr.streams) != 0 {
+		t.Errorf("tracker holds %d streams, want none with nothing outstanding", len(tr.streams))
+	}
+
+	r := tr.report()
+	if r.Sent != 3 || r.Received != 2 || r.Lost != 1 || r.Duplicated != 1 || r.OutOfOrder != 0 {
+		t.Errorf("report() = %+v, want 3 sent, 2 received, 1 lost and 1 duplicate", r)
+	}
+}
@@ -0,0 +1,106 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/alexermolov/go-kafka-pusher/internal/config"
+	"github.com/alexermolov/go-kafka-pusher/internal/kafka"
+	"github.com/google/uuid"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// pollInterval is how often Wait checks for outstanding messages
+const pollInterval = 100 * time.Millisecond
+
+// Verifier consumes the target topics and checks that every produced
+// message arrives, matching messages by an ID header
+type Verifier struct {
+	cfg      *config.VerifyConfig
+	logger   *slog.Logger
+	tracker  *tracker
+	consumer *kafka.Consumer
+}
+
+// NewVerifier creates a read-back verifier
+func NewVerifier(cfg *config.VerifyConfig, kafkaCfg *config.KafkaConfig, logger *slog.Logger) (*Verifier, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("verify config is required")
+	}
+
+	consumer, err := kafka.NewConsumer(kafkaCfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verification consumer: %w", err)
+	}
+
+	return &Verifier{
+		cfg:      cfg,
+		logger:   logger,
+		consumer: consumer,
+		// A per-run prefix keeps IDs from earlier runs from matching
+		tracker: newTracker(uuid.NewString()[:8], cfg.Timeout),
+	}, nil
+}
+
+// Start begins consuming the topics from their current end. It must be
+// called before producing.
+func (v *Verifier) Start(ctx context.Context, topics []string) error {
+	if err := v.consumer.Start(ctx, topics, v.observe); err != nil {
+		return fmt.Errorf("failed to start verification consumer: %w", err)
+	}
+	return nil
+}
+
+// Track tags a message with its verification ID header.
+// A nil verifier leaves the message unchanged.
+// This method is thread-safe
+func (v *Verifier) Track(topic string, msg *kafka.Message) {
+	if v == nil {
+		return
+	}
+	id := v.tracker.track(topic, msg.Key, time.Now())
+	msg.Headers = append(msg.Headers, kafkago.Header{Key: v.cfg.Header, Value: []byte(id)})
+}
+
+// Wait waits up to the configured timeout for outstanding messages, stops
+// the consumers and returns the report
+func (v *Verifier) Wait(ctx context.Context) Report {
+	deadline := time.NewTimer(v.cfg.Timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+wait:
+	for !v.tracker.complete() {
+		select {
+		case <-ctx.Done():
+			break wait
+		case <-deadline.C:
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	v.Close()
+	return v.tracker.report()
+}
+
+// Close stops the consumer
+func (v *Verifier) Close() {
+	if err := v.consumer.Close(); err != nil {
+		v.logger.Debug("failed to close verification consumer", slog.String("error", err.Error()))
+	}
+}
+
+// observe matches a consumed message by its ID header
+func (v *Verifier) observe(msg kafkago.Message) {
+	now := time.Now()
+	for _, h := range msg.Headers {
+		if h.Key == v.cfg.Header {
+			v.tracker.observe(string(h.Value), now)
+			return
+		}
+	}
+}